@@ -3,17 +3,45 @@ package create
 import (
 	"context"
 	"errors"
-	"sync"
+	"fmt"
+	"os"
 
 	"github.com/squarefactory/cloud-burster/logger"
+	"github.com/squarefactory/cloud-burster/pkg/burster"
 	"github.com/squarefactory/cloud-burster/pkg/cloud"
 	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/lock"
+	"github.com/squarefactory/cloud-burster/pkg/report"
+	"github.com/squarefactory/cloud-burster/pkg/state"
 	"github.com/squarefactory/cloud-burster/utils/generators"
 	"github.com/urfave/cli/v2"
 	"go.uber.org/zap"
 )
 
-var flags = []cli.Flag{}
+var flags = []cli.Flag{
+	&cli.StringFlag{
+		Name:    "output",
+		Usage:   "Output format of the report: table or json.",
+		Value:   "table",
+		Aliases: []string{"o"},
+		Action: func(ctx *cli.Context, s string) error {
+			return report.ValidateFormat(s)
+		},
+	},
+	&cli.StringFlag{
+		Name:  "on-exists",
+		Usage: "What to do when a host already has an instance: skip, fail or recreate.",
+		Value: string(burster.OnExistsSkip),
+		Action: func(ctx *cli.Context, s string) error {
+			_, err := burster.ParseOnExists(s)
+			return err
+		},
+	},
+	&cli.BoolFlag{
+		Name:  "fail-fast",
+		Usage: "Fail instead of waiting when another operation holds the lock of a host.",
+	},
+}
 
 var Command = &cli.Command{
 	Name:      "create",
@@ -26,12 +54,9 @@ var Command = &cli.Command{
 			return errors.New("not enough arguments")
 		}
 		arg := cCtx.Args().Get(0)
-		hostnamesRanges := generators.SplitCommaOutsideOfBrackets(arg)
-
-		var hostnames []string
-		for _, hostnamesRange := range hostnamesRanges {
-			h := generators.ExpandBrackets(hostnamesRange)
-			hostnames = append(hostnames, h...)
+		hostnames, err := generators.Expand(arg)
+		if err != nil {
+			return err
 		}
 
 		// Parse config
@@ -42,88 +67,52 @@ var Command = &cli.Command{
 		if err := conf.Validate(); err != nil {
 			return err
 		}
-
-		logger.I.Info("Creating...", zap.Any("hostnames", hostnames))
-
-		var wg sync.WaitGroup
-		errChan := make(chan error)
-
-		for _, hostname := range hostnames {
-			wg.Add(1)
-			go func(ctx context.Context, hostname string, wg *sync.WaitGroup, errChan chan<- error) {
-				defer wg.Done()
-
-				// Search host and cloud by hostname
-				var host *config.Host
-				var cl *config.Cloud
-				var err error
-
-				// Search hosts using hostname and suffix
-				for _, suffix := range conf.SuffixSearch {
-					host, cl, err = conf.SearchHostByHostName(hostname + suffix)
-					if err != nil {
-						errChan <- err
-						return
-					}
-					if host != nil && cl != nil {
-						break
-					}
-				}
-
-				// If host is nil, default to search using hostname
-				if host == nil && cl == nil {
-					host, cl, err = conf.SearchHostByHostName(hostname)
-					if err != nil {
-						errChan <- err
-						return
-					}
-				}
-
-				// If host is still nil, crash
-				if host == nil && cl == nil {
-					errChan <- errors.New("hostname not found")
-					return
-				}
-
-				// Instanciate the corresponding cloud
-				cloudWorker, err := cloud.New(cl)
-				if err != nil {
-					errChan <- err
-					return
-				}
-
-				if err := cloudWorker.Create(ctx, host, cl); err != nil {
-					logger.I.Warn(
-						"couldn't create the host",
-						zap.Error(err),
-						zap.Any("host", host),
-						zap.Any("cloud", cl),
-					)
-					errChan <- err
-					return
-				}
-			}(
-				ctx,
-				hostname,
-				&wg,
-				errChan,
-			)
+		lockTimeout := cCtx.Duration("lock.timeout")
+		if cCtx.Bool("fail-fast") {
+			lockTimeout = 0
+		}
+		b, err := burster.New(
+			conf,
+			cloud.New,
+			burster.WithStore(state.Open(cCtx.String("state.path"))),
+			burster.WithLocker(lock.Open(cCtx.String("lock.dir")), lockTimeout),
+			burster.WithOnExists(burster.OnExists(cCtx.String("on-exists"))),
+		)
+		if err != nil {
+			return err
 		}
 
-		go func() {
-			wg.Wait()
-			close(errChan)
-		}()
+		logger.I.Info("Creating...", zap.Any("hostnames", hostnames))
 
-		for e := range errChan {
-			if e != nil {
-				logger.I.Error("create thrown an error", zap.Error(e))
-				err = e
+		r := report.Collect(
+			ctx,
+			"create",
+			hostnames,
+			cCtx.Int("parallelism"),
+			func(ctx context.Context, hostname string, result *report.Result) error {
+				err := b.Create(ctx, hostname)
+				b.Describe(hostname, result)
+				return err
+			},
+		)
+		for _, result := range r {
+			if result.Status == report.StatusFailure {
+				logger.I.Error(
+					"create thrown an error",
+					zap.String("error", result.Error),
+					zap.String("hostname", result.Hostname),
+				)
 			}
 		}
-		if err != nil {
+		if err := r.Render(os.Stdout, cCtx.String("output")); err != nil {
 			return err
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if code := r.ExitCode(); code != report.ExitSuccess {
+			return cli.Exit(fmt.Sprintf("%d of %d hosts failed", r.Failed(), len(r)), code)
+		}
 
 		logger.I.Info("Create command successful.")
 
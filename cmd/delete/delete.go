@@ -3,19 +3,41 @@ package delete
 import (
 	"context"
 	"errors"
-	"sync"
+	"fmt"
+	"os"
 
 	"github.com/squarefactory/cloud-burster/logger"
+	"github.com/squarefactory/cloud-burster/pkg/burster"
 	"github.com/squarefactory/cloud-burster/pkg/cloud"
 	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/lock"
+	"github.com/squarefactory/cloud-burster/pkg/report"
+	"github.com/squarefactory/cloud-burster/pkg/state"
 	"github.com/squarefactory/cloud-burster/utils/generators"
 	"github.com/urfave/cli/v2"
 	"go.uber.org/zap"
 )
 
+var flags = []cli.Flag{
+	&cli.StringFlag{
+		Name:    "output",
+		Usage:   "Output format of the report: table or json.",
+		Value:   "table",
+		Aliases: []string{"o"},
+		Action: func(ctx *cli.Context, s string) error {
+			return report.ValidateFormat(s)
+		},
+	},
+	&cli.BoolFlag{
+		Name:  "fail-fast",
+		Usage: "Fail instead of waiting when another operation holds the lock of a host.",
+	},
+}
+
 var Command = &cli.Command{
 	Name:      "delete",
 	Usage:     "Delete a VM on a public cloud.",
+	Flags:     flags,
 	ArgsUsage: "<hostname>",
 	Action: func(cCtx *cli.Context) error {
 		ctx := cCtx.Context
@@ -23,12 +45,9 @@ var Command = &cli.Command{
 			return errors.New("not enough arguments")
 		}
 		arg := cCtx.Args().Get(0)
-		hostnamesRanges := generators.SplitCommaOutsideOfBrackets(arg)
-
-		var hostnames []string
-		for _, hostnamesRange := range hostnamesRanges {
-			h := generators.ExpandBrackets(hostnamesRange)
-			hostnames = append(hostnames, h...)
+		hostnames, err := generators.Expand(arg)
+		if err != nil {
+			return err
 		}
 
 		logger.I.Info("Deleting...", zap.Any("hostnames", hostnames))
@@ -41,85 +60,49 @@ var Command = &cli.Command{
 		if err := conf.Validate(); err != nil {
 			return err
 		}
-
-		var wg sync.WaitGroup
-		errChan := make(chan error)
-
-		for _, hostname := range hostnames {
-			wg.Add(1)
-
-			go func(ctx context.Context, hostname string, wg *sync.WaitGroup, errChan chan<- error) {
-				defer wg.Done()
-				// Search host and cloud by hostname
-				var host *config.Host
-				var cl *config.Cloud
-				var err error
-
-				// Search hosts using hostname and suffix
-				for _, suffix := range conf.SuffixSearch {
-					host, cl, err = conf.SearchHostByHostName(hostname + suffix)
-					if err != nil {
-						errChan <- err
-						return
-					}
-					if host != nil && cl != nil {
-						break
-					}
-				}
-
-				// If host is nil, default to search using hostname
-				if host == nil && cl == nil {
-					host, cl, err = conf.SearchHostByHostName(hostname)
-					if err != nil {
-						errChan <- err
-						return
-					}
-				}
-
-				// If host is still nil, crash
-				if host == nil && cl == nil {
-					errChan <- errors.New("hostname not found")
-					return
-				}
-
-				// Instanciate the corresponding cloud
-				cloudWorker, err := cloud.New(cl)
-				if err != nil {
-					errChan <- err
-					return
-				}
-
-				if err := cloudWorker.Delete(ctx, host.Name); err != nil {
-					logger.I.Error(
-						"couldn't delete the host",
-						zap.Error(err),
-						zap.Any("host", host),
-					)
-					errChan <- err
-					return
-				}
-			}(
-				ctx,
-				hostname,
-				&wg,
-				errChan,
-			)
+		lockTimeout := cCtx.Duration("lock.timeout")
+		if cCtx.Bool("fail-fast") {
+			lockTimeout = 0
+		}
+		b, err := burster.New(
+			conf,
+			cloud.New,
+			burster.WithStore(state.Open(cCtx.String("state.path"))),
+			burster.WithLocker(lock.Open(cCtx.String("lock.dir")), lockTimeout),
+		)
+		if err != nil {
+			return err
 		}
 
-		go func() {
-			wg.Wait()
-			close(errChan)
-		}()
-
-		for e := range errChan {
-			if e != nil {
-				logger.I.Error("delete thrown an error", zap.Error(e))
-				err = e
+		r := report.Collect(
+			ctx,
+			"delete",
+			hostnames,
+			cCtx.Int("parallelism"),
+			func(ctx context.Context, hostname string, result *report.Result) error {
+				// The record is gone once deleted
+				b.Describe(hostname, result)
+				return b.Delete(ctx, hostname)
+			},
+		)
+		for _, result := range r {
+			if result.Status == report.StatusFailure {
+				logger.I.Error(
+					"delete thrown an error",
+					zap.String("error", result.Error),
+					zap.String("hostname", result.Hostname),
+				)
 			}
 		}
-		if err != nil {
+		if err := r.Render(os.Stdout, cCtx.String("output")); err != nil {
 			return err
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if code := r.ExitCode(); code != report.ExitSuccess {
+			return cli.Exit(fmt.Sprintf("%d of %d hosts failed", r.Failed(), len(r)), code)
+		}
 
 		logger.I.Info("Delete command successful.")
 
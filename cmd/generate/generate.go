@@ -37,21 +37,32 @@ var Command = &cli.Command{
 		{
 			Name:      "hostnames",
 			Usage:     "Generate hostnames from host pattern",
-			ArgsUsage: "<hostnames>",
+			ArgsUsage: "<hostnames>...",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "compress",
+					Usage: "Print the hostnames as a single hostlist expression, like cn[1-3,5].",
+				},
+			},
 			Action: func(cCtx *cli.Context) error {
 				if cCtx.NArg() < 1 {
 					return errors.New("not enough arguments")
 				}
 
-				arg := cCtx.Args().Get(0)
-				hostnamesRanges := generators.SplitCommaOutsideOfBrackets(arg)
-
 				var hostnames []string
-				for _, hostnamesRange := range hostnamesRanges {
-					h := generators.ExpandBrackets(hostnamesRange)
+				for _, arg := range cCtx.Args().Slice() {
+					h, err := generators.Expand(arg)
+					if err != nil {
+						return err
+					}
 					hostnames = append(hostnames, h...)
 				}
 
+				if cCtx.Bool("compress") {
+					fmt.Println(generators.Compress(hostnames))
+					return nil
+				}
+
 				for _, h := range hostnames {
 					fmt.Println(h)
 				}
@@ -0,0 +1,126 @@
+package list
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/squarefactory/cloud-burster/pkg/burster"
+	"github.com/squarefactory/cloud-burster/pkg/cloud"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/utils/generators"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+var flags = []cli.Flag{
+	&cli.StringFlag{
+		Name:    "output",
+		Usage:   "Output format: table, json or yaml.",
+		Value:   "table",
+		Aliases: []string{"o"},
+		Action: func(ctx *cli.Context, s string) error {
+			switch s {
+			case "table", "json", "yaml":
+				return nil
+			}
+			return fmt.Errorf("unknown output format: %s", s)
+		},
+	},
+}
+
+var Command = &cli.Command{
+	Name:      "list",
+	Usage:     "List configured hosts and their live state.",
+	Flags:     flags,
+	ArgsUsage: "[hostnames]",
+	Action: func(cCtx *cli.Context) error {
+		ctx := cCtx.Context
+
+		var hostnames []string
+		if cCtx.NArg() > 0 {
+			var err error
+			hostnames, err = generators.Expand(cCtx.Args().Get(0))
+			if err != nil {
+				return err
+			}
+		}
+
+		// Parse config
+		conf, err := config.ParseFile(cCtx.String("config.path"))
+		if err != nil {
+			return err
+		}
+		if err := conf.Validate(); err != nil {
+			return err
+		}
+
+		b, err := burster.New(conf, cloud.New)
+		if err != nil {
+			return err
+		}
+		rows, listErr := b.List(ctx, hostnames)
+		if rows == nil && listErr != nil {
+			return listErr
+		}
+
+		if err := render(os.Stdout, cCtx.String("output"), rows); err != nil {
+			return err
+		}
+
+		return listErr
+	},
+}
+
+func render(w io.Writer, format string, rows []burster.Row) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	case "yaml":
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(rows); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "HOSTNAME\tCLOUD\tIP\tSTATE\tPROVIDER ID\tLIVE IPS\tFLAVOR\tIMAGE\tCREATED")
+	for _, row := range rows {
+		var id, ips, flavor, image, created string
+		ip := row.IP
+		if row.IPv6 != "" {
+			ip = strings.TrimPrefix(ip+","+row.IPv6, ",")
+		}
+		if row.Instance != nil {
+			id = row.Instance.ProviderID
+			ips = strings.Join(row.Instance.IPs, ",")
+			flavor = row.Instance.Flavor
+			image = row.Instance.Image
+			if row.Instance.CreatedAt != nil {
+				created = row.Instance.CreatedAt.Format(time.RFC3339)
+			}
+		}
+		fmt.Fprintf(
+			tw,
+			"%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			row.Hostname,
+			row.Cloud,
+			ip,
+			row.State,
+			id,
+			ips,
+			flavor,
+			image,
+			created,
+		)
+	}
+	return tw.Flush()
+}
@@ -1,16 +1,38 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/squarefactory/cloud-burster/cmd/create"
 	"github.com/squarefactory/cloud-burster/cmd/delete"
 	"github.com/squarefactory/cloud-burster/cmd/generate"
+	"github.com/squarefactory/cloud-burster/cmd/list"
+	"github.com/squarefactory/cloud-burster/cmd/providers"
+	"github.com/squarefactory/cloud-burster/cmd/reconcile"
 	"github.com/squarefactory/cloud-burster/cmd/search"
+	"github.com/squarefactory/cloud-burster/cmd/serve"
+	"github.com/squarefactory/cloud-burster/cmd/slurm"
+	cmdstate "github.com/squarefactory/cloud-burster/cmd/state"
 	"github.com/squarefactory/cloud-burster/cmd/validate.go"
 	"github.com/squarefactory/cloud-burster/logger"
+	"github.com/squarefactory/cloud-burster/pkg/lock"
+	"github.com/squarefactory/cloud-burster/pkg/state"
 	"github.com/urfave/cli/v2"
 	"go.uber.org/zap"
+
+	// Providers, registered by their init function
+	_ "github.com/squarefactory/cloud-burster/pkg/aws"
+	_ "github.com/squarefactory/cloud-burster/pkg/exoscale"
+	_ "github.com/squarefactory/cloud-burster/pkg/hetzner"
+	_ "github.com/squarefactory/cloud-burster/pkg/libvirt"
+	_ "github.com/squarefactory/cloud-burster/pkg/openstack"
+	_ "github.com/squarefactory/cloud-burster/pkg/plugin"
+	_ "github.com/squarefactory/cloud-burster/pkg/shadow"
 )
 
 var version = "dev"
@@ -39,6 +61,44 @@ var flags = []cli.Flag{
 			return nil
 		},
 	},
+	&cli.StringFlag{
+		Name:  "state.path",
+		Usage: "Path of the file recording the provisioned instances. Empty to disable.",
+		Value: state.DefaultPath,
+		EnvVars: []string{
+			"STATE_PATH",
+		},
+	},
+	&cli.StringFlag{
+		Name:  "lock.dir",
+		Usage: "Directory of the per-host lock files. Empty to only lock within the process.",
+		Value: lock.DefaultDir,
+		EnvVars: []string{
+			"LOCK_DIR",
+		},
+	},
+	&cli.DurationFlag{
+		Name:  "lock.timeout",
+		Usage: "How long to wait for the lock of a host held by another operation.",
+		Value: 10 * time.Minute,
+		EnvVars: []string{
+			"LOCK_TIMEOUT",
+		},
+	},
+	&cli.IntFlag{
+		Name:  "parallelism",
+		Usage: "Maximum number of hosts handled at the same time. 0 for no limit.",
+		Value: 10,
+		EnvVars: []string{
+			"PARALLELISM",
+		},
+		Action: func(ctx *cli.Context, i int) error {
+			if i < 0 {
+				return fmt.Errorf("parallelism must be positive: %d", i)
+			}
+			return nil
+		},
+	},
 	&cli.BoolFlag{
 		Name:  "debug",
 		Value: false,
@@ -60,14 +120,37 @@ var app = &cli.App{
 		create.Command,
 		delete.Command,
 		generate.Command,
+		list.Command,
+		providers.Command,
+		reconcile.Command,
 		search.Command,
+		serve.Command,
+		slurm.Command,
+		cmdstate.Command,
 		validate.Command,
 	},
 	Suggest: true,
 }
 
+// exitInterrupted is the exit code when a signal interrupted the command.
+const exitInterrupted = 130
+
 func main() {
-	if err := app.Run(os.Args); err != nil {
+	// stop is not deferred: the context must only be done on a signal
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		logger.I.Warn("interrupted, canceling the operations (press again to force)")
+		// A second signal kills the process
+		stop()
+	}()
+
+	if err := app.RunContext(ctx, os.Args); err != nil {
+		if ctx.Err() != nil {
+			logger.I.Error("interrupted", zap.Error(err))
+			stop()
+			os.Exit(exitInterrupted)
+		}
 		logger.I.Fatal("app crashed", zap.Error(err))
 	}
 }
@@ -0,0 +1,46 @@
+package providers
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/squarefactory/cloud-burster/pkg/cloud"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/urfave/cli/v2"
+)
+
+var Command = &cli.Command{
+	Name:  "providers",
+	Usage: "List the providers, and the options of their provider block.",
+	Action: func(cCtx *cli.Context) error {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, name := range config.Providers() {
+			p, ok := cloud.Lookup(name)
+			if !ok {
+				continue
+			}
+			fmt.Fprintf(w, "%s: %s\n", name, p.Description)
+			fmt.Fprintln(w, "  KEY\tTYPE\tVALIDATION")
+			t := reflect.TypeOf(p.Options())
+			for t.Kind() == reflect.Pointer {
+				t = t.Elem()
+			}
+			for i := 0; i < t.NumField(); i++ {
+				field := t.Field(i)
+				key, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+				if !field.IsExported() || key == "-" {
+					continue
+				}
+				if key == "" {
+					key = strings.ToLower(field.Name)
+				}
+				fmt.Fprintf(w, "  %s\t%s\t%s\n", key, field.Type, field.Tag.Get("validate"))
+			}
+			fmt.Fprintln(w)
+		}
+		return w.Flush()
+	},
+}
@@ -0,0 +1,87 @@
+package reconcile
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/squarefactory/cloud-burster/logger"
+	"github.com/squarefactory/cloud-burster/pkg/cloud"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/lock"
+	"github.com/squarefactory/cloud-burster/pkg/reconcile"
+	"github.com/urfave/cli/v2"
+	"go.uber.org/zap"
+)
+
+var flags = []cli.Flag{
+	&cli.BoolFlag{
+		Name:  "apply",
+		Usage: "Delete the orphan resources. Without it, the actions are only printed.",
+		Value: false,
+	},
+}
+
+var Command = &cli.Command{
+	Name:  "reconcile",
+	Usage: "Find and delete orphan servers, ports and block devices.",
+	Flags: flags,
+	Action: func(cCtx *cli.Context) error {
+		ctx := cCtx.Context
+		apply := cCtx.Bool("apply")
+
+		// Parse config
+		conf, err := config.ParseFile(cCtx.String("config.path"))
+		if err != nil {
+			return err
+		}
+		if err := conf.Validate(); err != nil {
+			return err
+		}
+
+		locker := lock.Open(cCtx.String("lock.dir"))
+		lockTimeout := cCtx.Duration("lock.timeout")
+
+		var errs []error
+		for idx := range conf.Clouds {
+			cl := &conf.Clouds[idx]
+
+			// Instanciate the corresponding cloud
+			cloudWorker, err := cloud.New(cl)
+			if err != nil {
+				return err
+			}
+
+			actions, err := reconcile.Plan(ctx, conf, idx, cloudWorker)
+			if err != nil {
+				logger.I.Error(
+					"couldn't plan the reconciliation",
+					zap.Error(err),
+					zap.Int("cloud", idx),
+				)
+				errs = append(errs, err)
+				continue
+			}
+			for _, action := range actions {
+				fmt.Println(action)
+			}
+
+			if !apply || len(actions) == 0 {
+				continue
+			}
+			if err := reconcile.Apply(ctx, cl, cloudWorker, actions, locker, lockTimeout); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err := errors.Join(errs...); err != nil {
+			return err
+		}
+
+		if apply {
+			logger.I.Info("Reconcile command successful.")
+		} else {
+			logger.I.Info("Nothing was deleted, use --apply to execute the plan.")
+		}
+
+		return nil
+	},
+}
@@ -3,16 +3,30 @@ package search
 import (
 	"context"
 	"errors"
-	"sync"
+	"fmt"
+	"os"
 
 	"github.com/squarefactory/cloud-burster/logger"
+	"github.com/squarefactory/cloud-burster/pkg/burster"
+	"github.com/squarefactory/cloud-burster/pkg/cloud"
 	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/report"
 	"github.com/squarefactory/cloud-burster/utils/generators"
 	"github.com/urfave/cli/v2"
 	"go.uber.org/zap"
 )
 
-var flags = []cli.Flag{}
+var flags = []cli.Flag{
+	&cli.StringFlag{
+		Name:    "output",
+		Usage:   "Output format of the report: table or json.",
+		Value:   "table",
+		Aliases: []string{"o"},
+		Action: func(ctx *cli.Context, s string) error {
+			return report.ValidateFormat(s)
+		},
+	},
+}
 
 var Command = &cli.Command{
 	Name:      "search",
@@ -25,12 +39,9 @@ var Command = &cli.Command{
 			return errors.New("not enough arguments")
 		}
 		arg := cCtx.Args().Get(0)
-		hostnamesRanges := generators.SplitCommaOutsideOfBrackets(arg)
-
-		var hostnames []string
-		for _, hostnamesRange := range hostnamesRanges {
-			h := generators.ExpandBrackets(hostnamesRange)
-			hostnames = append(hostnames, h...)
+		hostnames, err := generators.Expand(arg)
+		if err != nil {
+			return err
 		}
 
 		// Parse config
@@ -42,75 +53,47 @@ var Command = &cli.Command{
 			return err
 		}
 
-		logger.I.Info("Searching...", zap.Any("hostnames", hostnames))
-
-		var wg sync.WaitGroup
-		errChan := make(chan error)
-
-		for _, hostname := range hostnames {
-			wg.Add(1)
-			go func(ctx context.Context, hostname string, wg *sync.WaitGroup, errChan chan<- error) {
-				defer wg.Done()
-
-				// Search host and cloud by hostname
-				var host *config.Host
-				var cl *config.Cloud
-				var err error
-
-				// Search hosts using hostname and suffix
-				for _, suffix := range conf.SuffixSearch {
-					host, cl, err = conf.SearchHostByHostName(hostname + suffix)
-					if err != nil {
-						errChan <- err
-						return
-					}
-					if host != nil && cl != nil {
-						break
-					}
-				}
+		b, err := burster.New(conf, cloud.New)
+		if err != nil {
+			return err
+		}
 
-				// If host is nil, default to search using hostname
-				if host == nil && cl == nil {
-					host, cl, err = conf.SearchHostByHostName(hostname)
-					if err != nil {
-						errChan <- err
-						return
-					}
-				}
+		logger.I.Info("Searching...", zap.Any("hostnames", hostnames))
 
-				// If host is still nil, crash
-				if host == nil && cl == nil {
-					errChan <- errors.New("hostname not found")
-					return
+		r := report.Collect(
+			ctx,
+			"search",
+			hostnames,
+			cCtx.Int("parallelism"),
+			func(ctx context.Context, hostname string, result *report.Result) error {
+				host, idx, err := b.Resolve(hostname)
+				if err != nil {
+					return err
 				}
-
+				b.Describe(hostname, result)
 				logger.I.Info(
 					"Search command successful.",
 					zap.Any("host", host),
-					zap.Any("cloud", cl),
+					zap.Any("cloud", &conf.Clouds[idx]),
+				)
+				return nil
+			},
+		)
+		for _, result := range r {
+			if result.Status == report.StatusFailure {
+				logger.I.Error(
+					"search thrown an error",
+					zap.String("error", result.Error),
+					zap.String("hostname", result.Hostname),
 				)
-			}(
-				ctx,
-				hostname,
-				&wg,
-				errChan,
-			)
-		}
-
-		go func() {
-			wg.Wait()
-			close(errChan)
-		}()
-
-		for e := range errChan {
-			if e != nil {
-				logger.I.Error("create thrown an error", zap.Error(e))
-				err = e
 			}
 		}
-		if err != nil {
+		if err := r.Render(os.Stdout, cCtx.String("output")); err != nil {
 			return err
 		}
+		if code := r.ExitCode(); code != report.ExitSuccess {
+			return cli.Exit(fmt.Sprintf("%d of %d hosts failed", r.Failed(), len(r)), code)
+		}
 
 		logger.I.Info("Search command successful.")
 
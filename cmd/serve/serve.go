@@ -0,0 +1,89 @@
+package serve
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/squarefactory/cloud-burster/logger"
+	"github.com/squarefactory/cloud-burster/pkg/burster"
+	"github.com/squarefactory/cloud-burster/pkg/cloud"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/lock"
+	"github.com/squarefactory/cloud-burster/pkg/server"
+	"github.com/squarefactory/cloud-burster/pkg/state"
+	"github.com/urfave/cli/v2"
+	"go.uber.org/zap"
+)
+
+var flags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  "listen-address",
+		Usage: "Address of the HTTP API.",
+		Value: "127.0.0.1:8080",
+		EnvVars: []string{
+			"LISTEN_ADDRESS",
+		},
+	},
+	&cli.StringFlag{
+		Name:  "token",
+		Usage: "Bearer token required by the HTTP API. Disabled if empty.",
+		EnvVars: []string{
+			"API_TOKEN",
+		},
+	},
+}
+
+var Command = &cli.Command{
+	Name:  "serve",
+	Usage: "Run a daemon exposing create, delete and list through an HTTP API.",
+	Flags: flags,
+	Action: func(cCtx *cli.Context) error {
+		// Canceled on SIGINT and SIGTERM
+		ctx := cCtx.Context
+
+		// Parse config
+		conf, err := config.ParseFile(cCtx.String("config.path"))
+		if err != nil {
+			return err
+		}
+		if err := conf.Validate(); err != nil {
+			return err
+		}
+
+		b, err := burster.New(
+			conf,
+			cloud.New,
+			burster.WithStore(state.Open(cCtx.String("state.path"))),
+			burster.WithLocker(lock.Open(cCtx.String("lock.dir")), cCtx.Duration("lock.timeout")),
+		)
+		if err != nil {
+			return err
+		}
+		srv := server.New(ctx, b, cCtx.String("token"), cCtx.Int("parallelism"))
+		httpServer := &http.Server{
+			Addr:              cCtx.String("listen-address"),
+			Handler:           srv,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		go func() {
+			<-ctx.Done()
+			logger.I.Info("Shutting down...")
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := httpServer.Shutdown(shutdownCtx); err != nil {
+				logger.I.Error("couldn't shutdown the server", zap.Error(err))
+			}
+		}()
+
+		logger.I.Info("Serving...", zap.String("address", httpServer.Addr))
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		srv.Wait()
+
+		return nil
+	},
+}
@@ -0,0 +1,133 @@
+package slurm
+
+import (
+	"context"
+	"errors"
+
+	"github.com/squarefactory/cloud-burster/logger"
+	"github.com/squarefactory/cloud-burster/pkg/burster"
+	"github.com/squarefactory/cloud-burster/pkg/cloud"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/lock"
+	"github.com/squarefactory/cloud-burster/pkg/slurm"
+	"github.com/squarefactory/cloud-burster/pkg/state"
+	"github.com/squarefactory/cloud-burster/utils/generators"
+	"github.com/urfave/cli/v2"
+	"go.uber.org/zap"
+)
+
+var flags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  "scontrol",
+		Usage: "Path to the scontrol executable.",
+		Value: "scontrol",
+		EnvVars: []string{
+			"SCONTROL_PATH",
+		},
+	},
+}
+
+var Command = &cli.Command{
+	Name:  "slurm",
+	Usage: "Slurm power saving hooks.",
+	Flags: flags,
+	Subcommands: []*cli.Command{
+		{
+			Name:      "resume",
+			Usage:     "ResumeProgram: spawn the nodes.",
+			ArgsUsage: "[hostlist]",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "resume-file",
+					Usage: "JSON file describing the nodes to resume. Used when the hostlist is not given.",
+					EnvVars: []string{
+						"SLURM_RESUME_FILE",
+					},
+				},
+			},
+			Action: func(cCtx *cli.Context) error {
+				hostlist := cCtx.Args().Get(0)
+				if path := cCtx.String("resume-file"); path != "" {
+					file, err := slurm.ParseResumeFile(path)
+					if err != nil {
+						return err
+					}
+					logger.I.Info("parsed resume file", zap.Any("file", file))
+					if hostlist == "" {
+						hostlist = file.AllNodesResume
+					}
+				}
+				return run(cCtx, "resume", hostlist, createNode)
+			},
+		},
+		{
+			Name:      "suspend",
+			Usage:     "SuspendProgram: delete the nodes.",
+			ArgsUsage: "<hostlist>",
+			Action: func(cCtx *cli.Context) error {
+				return run(cCtx, "suspend", cCtx.Args().Get(0), deleteNode)
+			},
+		},
+		{
+			Name:      "resume-fail",
+			Usage:     "ResumeFailProgram: clean up the nodes which failed to resume.",
+			ArgsUsage: "<hostlist>",
+			Action: func(cCtx *cli.Context) error {
+				return run(cCtx, "resume-fail", cCtx.Args().Get(0), deleteNode)
+			},
+		},
+	},
+}
+
+func createNode(ctx context.Context, b *burster.Burster, node string) error {
+	return b.Create(ctx, node)
+}
+
+func deleteNode(ctx context.Context, b *burster.Burster, node string) error {
+	return b.Delete(ctx, node)
+}
+
+func run(
+	cCtx *cli.Context,
+	action string,
+	hostlist string,
+	fn func(ctx context.Context, b *burster.Burster, hostname string) error,
+) error {
+	if hostlist == "" {
+		return errors.New("not enough arguments")
+	}
+	nodes, err := generators.Expand(hostlist)
+	if err != nil {
+		return err
+	}
+
+	// Parse config
+	conf, err := config.ParseFile(cCtx.String("config.path"))
+	if err != nil {
+		return err
+	}
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+	b, err := burster.New(
+		conf,
+		cloud.New,
+		burster.WithStore(state.Open(cCtx.String("state.path"))),
+		burster.WithLocker(lock.Open(cCtx.String("lock.dir")), cCtx.Duration("lock.timeout")),
+	)
+	if err != nil {
+		return err
+	}
+
+	logger.I.Info("Running slurm hook...", zap.String("action", action), zap.Any("nodes", nodes))
+
+	scontrol := &slurm.Scontrol{Path: cCtx.String("scontrol")}
+	if err := slurm.Run(cCtx.Context, scontrol, action, nodes, cCtx.Int("parallelism"), func(ctx context.Context, node string) error {
+		return fn(ctx, b, node)
+	}); err != nil {
+		return err
+	}
+
+	logger.I.Info("Slurm hook successful.", zap.String("action", action))
+	return nil
+}
@@ -0,0 +1,187 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/squarefactory/cloud-burster/logger"
+	"github.com/squarefactory/cloud-burster/pkg/burster"
+	"github.com/squarefactory/cloud-burster/pkg/cloud"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/lock"
+	"github.com/squarefactory/cloud-burster/pkg/provider"
+	"github.com/squarefactory/cloud-burster/pkg/state"
+	"github.com/squarefactory/cloud-burster/utils/generators"
+	"github.com/urfave/cli/v2"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+var outputFlag = &cli.StringFlag{
+	Name:    "output",
+	Usage:   "Output format: table, json or yaml.",
+	Value:   "table",
+	Aliases: []string{"o"},
+	Action: func(ctx *cli.Context, s string) error {
+		switch s {
+		case "table", "json", "yaml":
+			return nil
+		}
+		return fmt.Errorf("unknown output format: %s", s)
+	},
+}
+
+var Command = &cli.Command{
+	Name:  "state",
+	Usage: "Inspect and repair the records of the provisioned instances.",
+	Subcommands: []*cli.Command{
+		{
+			Name:  "list",
+			Usage: "List the records.",
+			Flags: []cli.Flag{outputFlag},
+			Action: func(cCtx *cli.Context) error {
+				records, err := open(cCtx).List()
+				if err != nil {
+					return err
+				}
+				return render(os.Stdout, cCtx.String("output"), records)
+			},
+		},
+		{
+			Name:      "get",
+			Usage:     "Show the record of a host.",
+			Flags:     []cli.Flag{outputFlag},
+			ArgsUsage: "<hostname>",
+			Action: func(cCtx *cli.Context) error {
+				if cCtx.NArg() < 1 {
+					return errors.New("not enough arguments")
+				}
+				record, err := open(cCtx).Get(cCtx.Args().Get(0))
+				if err != nil {
+					return err
+				}
+				return render(os.Stdout, cCtx.String("output"), []state.Record{*record})
+			},
+		},
+		{
+			Name:      "forget",
+			Usage:     "Remove the record of a host, without touching the instance.",
+			ArgsUsage: "<hostname>",
+			Action: func(cCtx *cli.Context) error {
+				if cCtx.NArg() < 1 {
+					return errors.New("not enough arguments")
+				}
+				return open(cCtx).Delete(cCtx.Args().Get(0))
+			},
+		},
+		{
+			Name:      "refresh",
+			Usage:     "Rebuild the records from the live instances. Defaults to the recorded hosts.",
+			ArgsUsage: "[hostnames]",
+			Action:    refresh,
+		},
+	},
+}
+
+func open(cCtx *cli.Context) state.Store {
+	return state.Open(cCtx.String("state.path"))
+}
+
+func refresh(cCtx *cli.Context) error {
+	ctx := cCtx.Context
+	store := open(cCtx)
+
+	var hostnames []string
+	if cCtx.NArg() > 0 {
+		var err error
+		hostnames, err = generators.Expand(cCtx.Args().Get(0))
+		if err != nil {
+			return err
+		}
+	} else {
+		records, err := store.List()
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			hostnames = append(hostnames, record.Hostname)
+		}
+	}
+
+	// Parse config
+	conf, err := config.ParseFile(cCtx.String("config.path"))
+	if err != nil {
+		return err
+	}
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+	b, err := burster.New(
+		conf,
+		cloud.New,
+		burster.WithStore(store),
+		burster.WithLocker(lock.Open(cCtx.String("lock.dir")), cCtx.Duration("lock.timeout")),
+	)
+	if err != nil {
+		return err
+	}
+
+	var refreshErr error
+	for _, hostname := range hostnames {
+		record, err := b.Refresh(ctx, hostname)
+		switch {
+		case errors.Is(err, provider.ErrNotFound):
+			logger.I.Info("instance not found, record removed", zap.String("hostname", hostname))
+		case err != nil:
+			logger.I.Error("couldn't refresh the record", zap.Error(err), zap.String("hostname", hostname))
+			refreshErr = err
+		default:
+			logger.I.Info("record refreshed", zap.Any("record", record))
+		}
+	}
+	return refreshErr
+}
+
+func render(w io.Writer, format string, records []state.Record) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	case "yaml":
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(records); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "HOSTNAME\tCLOUD\tPROVIDER ID\tPORTS\tVOLUMES\tCREATED\tCONFIG HASH")
+	for _, record := range records {
+		hash := record.ConfigHash
+		if len(hash) > 12 {
+			hash = hash[:12]
+		}
+		fmt.Fprintf(
+			tw,
+			"%s\t%d/%s\t%s\t%s\t%s\t%s\t%s\n",
+			record.Hostname,
+			record.Cloud,
+			record.CloudType,
+			record.ProviderID,
+			strings.Join(record.Ports, ","),
+			strings.Join(record.Volumes, ","),
+			record.CreatedAt.Format(time.RFC3339),
+			hash,
+		)
+	}
+	return tw.Flush()
+}
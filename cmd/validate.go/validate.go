@@ -1,13 +1,26 @@
 package validate
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
 	"github.com/squarefactory/cloud-burster/logger"
+	"github.com/squarefactory/cloud-burster/pkg/cloud"
 	"github.com/squarefactory/cloud-burster/pkg/config"
 	"github.com/urfave/cli/v2"
+	"go.uber.org/zap"
 )
 
 var Command = &cli.Command{
-	Name: "validate",
+	Name:  "validate",
+	Usage: "Validate the configuration.",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "remote",
+			Usage: "Check the images, flavors, networks, subnets and quotas against each cloud.",
+		},
+	},
 	Action: func(cCtx *cli.Context) error {
 		// Parse config
 		conf, err := config.ParseFile(cCtx.String("config.path"))
@@ -15,11 +28,84 @@ var Command = &cli.Command{
 			return err
 		}
 		if err := conf.Validate(); err != nil {
+			var issues config.Issues
+			if !errors.As(err, &issues) {
+				return err
+			}
+			for _, issue := range issues {
+				logger.I.Error(
+					issue.Message,
+					zap.String("path", issue.Path),
+					zap.Int("line", issue.Line),
+				)
+			}
+			return cli.Exit(fmt.Sprintf("%d issue(s) in the configuration", len(issues)), 1)
+		}
+
+		resolver, err := config.NewResolver(conf)
+		if err != nil {
 			return err
 		}
+		for name, clouds := range resolver.Duplicates() {
+			logger.I.Warn(
+				"hostname defined in several clouds, it cannot be resolved",
+				zap.String("hostname", name),
+				zap.Ints("clouds", clouds),
+			)
+		}
+
+		if cCtx.Bool("remote") {
+			findings, err := checkRemote(cCtx.Context, conf)
+			if err != nil {
+				return err
+			}
+			if findings > 0 {
+				return cli.Exit(fmt.Sprintf("%d problem(s) found in the clouds", findings), 1)
+			}
+		}
 
 		logger.I.Info("Config is valid.")
 
 		return nil
 	},
 }
+
+// checkRemote checks every cloud against its provider, and returns the number of findings.
+func checkRemote(ctx context.Context, conf *config.Config) (int, error) {
+	var count int
+	var errs []error
+	for idx := range conf.Clouds {
+		cl := &conf.Clouds[idx]
+
+		// Instanciate the corresponding cloud
+		cloudWorker, err := cloud.New(cl)
+		if err != nil {
+			return count, err
+		}
+		checker, ok := cloudWorker.(cloud.Checker)
+		if !ok {
+			logger.I.Warn(
+				"remote validation is not supported by the cloud",
+				zap.Int("cloud", idx),
+				zap.String("type", cl.Type),
+			)
+			continue
+		}
+
+		findings, err := checker.Check(ctx, cl)
+		for _, finding := range findings {
+			logger.I.Error(
+				finding.Message,
+				zap.Int("cloud", idx),
+				zap.String("kind", finding.Kind),
+				zap.String("name", finding.Name),
+			)
+		}
+		count += len(findings)
+		if err != nil {
+			logger.I.Error("couldn't check the cloud", zap.Error(err), zap.Int("cloud", idx))
+			errs = append(errs, err)
+		}
+	}
+	return count, errors.Join(errs...)
+}
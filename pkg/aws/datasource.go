@@ -0,0 +1,571 @@
+package aws
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
+	"github.com/squarefactory/cloud-burster/logger"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/middlewares"
+	"github.com/squarefactory/cloud-burster/pkg/provider"
+	"github.com/squarefactory/cloud-burster/utils/rollback"
+	"github.com/squarefactory/cloud-burster/utils/try"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+	"gopkg.in/yaml.v3"
+)
+
+// TagHostname is the tag of the instances and network interfaces, whose value is the hostname.
+const TagHostname = "cloud-burster:hostname"
+
+// DefaultRetry is the default retry policy of the data source.
+var DefaultRetry = try.Policy{
+	MaxElapsed:   5 * time.Minute,
+	InitialDelay: 2 * time.Second,
+	MaxDelay:     15 * time.Second,
+	Multiplier:   2,
+	Jitter:       0.2,
+	Retryable:    Retryable,
+}
+
+// Retryable returns false for the client errors of the API, except the rate limits and the
+// resources which are not visible yet.
+func Retryable(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		code := apiErr.ErrorCode()
+		if code == "RequestLimitExceeded" || strings.HasSuffix(code, ".NotFound") {
+			return true
+		}
+	}
+	var respErr *awshttp.ResponseError
+	if !errors.As(err, &respErr) {
+		return true
+	}
+	code := respErr.HTTPStatusCode()
+	if code == http.StatusRequestTimeout || code == http.StatusTooManyRequests {
+		return true
+	}
+	return code < 400 || code >= 500
+}
+
+type DataSource struct {
+	// Retry is the retry policy of the operations which are eventually consistent.
+	Retry   try.Policy
+	client  *ec2.Client
+	options *Options
+}
+
+// New returns a data source managing the instances of the region of the options.
+func New(opts *Options, limiter *rate.Limiter) (*DataSource, error) {
+	clientOpts := ec2.Options{
+		Region: opts.Region,
+		Credentials: awssdk.NewCredentialsCache(awssdk.CredentialsProviderFunc(
+			func(context.Context) (awssdk.Credentials, error) {
+				return awssdk.Credentials{
+					AccessKeyID:     opts.AccessKeyID,
+					SecretAccessKey: opts.SecretAccessKey,
+					SessionToken:    opts.SessionToken,
+					Source:          "cloud-burster",
+				}, nil
+			},
+		)),
+		HTTPClient: &http.Client{Transport: middlewares.Transport(limiter)},
+	}
+	if opts.Endpoint != "" {
+		clientOpts.BaseEndpoint = awssdk.String(opts.Endpoint)
+	}
+	return &DataSource{
+		Retry:   DefaultRetry,
+		client:  ec2.New(clientOpts),
+		options: opts,
+	}, nil
+}
+
+// Create creates a network interface with the IPs of the host in the subnet of the cloud,
+// then an instance booting on it with the cloud-config as user-data.
+func (s *DataSource) Create(
+	ctx context.Context,
+	host *config.Host,
+	cloud *config.Cloud,
+) error {
+	logger.I.Debug(
+		"Create called",
+		zap.Any("host", host),
+		zap.Any("cloud", cloud),
+	)
+	if cloud.Network == nil {
+		return errors.New("the network of the cloud is required")
+	}
+
+	image, err := s.FindImage(ctx, host.ImageName)
+	if err != nil {
+		return err
+	}
+	subnetID, err := s.FindSubnetID(ctx, cloud.Network)
+	if err != nil {
+		return err
+	}
+
+	var customConfig []byte
+	if len(cloud.CustomConfig) > 0 {
+		customConfig, err = yaml.Marshal(cloud.CustomConfig)
+		if err != nil {
+			return err
+		}
+	}
+	userData, err := GenerateCloudConfig(&CloudConfigOpts{
+		Hostname:          host.Name,
+		AuthorizedKeys:    cloud.AuthorizedKeys,
+		PostScripts:       cloud.PostScripts,
+		DNS:               cloud.Network.Nameservers(),
+		Search:            cloud.Network.Search,
+		CustomCloudConfig: string(customConfig),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Interfaces left behind by a failed creation hold the IPs of the host
+	if err := s.deleteDanglingInterfaces(ctx, host.Name); err != nil {
+		return err
+	}
+
+	tx := rollback.New("create " + host.Name)
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	eniInput := &ec2.CreateNetworkInterfaceInput{
+		SubnetId:    awssdk.String(subnetID),
+		Description: awssdk.String("cloud-burster " + host.Name),
+		Groups:      s.options.SecurityGroupIDs,
+		TagSpecifications: []ec2types.TagSpecification{
+			{
+				ResourceType: ec2types.ResourceTypeNetworkInterface,
+				Tags:         tags(host.Name),
+			},
+		},
+	}
+	if host.IP != "" {
+		eniInput.PrivateIpAddress = awssdk.String(host.IP)
+	}
+	if host.IPv6 != "" {
+		eniInput.Ipv6Addresses = []ec2types.InstanceIpv6Address{
+			{Ipv6Address: awssdk.String(host.IPv6)},
+		}
+	}
+	eni, err := s.client.CreateNetworkInterface(ctx, eniInput)
+	if err != nil {
+		return fmt.Errorf("couldn't create the network interface: %w", err)
+	}
+	eniID := awssdk.ToString(eni.NetworkInterface.NetworkInterfaceId)
+	tx.Push("network interface "+eniID, func(ctx context.Context) error {
+		return s.deleteInterface(ctx, eniID)
+	})
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	runInput := &ec2.RunInstancesInput{
+		ImageId:      image.ImageId,
+		InstanceType: ec2types.InstanceType(s.options.InstanceType(host.FlavorName)),
+		MinCount:     awssdk.Int32(1),
+		MaxCount:     awssdk.Int32(1),
+		UserData:     awssdk.String(base64.StdEncoding.EncodeToString(userData)),
+		NetworkInterfaces: []ec2types.InstanceNetworkInterfaceSpecification{
+			{
+				DeviceIndex:        awssdk.Int32(0),
+				NetworkInterfaceId: awssdk.String(eniID),
+			},
+		},
+		TagSpecifications: []ec2types.TagSpecification{
+			{ResourceType: ec2types.ResourceTypeInstance, Tags: tags(host.Name)},
+			{ResourceType: ec2types.ResourceTypeVolume, Tags: tags(host.Name)},
+		},
+	}
+	if host.DiskSize > 0 && image.RootDeviceName != nil {
+		runInput.BlockDeviceMappings = []ec2types.BlockDeviceMapping{
+			{
+				DeviceName: image.RootDeviceName,
+				Ebs: &ec2types.EbsBlockDevice{
+					VolumeSize:          awssdk.Int32(int32(host.DiskSize)),
+					VolumeType:          ec2types.VolumeTypeGp3,
+					DeleteOnTermination: awssdk.Bool(true),
+				},
+			},
+		}
+	}
+	if s.options.KeyName != "" {
+		runInput.KeyName = awssdk.String(s.options.KeyName)
+	}
+	run, err := s.client.RunInstances(ctx, runInput)
+	if err != nil {
+		return fmt.Errorf("couldn't run the instance: %w", err)
+	}
+	if len(run.Instances) == 0 {
+		return errors.New("no instance was started")
+	}
+	instance := run.Instances[0]
+	instanceID := awssdk.ToString(instance.InstanceId)
+	tx.Push("instance "+instanceID, func(ctx context.Context) error {
+		return s.terminate(ctx, []string{instanceID})
+	})
+
+	// The interface was created outside of the instance: delete it with the instance
+	for _, ni := range instance.NetworkInterfaces {
+		if awssdk.ToString(ni.NetworkInterfaceId) != eniID || ni.Attachment == nil {
+			continue
+		}
+		if _, err := try.Do(ctx, s.Retry, func() (*ec2.ModifyNetworkInterfaceAttributeOutput, error) {
+			return s.client.ModifyNetworkInterfaceAttribute(ctx, &ec2.ModifyNetworkInterfaceAttributeInput{
+				NetworkInterfaceId: awssdk.String(eniID),
+				Attachment: &ec2types.NetworkInterfaceAttachmentChanges{
+					AttachmentId:        ni.Attachment.AttachmentId,
+					DeleteOnTermination: awssdk.Bool(true),
+				},
+			})
+		}); err != nil {
+			// Delete cleans up the interfaces of the host
+			logger.I.Warn(
+				"couldn't delete the network interface on termination",
+				zap.Error(err),
+				zap.String("networkInterface", eniID),
+			)
+		}
+	}
+
+	tx.Commit()
+	logger.I.Info(
+		"created instance",
+		zap.String("name", host.Name),
+		zap.String("instanceID", instanceID),
+	)
+	return nil
+}
+
+// Delete terminates the instances tagged with the hostname, then deletes their network
+// interfaces.
+func (s *DataSource) Delete(ctx context.Context, name string) error {
+	logger.I.Debug("Delete called", zap.String("name", name))
+
+	instances, err := s.describe(ctx, name)
+	if err != nil {
+		return err
+	}
+	var ids []string
+	for _, instance := range instances {
+		if instance.State != nil && instance.State.Name == ec2types.InstanceStateNameTerminated {
+			continue
+		}
+		ids = append(ids, awssdk.ToString(instance.InstanceId))
+	}
+	if len(ids) == 0 {
+		return provider.ErrNotFound
+	}
+	if err := s.terminate(ctx, ids); err != nil {
+		return err
+	}
+	return s.deleteDanglingInterfaces(ctx, name)
+}
+
+// List returns the instances tagged by cloud-burster.
+func (s *DataSource) List(ctx context.Context) ([]provider.Instance, error) {
+	instances, err := s.describe(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	return s.toInstances(ctx, instances)
+}
+
+// Get returns the newest instance tagged with the hostname.
+func (s *DataSource) Get(ctx context.Context, name string) (*provider.Instance, error) {
+	instances, err := s.describe(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	out, err := s.toInstances(ctx, instances)
+	if err != nil {
+		return nil, err
+	}
+	return provider.Newest(out, name)
+}
+
+// toInstances converts EC2 instances, whose networks are named after the Name tag of their
+// subnet, like the networks of the configuration.
+func (s *DataSource) toInstances(
+	ctx context.Context,
+	instances []ec2types.Instance,
+) ([]provider.Instance, error) {
+	subnetNames, err := s.subnetNames(ctx, instances)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]provider.Instance, 0, len(instances))
+	for _, instance := range instances {
+		out = append(out, toInstance(instance, subnetNames))
+	}
+	return out, nil
+}
+
+// subnetNames returns the Name tag of the subnets of the instances, by subnet ID. The subnets
+// without the tag are named after their ID.
+func (s *DataSource) subnetNames(
+	ctx context.Context,
+	instances []ec2types.Instance,
+) (map[string]string, error) {
+	out := make(map[string]string)
+	var ids []string
+	for _, instance := range instances {
+		id := awssdk.ToString(instance.SubnetId)
+		if _, ok := out[id]; id == "" || ok {
+			continue
+		}
+		out[id] = id
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return out, nil
+	}
+	subnets, err := s.client.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{
+		SubnetIds: ids,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't describe the subnets: %w", err)
+	}
+	for _, subnet := range subnets.Subnets {
+		if name := tagValue(subnet.Tags, "Name"); name != "" {
+			out[awssdk.ToString(subnet.SubnetId)] = name
+		}
+	}
+	return out, nil
+}
+
+// FindImage returns an image by ID ("ami-..."), by filters ("name=rocky-9-*,architecture=x86_64"),
+// or by name. Wildcards are allowed in the names and filters, the newest image is returned.
+func (s *DataSource) FindImage(ctx context.Context, image string) (*ec2types.Image, error) {
+	input := &ec2.DescribeImagesInput{}
+	switch {
+	case strings.HasPrefix(image, "ami-") && !strings.ContainsAny(image, "=*"):
+		input.ImageIds = []string{image}
+	case strings.Contains(image, "="):
+		input.Owners = s.options.ImageOwners
+		for _, filter := range strings.Split(image, ",") {
+			key, value, _ := strings.Cut(filter, "=")
+			input.Filters = append(input.Filters, ec2types.Filter{
+				Name:   awssdk.String(strings.TrimSpace(key)),
+				Values: []string{strings.TrimSpace(value)},
+			})
+		}
+	default:
+		input.Owners = s.options.ImageOwners
+		input.Filters = []ec2types.Filter{{Name: awssdk.String("name"), Values: []string{image}}}
+	}
+	out, err := s.client.DescribeImages(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't find the image %s: %w", image, err)
+	}
+	if len(out.Images) == 0 {
+		return nil, fmt.Errorf("image not found: %s", image)
+	}
+	images := out.Images
+	// CreationDate is in ISO 8601
+	sort.SliceStable(images, func(i, j int) bool {
+		return awssdk.ToString(images[i].CreationDate) > awssdk.ToString(images[j].CreationDate)
+	})
+	return &images[0], nil
+}
+
+// FindSubnetID returns the subnet whose CIDR is the subnet of the network. If several
+// subnets match, the one whose Name tag is the name of the network is returned.
+func (s *DataSource) FindSubnetID(ctx context.Context, network *config.Network) (string, error) {
+	out, err := s.client.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{
+		Filters: []ec2types.Filter{
+			{Name: awssdk.String("cidr-block"), Values: []string{network.SubnetCIDR}},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("couldn't find the subnet %s: %w", network.SubnetCIDR, err)
+	}
+	switch len(out.Subnets) {
+	case 0:
+		return "", fmt.Errorf("subnet not found: %s", network.SubnetCIDR)
+	case 1:
+		return awssdk.ToString(out.Subnets[0].SubnetId), nil
+	}
+	for _, subnet := range out.Subnets {
+		if tagValue(subnet.Tags, "Name") == network.Name {
+			return awssdk.ToString(subnet.SubnetId), nil
+		}
+	}
+	return "", fmt.Errorf(
+		"%d subnets match %s, tag one of them with Name=%s",
+		len(out.Subnets),
+		network.SubnetCIDR,
+		network.Name,
+	)
+}
+
+// describe returns the instances tagged with the hostname, every tagged instance if name is
+// empty.
+func (s *DataSource) describe(ctx context.Context, name string) ([]ec2types.Instance, error) {
+	filter := ec2types.Filter{Name: awssdk.String("tag-key"), Values: []string{TagHostname}}
+	if name != "" {
+		filter = ec2types.Filter{Name: awssdk.String("tag:" + TagHostname), Values: []string{name}}
+	}
+	paginator := ec2.NewDescribeInstancesPaginator(s.client, &ec2.DescribeInstancesInput{
+		Filters: []ec2types.Filter{filter},
+	})
+	var out []ec2types.Instance
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, reservation := range page.Reservations {
+			out = append(out, reservation.Instances...)
+		}
+	}
+	return out, nil
+}
+
+// terminate terminates the instances and waits for their termination.
+func (s *DataSource) terminate(ctx context.Context, ids []string) error {
+	if _, err := s.client.TerminateInstances(ctx, &ec2.TerminateInstancesInput{
+		InstanceIds: ids,
+	}); err != nil {
+		return fmt.Errorf("couldn't terminate the instances %v: %w", ids, err)
+	}
+	_, err := try.Do(ctx, s.Retry, func() (struct{}, error) {
+		out, err := s.client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
+			InstanceIds: ids,
+		})
+		if err != nil {
+			return struct{}{}, err
+		}
+		for _, reservation := range out.Reservations {
+			for _, instance := range reservation.Instances {
+				if instance.State != nil && instance.State.Name != ec2types.InstanceStateNameTerminated {
+					return struct{}{}, fmt.Errorf(
+						"instance %s is %s",
+						awssdk.ToString(instance.InstanceId),
+						instance.State.Name,
+					)
+				}
+			}
+		}
+		return struct{}{}, nil
+	})
+	return err
+}
+
+// deleteDanglingInterfaces deletes the network interfaces of the hostname which are not
+// attached to any instance.
+func (s *DataSource) deleteDanglingInterfaces(ctx context.Context, name string) error {
+	out, err := s.client.DescribeNetworkInterfaces(ctx, &ec2.DescribeNetworkInterfacesInput{
+		Filters: []ec2types.Filter{
+			{Name: awssdk.String("tag:" + TagHostname), Values: []string{name}},
+			{Name: awssdk.String("status"), Values: []string{string(ec2types.NetworkInterfaceStatusAvailable)}},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't list the network interfaces of %s: %w", name, err)
+	}
+	for _, ni := range out.NetworkInterfaces {
+		if err := s.deleteInterface(ctx, awssdk.ToString(ni.NetworkInterfaceId)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *DataSource) deleteInterface(ctx context.Context, id string) error {
+	_, err := try.Do(ctx, s.Retry, func() (*ec2.DeleteNetworkInterfaceOutput, error) {
+		return s.client.DeleteNetworkInterface(ctx, &ec2.DeleteNetworkInterfaceInput{
+			NetworkInterfaceId: awssdk.String(id),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't delete the network interface %s: %w", id, err)
+	}
+	logger.I.Info("deleted network interface", zap.String("networkInterface", id))
+	return nil
+}
+
+func tags(name string) []ec2types.Tag {
+	return []ec2types.Tag{
+		{Key: awssdk.String("Name"), Value: awssdk.String(name)},
+		{Key: awssdk.String(TagHostname), Value: awssdk.String(name)},
+	}
+}
+
+func tagValue(tags []ec2types.Tag, key string) string {
+	for _, tag := range tags {
+		if awssdk.ToString(tag.Key) == key {
+			return awssdk.ToString(tag.Value)
+		}
+	}
+	return ""
+}
+
+// toInstance converts an EC2 instance into a provider-neutral record. subnetNames maps the
+// subnet IDs to the names of the networks.
+func toInstance(instance ec2types.Instance, subnetNames map[string]string) provider.Instance {
+	out := provider.Instance{
+		Name:       tagValue(instance.Tags, TagHostname),
+		ProviderID: awssdk.ToString(instance.InstanceId),
+		State:      provider.StateUnknown,
+		Flavor:     string(instance.InstanceType),
+		Image:      awssdk.ToString(instance.ImageId),
+		CreatedAt:  instance.LaunchTime,
+	}
+	out.Managed = out.Name != ""
+	if instance.State != nil {
+		out.State = toState(instance.State.Name)
+	}
+	if instance.SubnetId != nil {
+		out.Networks = []string{subnetNames[awssdk.ToString(instance.SubnetId)]}
+	}
+	if instance.PrivateIpAddress != nil {
+		out.IPs = append(out.IPs, awssdk.ToString(instance.PrivateIpAddress))
+	}
+	for _, ni := range instance.NetworkInterfaces {
+		out.Ports = append(out.Ports, awssdk.ToString(ni.NetworkInterfaceId))
+		for _, ipv6 := range ni.Ipv6Addresses {
+			out.IPs = append(out.IPs, awssdk.ToString(ipv6.Ipv6Address))
+		}
+	}
+	for _, bdm := range instance.BlockDeviceMappings {
+		if bdm.Ebs != nil {
+			out.Volumes = append(out.Volumes, awssdk.ToString(bdm.Ebs.VolumeId))
+		}
+	}
+	return out
+}
+
+func toState(state ec2types.InstanceStateName) provider.State {
+	switch state {
+	case ec2types.InstanceStateNamePending:
+		return provider.StatePending
+	case ec2types.InstanceStateNameRunning:
+		return provider.StateRunning
+	case ec2types.InstanceStateNameStopping, ec2types.InstanceStateNameStopped:
+		return provider.StateStopped
+	case ec2types.InstanceStateNameShuttingDown:
+		return provider.StateDeleting
+	case ec2types.InstanceStateNameTerminated:
+		return provider.StateTerminated
+	}
+	return provider.StateUnknown
+}
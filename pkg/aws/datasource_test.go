@@ -0,0 +1,519 @@
+//go:build unit
+
+package aws_test
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/squarefactory/cloud-burster/pkg/aws"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/provider"
+	"github.com/squarefactory/cloud-burster/utils/try"
+	"github.com/stretchr/testify/suite"
+)
+
+// mockEC2 is an EC2-compatible endpoint, implementing the actions used by the data source.
+type mockEC2 struct {
+	mu         sync.Mutex
+	images     []mockImage
+	subnets    map[string]mockSubnet
+	interfaces map[string]*mockInterface
+	instances  []*mockInstance
+	actions    []string
+	lastID     int
+}
+
+type mockImage struct {
+	id, name, creationDate string
+}
+
+type mockSubnet struct {
+	cidr, name string
+}
+
+type mockInterface struct {
+	id, subnet, ip, status string
+	tags                   map[string]string
+	deleteOnTermination    bool
+}
+
+type mockInstance struct {
+	id, image, instanceType, userData, state string
+	eni                                      *mockInterface
+	tags                                     map[string]string
+	volumeSize                               string
+}
+
+func newMockEC2() *mockEC2 {
+	return &mockEC2{
+		images: []mockImage{
+			{"ami-old", "rocky-9-2023", "2023-01-01T00:00:00.000Z"},
+			{"ami-new", "rocky-9-2024", "2024-01-01T00:00:00.000Z"},
+			{"ami-ubuntu", "ubuntu-22.04", "2024-02-01T00:00:00.000Z"},
+		},
+		subnets:    map[string]mockSubnet{"subnet-1": {"172.28.0.0/20", "net"}},
+		interfaces: make(map[string]*mockInterface),
+	}
+}
+
+func (m *mockEC2) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	action := r.Form.Get("Action")
+	m.actions = append(m.actions, action)
+	body, code, msg := m.handle(action, r.Form)
+	w.Header().Set("Content-Type", "text/xml")
+	if code != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(
+			w,
+			"<Response><Errors><Error><Code>%s</Code><Message>%s</Message></Error></Errors><RequestID>1</RequestID></Response>",
+			code,
+			msg,
+		)
+		return
+	}
+	fmt.Fprintf(w, "<%[1]sResponse><requestId>1</requestId>%[2]s</%[1]sResponse>", action, body)
+}
+
+func (m *mockEC2) handle(action string, form url.Values) (body string, code string, msg string) {
+	filters := formFilters(form)
+	switch action {
+	case "DescribeImages":
+		ids := formList(form, "ImageId")
+		var sb strings.Builder
+		for _, image := range m.images {
+			if len(ids) > 0 && !contains(ids, image.id) {
+				continue
+			}
+			if !matches(filters["name"], image.name) {
+				continue
+			}
+			fmt.Fprintf(
+				&sb,
+				"<item><imageId>%s</imageId><name>%s</name><creationDate>%s</creationDate><rootDeviceName>/dev/sda1</rootDeviceName></item>",
+				image.id,
+				image.name,
+				image.creationDate,
+			)
+		}
+		return "<imagesSet>" + sb.String() + "</imagesSet>", "", ""
+	case "DescribeSubnets":
+		ids := formList(form, "SubnetId")
+		var sb strings.Builder
+		for id, subnet := range m.subnets {
+			if len(ids) > 0 && !contains(ids, id) {
+				continue
+			}
+			if !matches(filters["cidr-block"], subnet.cidr) {
+				continue
+			}
+			var tags string
+			if subnet.name != "" {
+				tags = "<tagSet><item><key>Name</key><value>" + subnet.name + "</value></item></tagSet>"
+			}
+			fmt.Fprintf(
+				&sb,
+				"<item><subnetId>%s</subnetId><cidrBlock>%s</cidrBlock><vpcId>vpc-1</vpcId>%s</item>",
+				id,
+				subnet.cidr,
+				tags,
+			)
+		}
+		return "<subnetSet>" + sb.String() + "</subnetSet>", "", ""
+	case "CreateNetworkInterface":
+		ip := form.Get("PrivateIpAddress")
+		for _, eni := range m.interfaces {
+			if eni.ip == ip {
+				return "", "InvalidIPAddress.InUse", "Address " + ip + " is in use."
+			}
+		}
+		m.lastID++
+		eni := &mockInterface{
+			id:     fmt.Sprintf("eni-%d", m.lastID),
+			subnet: form.Get("SubnetId"),
+			ip:     ip,
+			status: "available",
+			tags:   formTags(form, "network-interface"),
+		}
+		m.interfaces[eni.id] = eni
+		return fmt.Sprintf(
+			"<networkInterface><networkInterfaceId>%s</networkInterfaceId><privateIpAddress>%s</privateIpAddress><status>available</status></networkInterface>",
+			eni.id,
+			eni.ip,
+		), "", ""
+	case "RunInstances":
+		if strings.HasPrefix(form.Get("InstanceType"), "invalid") {
+			return "", "InvalidParameterValue", "Invalid value for instanceType"
+		}
+		eni, ok := m.interfaces[form.Get("NetworkInterface.1.NetworkInterfaceId")]
+		if !ok {
+			return "", "InvalidNetworkInterfaceID.NotFound", "no such interface"
+		}
+		eni.status = "in-use"
+		userData, _ := base64.StdEncoding.DecodeString(form.Get("UserData"))
+		m.lastID++
+		instance := &mockInstance{
+			id:           fmt.Sprintf("i-%d", m.lastID),
+			image:        form.Get("ImageId"),
+			instanceType: form.Get("InstanceType"),
+			userData:     string(userData),
+			state:        "running",
+			eni:          eni,
+			tags:         formTags(form, "instance"),
+			volumeSize:   form.Get("BlockDeviceMapping.1.Ebs.VolumeSize"),
+		}
+		m.instances = append(m.instances, instance)
+		return fmt.Sprintf("<reservationId>r-1</reservationId><instancesSet>%s</instancesSet>", instance.xml()), "", ""
+	case "ModifyNetworkInterfaceAttribute":
+		eni, ok := m.interfaces[form.Get("NetworkInterfaceId")]
+		if !ok {
+			return "", "InvalidNetworkInterfaceID.NotFound", "no such interface"
+		}
+		eni.deleteOnTermination = form.Get("Attachment.DeleteOnTermination") == "true"
+		return "<return>true</return>", "", ""
+	case "DescribeInstances":
+		ids := formList(form, "InstanceId")
+		var sb strings.Builder
+		for _, instance := range m.instances {
+			if len(ids) > 0 && !contains(ids, instance.id) {
+				continue
+			}
+			if _, ok := filters["tag-key"]; ok {
+				if _, tagged := instance.tags[aws.TagHostname]; !tagged {
+					continue
+				}
+			}
+			if values, ok := filters["tag:"+aws.TagHostname]; ok && !contains(values, instance.tags[aws.TagHostname]) {
+				continue
+			}
+			sb.WriteString(instance.xml())
+		}
+		return "<reservationSet><item><reservationId>r-1</reservationId><instancesSet>" +
+			sb.String() +
+			"</instancesSet></item></reservationSet>", "", ""
+	case "TerminateInstances":
+		var sb strings.Builder
+		for _, id := range formList(form, "InstanceId") {
+			for _, instance := range m.instances {
+				if instance.id != id {
+					continue
+				}
+				instance.state = "terminated"
+				if instance.eni.deleteOnTermination {
+					delete(m.interfaces, instance.eni.id)
+				} else {
+					instance.eni.status = "available"
+				}
+				fmt.Fprintf(&sb, "<item><instanceId>%s</instanceId><currentState><name>shutting-down</name></currentState></item>", id)
+			}
+		}
+		return "<instancesSet>" + sb.String() + "</instancesSet>", "", ""
+	case "DescribeNetworkInterfaces":
+		var sb strings.Builder
+		for _, eni := range m.interfaces {
+			if !matches(filters["tag:"+aws.TagHostname], eni.tags[aws.TagHostname]) ||
+				!matches(filters["status"], eni.status) {
+				continue
+			}
+			fmt.Fprintf(&sb, "<item><networkInterfaceId>%s</networkInterfaceId><status>%s</status></item>", eni.id, eni.status)
+		}
+		return "<networkInterfaceSet>" + sb.String() + "</networkInterfaceSet>", "", ""
+	case "DeleteNetworkInterface":
+		eni, ok := m.interfaces[form.Get("NetworkInterfaceId")]
+		if !ok {
+			return "", "InvalidNetworkInterfaceID.NotFound", "no such interface"
+		}
+		if eni.status != "available" {
+			return "", "InvalidNetworkInterface.InUse", "interface in use"
+		}
+		delete(m.interfaces, eni.id)
+		return "<return>true</return>", "", ""
+	}
+	return "", "InvalidAction", "unsupported action " + action
+}
+
+func (i *mockInstance) xml() string {
+	var tags strings.Builder
+	for k, v := range i.tags {
+		fmt.Fprintf(&tags, "<item><key>%s</key><value>%s</value></item>", k, v)
+	}
+	return fmt.Sprintf(
+		"<item><instanceId>%s</instanceId><imageId>%s</imageId><instanceType>%s</instanceType>"+
+			"<instanceState><code>16</code><name>%s</name></instanceState>"+
+			"<launchTime>2024-03-01T00:00:00.000Z</launchTime><privateIpAddress>%s</privateIpAddress><subnetId>%s</subnetId>"+
+			"<networkInterfaceSet><item><networkInterfaceId>%s</networkInterfaceId><attachment><attachmentId>attach-%s</attachmentId></attachment></item></networkInterfaceSet>"+
+			"<tagSet>%s</tagSet></item>",
+		i.id,
+		i.image,
+		i.instanceType,
+		i.state,
+		i.eni.ip,
+		i.eni.subnet,
+		i.eni.id,
+		i.eni.id,
+		tags.String(),
+	)
+}
+
+// formList returns the values of a list parameter, like InstanceId.1, InstanceId.2...
+func formList(form url.Values, prefix string) []string {
+	var out []string
+	for n := 1; form.Has(fmt.Sprintf("%s.%d", prefix, n)); n++ {
+		out = append(out, form.Get(fmt.Sprintf("%s.%d", prefix, n)))
+	}
+	return out
+}
+
+func formFilters(form url.Values) map[string][]string {
+	out := make(map[string][]string)
+	for n := 1; form.Has(fmt.Sprintf("Filter.%d.Name", n)); n++ {
+		name := form.Get(fmt.Sprintf("Filter.%d.Name", n))
+		out[name] = formList(form, fmt.Sprintf("Filter.%d.Value", n))
+	}
+	return out
+}
+
+func formTags(form url.Values, resourceType string) map[string]string {
+	out := make(map[string]string)
+	for n := 1; form.Has(fmt.Sprintf("TagSpecification.%d.ResourceType", n)); n++ {
+		if form.Get(fmt.Sprintf("TagSpecification.%d.ResourceType", n)) != resourceType {
+			continue
+		}
+		for t := 1; form.Has(fmt.Sprintf("TagSpecification.%d.Tag.%d.Key", n, t)); t++ {
+			out[form.Get(fmt.Sprintf("TagSpecification.%d.Tag.%d.Key", n, t))] =
+				form.Get(fmt.Sprintf("TagSpecification.%d.Tag.%d.Value", n, t))
+		}
+	}
+	return out
+}
+
+// matches returns true if no pattern is given, or if value matches one of them.
+func matches(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	host = config.Host{
+		Name:       "cn1.example.com",
+		DiskSize:   50,
+		FlavorName: "d2-2",
+		ImageName:  "rocky-9-*",
+		IP:         "172.28.1.1",
+	}
+
+	cloud = config.Cloud{
+		Type: "aws",
+		AuthorizedKeys: []string{
+			"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIDUnXMBGq6bV6H+c7P5QjDn1soeB6vkodi6OswcZsMwH nguye@PC-DARKNESS4",
+		},
+		Network: &config.Network{
+			Name:       "net",
+			SubnetCIDR: "172.28.0.0/20",
+			DNS:        "172.28.0.2",
+			Gateway:    "172.28.0.1",
+		},
+	}
+)
+
+type DataSourceTestSuite struct {
+	suite.Suite
+	mock   *mockEC2
+	server *httptest.Server
+	impl   *aws.DataSource
+}
+
+func (suite *DataSourceTestSuite) BeforeTest(suiteName, testName string) {
+	suite.mock = newMockEC2()
+	suite.server = httptest.NewServer(suite.mock)
+	impl, err := aws.New(&aws.Options{
+		AccessKeyID:     "key",
+		SecretAccessKey: "secret",
+		Region:          "eu-west-1",
+		Endpoint:        suite.server.URL,
+		InstanceTypes:   map[string]string{"d2-2": "t3.small"},
+	}, nil)
+	suite.Require().NoError(err)
+	impl.Retry = try.Constant(3, time.Millisecond)
+	suite.impl = impl
+}
+
+func (suite *DataSourceTestSuite) AfterTest(suiteName, testName string) {
+	suite.server.Close()
+}
+
+func (suite *DataSourceTestSuite) TestCreateAndDelete() {
+	ctx := context.Background()
+
+	// Act
+	err := suite.impl.Create(ctx, &host, &cloud)
+
+	// Assert
+	suite.Require().NoError(err)
+	suite.Require().Len(suite.mock.instances, 1)
+	instance := suite.mock.instances[0]
+	suite.Equal("ami-new", instance.image)
+	suite.Equal("t3.small", instance.instanceType)
+	suite.Equal("50", instance.volumeSize)
+	suite.Equal("subnet-1", instance.eni.subnet)
+	suite.Equal(host.IP, instance.eni.ip)
+	suite.True(instance.eni.deleteOnTermination)
+	suite.Equal(host.Name, instance.tags[aws.TagHostname])
+	suite.Contains(instance.userData, "#cloud-config")
+	suite.Contains(instance.userData, "hostname: "+host.Name)
+
+	// Act
+	found, err := suite.impl.Get(ctx, host.Name)
+
+	// Assert
+	suite.NoError(err)
+	suite.Equal(provider.StateRunning, found.State)
+	suite.Equal([]string{host.IP}, found.IPs)
+	suite.Equal([]string{cloud.Network.Name}, found.Networks)
+	suite.Equal(instance.id, found.ProviderID)
+	suite.True(found.Managed)
+
+	// Act
+	err = suite.impl.Delete(ctx, host.Name)
+
+	// Assert
+	suite.NoError(err)
+	suite.Equal("terminated", instance.state)
+	suite.Empty(suite.mock.interfaces)
+
+	// Act
+	err = suite.impl.Delete(ctx, host.Name)
+	instances, listErr := suite.impl.List(ctx)
+
+	// Assert
+	suite.ErrorIs(err, provider.ErrNotFound)
+	suite.NoError(listErr)
+	suite.Require().Len(instances, 1)
+	suite.Equal(provider.StateTerminated, instances[0].State)
+}
+
+func (suite *DataSourceTestSuite) TestCreateRollback() {
+	// Arrange
+	invalid := host
+	invalid.FlavorName = "invalid.large"
+
+	// Act
+	err := suite.impl.Create(context.Background(), &invalid, &cloud)
+
+	// Assert
+	suite.ErrorContains(err, "InvalidParameterValue")
+	suite.Empty(suite.mock.instances)
+	suite.Empty(suite.mock.interfaces)
+}
+
+func (suite *DataSourceTestSuite) TestCreateDanglingInterface() {
+	// Arrange
+	ctx := context.Background()
+	suite.Require().NoError(suite.impl.Create(ctx, &host, &cloud))
+	// The interface is left behind by a failed deletion
+	eni := suite.mock.instances[0].eni
+	eni.deleteOnTermination = false
+	suite.mock.instances[0].state = "terminated"
+	eni.status = "available"
+
+	// Act
+	err := suite.impl.Create(ctx, &host, &cloud)
+
+	// Assert
+	suite.NoError(err)
+	suite.NotContains(suite.mock.interfaces, eni.id)
+	suite.Len(suite.mock.interfaces, 1)
+}
+
+func (suite *DataSourceTestSuite) TestCreateIPInUse() {
+	// Arrange
+	suite.mock.interfaces["eni-other"] = &mockInterface{
+		id:     "eni-other",
+		ip:     host.IP,
+		status: "in-use",
+	}
+
+	// Act
+	err := suite.impl.Create(context.Background(), &host, &cloud)
+
+	// Assert
+	suite.ErrorContains(err, "InvalidIPAddress.InUse")
+	suite.Empty(suite.mock.instances)
+}
+
+func (suite *DataSourceTestSuite) TestFindImage() {
+	tests := []struct {
+		input      string
+		expected   string
+		isError    bool
+		errorMatch string
+		title      string
+	}{
+		{input: "ami-ubuntu", expected: "ami-ubuntu", title: "By ID"},
+		{input: "rocky-9-2023", expected: "ami-old", title: "By name"},
+		{input: "rocky-9-*", expected: "ami-new", title: "Newest by name"},
+		{input: "name=ubuntu-*", expected: "ami-ubuntu", title: "By filter"},
+		{input: "debian-12", isError: true, errorMatch: "image not found", title: "Not found"},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Act
+			image, err := suite.impl.FindImage(context.Background(), tt.input)
+
+			// Assert
+			if tt.isError {
+				suite.ErrorContains(err, tt.errorMatch)
+			} else {
+				suite.NoError(err)
+				suite.Equal(tt.expected, *image.ImageId)
+			}
+		})
+	}
+}
+
+func (suite *DataSourceTestSuite) TestFindSubnetID() {
+	// Act
+	id, err := suite.impl.FindSubnetID(context.Background(), cloud.Network)
+	_, notFoundErr := suite.impl.FindSubnetID(context.Background(), &config.Network{SubnetCIDR: "10.0.0.0/24"})
+
+	// Assert
+	suite.NoError(err)
+	suite.Equal("subnet-1", id)
+	suite.ErrorContains(notFoundErr, "subnet not found")
+}
+
+func TestDataSourceTestSuite(t *testing.T) {
+	suite.Run(t, &DataSourceTestSuite{})
+}
@@ -0,0 +1,54 @@
+package aws
+
+import (
+	"github.com/squarefactory/cloud-burster/pkg/cloud"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	validation "github.com/squarefactory/cloud-burster/validate"
+)
+
+func init() {
+	cloud.Register("aws", cloud.Provider{
+		Description: "AWS EC2 instances, with a network interface per host.",
+		Options:     func() config.ProviderOptions { return &Options{} },
+		New: func(conf *config.Cloud) (cloud.DataSource, error) {
+			ds, err := New(conf.Provider.(*Options), conf.RateLimit.Limiter())
+			if err != nil {
+				return nil, err
+			}
+			ds.Retry = conf.Retry.Apply(ds.Retry)
+			return ds, nil
+		},
+	})
+}
+
+// Options are the options of the provider block of the aws clouds.
+type Options struct {
+	AccessKeyID     string `yaml:"accessKeyID"            validate:"required"`
+	SecretAccessKey string `yaml:"secretAccessKey"        validate:"required"`
+	SessionToken    string `yaml:"sessionToken,omitempty"`
+	Region          string `yaml:"region"                 validate:"required"`
+	// Endpoint overrides the endpoint of EC2, for the EC2-compatible APIs.
+	Endpoint string `yaml:"endpoint,omitempty" validate:"omitempty,url"`
+	// ImageOwners restricts the search of the AMIs by name, like "amazon" or an account ID.
+	ImageOwners []string `yaml:"imageOwners,omitempty"`
+	// InstanceTypes maps the flavor names of the hosts to instance types. A flavor name
+	// which is not mapped is used as the instance type.
+	InstanceTypes map[string]string `yaml:"instanceTypes,omitempty"`
+	// SecurityGroupIDs are attached to the network interfaces. The default security group
+	// of the VPC is used if empty.
+	SecurityGroupIDs []string `yaml:"securityGroupIDs,omitempty"`
+	// KeyName is the name of the key pair of the instances.
+	KeyName string `yaml:"keyName,omitempty"`
+}
+
+func (o *Options) Validate() error {
+	return validation.I.Struct(o)
+}
+
+// InstanceType returns the instance type of a flavor.
+func (o *Options) InstanceType(flavor string) string {
+	if t, ok := o.InstanceTypes[flavor]; ok {
+		return t
+	}
+	return flavor
+}
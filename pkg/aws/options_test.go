@@ -0,0 +1,83 @@
+//go:build unit
+
+package aws_test
+
+import (
+	"testing"
+
+	"github.com/squarefactory/cloud-burster/pkg/aws"
+	"github.com/stretchr/testify/suite"
+)
+
+var cleanOptions = aws.Options{
+	AccessKeyID:     "key",
+	SecretAccessKey: "secret",
+	Region:          "eu-west-1",
+	InstanceTypes:   map[string]string{"d2-2": "t3.small"},
+}
+
+type OptionsTestSuite struct {
+	suite.Suite
+}
+
+func (suite *OptionsTestSuite) TestValidate() {
+	tests := []struct {
+		input         *aws.Options
+		isError       bool
+		errorContains []string
+		title         string
+	}{
+		{
+			input: &cleanOptions,
+			title: "Positive test",
+		},
+		{
+			input:         &aws.Options{},
+			isError:       true,
+			errorContains: []string{"AccessKeyID", "SecretAccessKey", "Region", "required"},
+			title:         "Required fields",
+		},
+		{
+			input: &aws.Options{
+				AccessKeyID:     cleanOptions.AccessKeyID,
+				SecretAccessKey: cleanOptions.SecretAccessKey,
+				Region:          cleanOptions.Region,
+				Endpoint:        "localhost",
+			},
+			isError:       true,
+			errorContains: []string{"Endpoint", "url"},
+			title:         "Valid endpoint",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Act
+			err := tt.input.Validate()
+
+			// Assert
+			if tt.isError {
+				suite.Error(err)
+				for _, contain := range tt.errorContains {
+					suite.ErrorContains(err, contain)
+				}
+			} else {
+				suite.NoError(err)
+			}
+		})
+	}
+}
+
+func (suite *OptionsTestSuite) TestInstanceType() {
+	// Act
+	mapped := cleanOptions.InstanceType("d2-2")
+	unmapped := cleanOptions.InstanceType("m5.large")
+
+	// Assert
+	suite.Equal("t3.small", mapped)
+	suite.Equal("m5.large", unmapped)
+}
+
+func TestOptionsTestSuite(t *testing.T) {
+	suite.Run(t, &OptionsTestSuite{})
+}
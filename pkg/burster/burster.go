@@ -0,0 +1,453 @@
+package burster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/squarefactory/cloud-burster/logger"
+	"github.com/squarefactory/cloud-burster/pkg/cloud"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/lock"
+	"github.com/squarefactory/cloud-burster/pkg/provider"
+	"github.com/squarefactory/cloud-burster/pkg/readiness"
+	"github.com/squarefactory/cloud-burster/pkg/report"
+	"github.com/squarefactory/cloud-burster/pkg/state"
+	"go.uber.org/zap"
+)
+
+// ErrHostNotFound is returned when a hostname is not in the configuration.
+var ErrHostNotFound = config.ErrHostNotFound
+
+// ErrExists is returned by Create when the host already has an instance and the policy is OnExistsFail.
+var ErrExists = errors.New("instance already exists")
+
+// OnExists is the policy of Create when the host already has an instance.
+type OnExists string
+
+const (
+	// OnExistsSkip adopts the existing instance.
+	OnExistsSkip OnExists = "skip"
+	// OnExistsFail returns ErrExists.
+	OnExistsFail OnExists = "fail"
+	// OnExistsRecreate deletes the existing instance before creating a new one.
+	OnExistsRecreate OnExists = "recreate"
+)
+
+// ParseOnExists parses a policy.
+func ParseOnExists(s string) (OnExists, error) {
+	switch p := OnExists(s); p {
+	case OnExistsSkip, OnExistsFail, OnExistsRecreate:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown on-exists policy: %s", s)
+}
+
+// Factory instanciates the data source of a cloud.
+type Factory func(conf *config.Cloud) (cloud.DataSource, error)
+
+// Option configures a Burster.
+type Option func(b *Burster)
+
+// WithStore records the provisioned instances in store.
+func WithStore(store state.Store) Option {
+	return func(b *Burster) {
+		b.store = store
+	}
+}
+
+// WithLocker serializes the operations on a host with locker.
+//
+// A second operation on a locked host waits for timeout. A zero timeout fails fast,
+// and a negative timeout waits until the context is done.
+func WithLocker(locker lock.Locker, timeout time.Duration) Option {
+	return func(b *Burster) {
+		b.locker = locker
+		b.lockTimeout = timeout
+	}
+}
+
+// WithOnExists sets the policy of Create when the host already has an instance.
+//
+// Defaults to OnExistsSkip.
+func WithOnExists(policy OnExists) Option {
+	return func(b *Burster) {
+		b.onExists = policy
+	}
+}
+
+// Burster runs operations on the hosts of a configuration.
+//
+// Data sources are instanciated once per cloud and shared between operations.
+type Burster struct {
+	conf    *config.Config
+	factory Factory
+	hosts   *config.Resolver
+	store   state.Store
+
+	locker      lock.Locker
+	lockTimeout time.Duration
+	onExists    OnExists
+	prober      *readiness.Prober
+
+	mu          sync.Mutex
+	dataSources map[int]cloud.DataSource
+}
+
+func New(conf *config.Config, factory Factory, opts ...Option) (*Burster, error) {
+	hosts, err := config.NewResolver(conf)
+	if err != nil {
+		return nil, err
+	}
+	b := &Burster{
+		conf:        conf,
+		factory:     factory,
+		hosts:       hosts,
+		store:       state.NewMemoryStore(),
+		locker:      lock.NewMemoryLocker(),
+		lockTimeout: -1,
+		onExists:    OnExistsSkip,
+		prober:      readiness.NewProber(),
+		dataSources: make(map[int]cloud.DataSource),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b, nil
+}
+
+// Store returns the state store of the burster.
+func (b *Burster) Store() state.Store {
+	return b.store
+}
+
+// Config returns the configuration of the burster.
+func (b *Burster) Config() *config.Config {
+	return b.conf
+}
+
+// Resolve searches a host using the hostname and the search suffixes.
+//
+// It returns the host and the index of its cloud. See config.Resolver.
+func (b *Burster) Resolve(hostname string) (*config.Host, int, error) {
+	return b.hosts.Resolve(hostname)
+}
+
+// DataSource returns the data source of a cloud, instanciating it on first use.
+func (b *Burster) DataSource(idx int) (cloud.DataSource, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ds, ok := b.dataSources[idx]; ok {
+		return ds, nil
+	}
+	ds, err := b.factory(&b.conf.Clouds[idx])
+	if err != nil {
+		return nil, err
+	}
+	b.dataSources[idx] = ds
+	return ds, nil
+}
+
+// Create spawns the host.
+//
+// If the host already has an instance, the OnExists policy applies.
+func (b *Burster) Create(ctx context.Context, hostname string) error {
+	host, idx, err := b.Resolve(hostname)
+	if err != nil {
+		return err
+	}
+	ds, err := b.DataSource(idx)
+	if err != nil {
+		return err
+	}
+	release, err := b.lock(ctx, host.Name)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	existing, err := ds.Get(ctx, host.Name)
+	switch {
+	case errors.Is(err, provider.ErrNotFound):
+	case err != nil:
+		logger.I.Error("couldn't search the existing instance", zap.Error(err), zap.String("host", host.Name))
+		return err
+	case existing.State == provider.StateTerminated || existing.State == provider.StateDeleting:
+		logger.I.Info("ignoring the instance on its way out", zap.Any("instance", existing))
+	default:
+		logger.I.Warn(
+			"host already has an instance",
+			zap.Any("instance", existing),
+			zap.String("policy", string(b.onExists)),
+		)
+		switch b.onExists {
+		case OnExistsFail:
+			return fmt.Errorf("%s: %w", host.Name, ErrExists)
+		case OnExistsRecreate:
+			if err := b.deleteInstance(ctx, ds, host); err != nil {
+				return err
+			}
+		default:
+			b.adopt(ctx, ds, host, idx, existing)
+			return nil
+		}
+	}
+
+	cl := &b.conf.Clouds[idx]
+	var probes []readiness.Probe
+	if cl.Readiness != nil {
+		var release func()
+		probes, release, err = b.prober.Probes(cl.Readiness, ds)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
+	if err := ds.Create(ctx, host, cl); err != nil {
+		logger.I.Warn(
+			"couldn't create the host",
+			zap.Error(err),
+			zap.Any("host", host),
+			zap.Int("cloud", idx),
+		)
+		return err
+	}
+	b.record(ctx, ds, host, idx)
+
+	if cl.Readiness != nil {
+		return b.waitReady(ctx, ds, host, cl.Readiness, probes)
+	}
+	return nil
+}
+
+// waitReady waits for the probes, and deletes the host on failure if rollback is enabled.
+func (b *Burster) waitReady(
+	ctx context.Context,
+	ds cloud.DataSource,
+	host *config.Host,
+	conf *config.Readiness,
+	probes []readiness.Probe,
+) error {
+	timeout, interval := conf.Timeout, conf.Interval
+	if timeout == 0 {
+		timeout = readiness.DefaultTimeout
+	}
+	if interval == 0 {
+		interval = readiness.DefaultInterval
+	}
+	logger.I.Info("waiting for the host to be ready", zap.String("host", host.Name), zap.Duration("timeout", timeout))
+	err := readiness.Wait(ctx, host, probes, timeout, interval)
+	if err == nil {
+		return nil
+	}
+	logger.I.Error("host is not ready", zap.Error(err), zap.String("host", host.Name))
+	if !conf.Rollback {
+		return err
+	}
+
+	logger.I.Warn("rolling back the host", zap.String("host", host.Name))
+	// The context may be done already
+	rollbackCtx := context.WithoutCancel(ctx)
+	if rErr := b.deleteInstance(rollbackCtx, ds, host); rErr != nil {
+		return errors.Join(err, fmt.Errorf("rollback failed: %w", rErr))
+	}
+	if rErr := b.store.Delete(host.Name); rErr != nil && !errors.Is(rErr, state.ErrNotFound) {
+		logger.I.Error("couldn't delete the record", zap.Error(rErr), zap.String("host", host.Name))
+	}
+	return err
+}
+
+// lock acquires the lock of a host. The returned function releases it.
+func (b *Burster) lock(ctx context.Context, name string) (func(), error) {
+	r, err := lock.Acquire(ctx, b.locker, name, b.lockTimeout)
+	if err != nil {
+		logger.I.Warn("couldn't lock the host", zap.Error(err), zap.String("host", name))
+		return nil, err
+	}
+	return func() {
+		if err := r.Release(); err != nil {
+			logger.I.Error("couldn't release the lock", zap.Error(err), zap.String("host", name))
+		}
+	}, nil
+}
+
+// record stores the instance backing a host after its creation.
+//
+// Failures are logged: the instance exists, whatever happens to its record.
+func (b *Burster) record(ctx context.Context, ds cloud.DataSource, host *config.Host, idx int) {
+	record := b.newRecord(host, idx)
+	instance, err := ds.Get(ctx, host.Name)
+	if err != nil {
+		logger.I.Warn("couldn't fetch the created instance", zap.Error(err), zap.String("host", host.Name))
+	} else {
+		fillRecord(record, instance)
+	}
+	if err := b.store.Put(record); err != nil {
+		logger.I.Error("couldn't record the instance", zap.Error(err), zap.Any("record", record))
+	}
+}
+
+// adopt records an existing instance, unless it is already recorded.
+func (b *Burster) adopt(
+	ctx context.Context,
+	ds cloud.DataSource,
+	host *config.Host,
+	idx int,
+	instance *provider.Instance,
+) {
+	if record, err := b.store.Get(host.Name); err == nil && record.ProviderID == instance.ProviderID {
+		return
+	}
+	b.record(ctx, ds, host, idx)
+}
+
+func (b *Burster) newRecord(host *config.Host, idx int) *state.Record {
+	cl := &b.conf.Clouds[idx]
+	hash, err := state.Hash(host, cl)
+	if err != nil {
+		logger.I.Error("couldn't hash the configuration", zap.Error(err))
+	}
+	return &state.Record{
+		Hostname:   host.Name,
+		Cloud:      idx,
+		CloudType:  cl.Type,
+		CreatedAt:  time.Now(),
+		ConfigHash: hash,
+	}
+}
+
+func fillRecord(record *state.Record, instance *provider.Instance) {
+	record.ProviderID = instance.ProviderID
+	record.Ports = instance.Ports
+	record.Volumes = instance.Volumes
+	if instance.CreatedAt != nil {
+		record.CreatedAt = *instance.CreatedAt
+	}
+}
+
+// Refresh replaces the record of a host with the live instance.
+//
+// The configuration hash of an existing record is kept. If there is no live instance,
+// the record is removed and provider.ErrNotFound is returned.
+func (b *Burster) Refresh(ctx context.Context, hostname string) (*state.Record, error) {
+	host, idx, err := b.Resolve(hostname)
+	if err != nil {
+		return nil, err
+	}
+	ds, err := b.DataSource(idx)
+	if err != nil {
+		return nil, err
+	}
+	release, err := b.lock(ctx, host.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	instance, err := ds.Get(ctx, host.Name)
+	if errors.Is(err, provider.ErrNotFound) {
+		if dErr := b.store.Delete(host.Name); dErr != nil && !errors.Is(dErr, state.ErrNotFound) {
+			return nil, dErr
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	record := b.newRecord(host, idx)
+	if existing, err := b.store.Get(host.Name); err == nil {
+		record.ConfigHash = existing.ConfigHash
+	}
+	fillRecord(record, instance)
+	if err := b.store.Put(record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Delete deletes the host.
+func (b *Burster) Delete(ctx context.Context, hostname string) error {
+	host, idx, err := b.Resolve(hostname)
+	if err != nil {
+		return err
+	}
+	ds, err := b.DataSource(idx)
+	if err != nil {
+		return err
+	}
+	release, err := b.lock(ctx, host.Name)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err := b.deleteInstance(ctx, ds, host); err != nil {
+		logger.I.Error(
+			"couldn't delete the host",
+			zap.Error(err),
+			zap.Any("host", host),
+			zap.Int("cloud", idx),
+		)
+		return err
+	}
+	if err := b.store.Delete(host.Name); err != nil && !errors.Is(err, state.ErrNotFound) {
+		logger.I.Error("couldn't delete the record", zap.Error(err), zap.String("host", host.Name))
+	}
+	return nil
+}
+
+// deleteInstance deletes the instance recorded for a host, or searches it by name
+// if there is no record.
+func (b *Burster) deleteInstance(ctx context.Context, ds cloud.DataSource, host *config.Host) error {
+	record, err := b.store.Get(host.Name)
+	if err != nil && !errors.Is(err, state.ErrNotFound) {
+		logger.I.Warn("couldn't read the record", zap.Error(err), zap.String("host", host.Name))
+	}
+	r, ok := ds.(cloud.Reconciler)
+	if record == nil || record.ProviderID == "" || !ok {
+		return ds.Delete(ctx, host.Name)
+	}
+
+	err = r.DeleteResource(ctx, provider.Resource{
+		Kind: provider.ResourceServer,
+		ID:   record.ProviderID,
+		Name: host.Name,
+	})
+	if errors.Is(err, provider.ErrNotFound) {
+		logger.I.Warn(
+			"recorded instance not found, searching by name",
+			zap.Any("record", record),
+		)
+		return ds.Delete(ctx, host.Name)
+	}
+	return err
+}
+
+// Describe sets the cloud of a host and its recorded provider ID in result.
+func (b *Burster) Describe(hostname string, result *report.Result) {
+	host, idx, err := b.Resolve(hostname)
+	if err != nil {
+		return
+	}
+	result.Cloud = fmt.Sprintf("%d/%s", idx, b.conf.Clouds[idx].Type)
+	if record, err := b.store.Get(host.Name); err == nil {
+		result.ProviderID = record.ProviderID
+	}
+}
+
+// Get returns the live instance of the host.
+func (b *Burster) Get(ctx context.Context, hostname string) (*provider.Instance, error) {
+	host, idx, err := b.Resolve(hostname)
+	if err != nil {
+		return nil, err
+	}
+	ds, err := b.DataSource(idx)
+	if err != nil {
+		return nil, err
+	}
+	return ds.Get(ctx, host.Name)
+}
@@ -0,0 +1,399 @@
+//go:build unit
+
+package burster_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/squarefactory/cloud-burster/pkg/burster"
+	"github.com/squarefactory/cloud-burster/pkg/cloud"
+	"github.com/squarefactory/cloud-burster/pkg/cloud/fake"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/lock"
+	"github.com/squarefactory/cloud-burster/pkg/provider"
+	"github.com/squarefactory/cloud-burster/pkg/readiness"
+	"github.com/squarefactory/cloud-burster/pkg/report"
+	"github.com/squarefactory/cloud-burster/pkg/state"
+	"github.com/stretchr/testify/suite"
+)
+
+var conf = config.Config{
+	SuffixSearch: []string{".example.com"},
+	Clouds: []config.Cloud{
+		{
+			Type: "openstack",
+			Hosts: []config.Host{
+				{Name: "host.example.com", IP: "10.0.0.1"},
+			},
+		},
+		{
+			Type: "exoscale",
+			GroupsHost: []config.GroupHost{
+				{
+					NamePattern: "cn-[1-2]",
+					IPCidr:      "10.0.1.0/24",
+				},
+			},
+		},
+	},
+}
+
+type BursterTestSuite struct {
+	suite.Suite
+	dataSources map[string]*fake.DataSource
+	impl        *burster.Burster
+}
+
+func (suite *BursterTestSuite) BeforeTest(suiteName, testName string) {
+	suite.dataSources = make(map[string]*fake.DataSource)
+	b, err := burster.New(&conf, func(cl *config.Cloud) (cloud.DataSource, error) {
+		ds := &fake.DataSource{}
+		suite.dataSources[cl.Type] = ds
+		return ds, nil
+	})
+	suite.Require().NoError(err)
+	suite.impl = b
+}
+
+func (suite *BursterTestSuite) TestResolve() {
+	tests := []struct {
+		input         string
+		expectedName  string
+		expectedCloud int
+		isError       bool
+		title         string
+	}{
+		{
+			input:         "host",
+			expectedName:  "host.example.com",
+			expectedCloud: 0,
+			title:         "Search with suffix",
+		},
+		{
+			input:         "cn-2",
+			expectedName:  "cn-2",
+			expectedCloud: 1,
+			title:         "Search in groups host",
+		},
+		{
+			input:   "aaa",
+			isError: true,
+			title:   "Not found",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Act
+			host, idx, err := suite.impl.Resolve(tt.input)
+
+			// Assert
+			if tt.isError {
+				suite.ErrorIs(err, burster.ErrHostNotFound)
+			} else {
+				suite.NoError(err)
+				suite.Equal(tt.expectedName, host.Name)
+				suite.Equal(tt.expectedCloud, idx)
+			}
+		})
+	}
+}
+
+func (suite *BursterTestSuite) TestCreateDelete() {
+	// Arrange
+	ctx := context.Background()
+
+	// Act
+	err := suite.impl.Create(ctx, "cn-1")
+	suite.NoError(err)
+	err = suite.impl.Create(ctx, "cn-2")
+	suite.NoError(err)
+	instance, err := suite.impl.Get(ctx, "cn-1")
+
+	// Assert
+	suite.NoError(err)
+	suite.Equal("cn-1", instance.Name)
+	suite.Len(suite.dataSources, 1, "the data source should be shared")
+
+	// Act
+	err = suite.impl.Delete(ctx, "cn-1")
+	suite.NoError(err)
+	_, err = suite.impl.Get(ctx, "cn-1")
+
+	// Assert
+	suite.ErrorIs(err, provider.ErrNotFound)
+}
+
+func (suite *BursterTestSuite) TestRecord() {
+	// Arrange
+	ctx := context.Background()
+	store := suite.impl.Store()
+
+	// Act
+	err := suite.impl.Create(ctx, "cn-1")
+	suite.NoError(err)
+	record, err := store.Get("cn-1")
+
+	// Assert
+	suite.NoError(err)
+	suite.Equal("cn-1", record.ProviderID)
+	suite.Equal(1, record.Cloud)
+	suite.Equal("exoscale", record.CloudType)
+	suite.NotEmpty(record.ConfigHash)
+
+	// Act
+	err = suite.impl.Delete(ctx, "cn-1")
+
+	// Assert
+	suite.NoError(err)
+	suite.Equal([]string{"create cn-1", "delete server cn-1"}, suite.dataSources["exoscale"].Calls)
+	_, err = store.Get("cn-1")
+	suite.ErrorIs(err, state.ErrNotFound)
+}
+
+func (suite *BursterTestSuite) TestDescribe() {
+	// Arrange
+	err := suite.impl.Create(context.Background(), "cn-1")
+	suite.NoError(err)
+	var result report.Result
+
+	// Act
+	suite.impl.Describe("cn-1", &result)
+
+	// Assert
+	suite.Equal("1/exoscale", result.Cloud)
+	suite.Equal("cn-1", result.ProviderID)
+}
+
+func (suite *BursterTestSuite) TestDeleteStaleRecord() {
+	// Arrange
+	ctx := context.Background()
+	err := suite.impl.Create(ctx, "cn-1")
+	suite.NoError(err)
+	ds := suite.dataSources["exoscale"]
+	ds.DeleteResourceErr = map[string]error{"cn-1": provider.ErrNotFound}
+
+	// Act
+	err = suite.impl.Delete(ctx, "cn-1")
+
+	// Assert
+	suite.NoError(err)
+	suite.Equal([]string{"create cn-1", "delete server cn-1", "delete cn-1"}, ds.Calls)
+}
+
+func (suite *BursterTestSuite) TestRefresh() {
+	// Arrange
+	ctx := context.Background()
+	store := suite.impl.Store()
+	err := suite.impl.Create(ctx, "cn-1")
+	suite.NoError(err)
+	ds := suite.dataSources["exoscale"]
+	ds.Instances[0].ProviderID = "new"
+
+	// Act
+	record, err := suite.impl.Refresh(ctx, "cn-1")
+
+	// Assert
+	suite.NoError(err)
+	suite.Equal("new", record.ProviderID)
+
+	// Act
+	ds.Instances = nil
+	_, err = suite.impl.Refresh(ctx, "cn-1")
+
+	// Assert
+	suite.ErrorIs(err, provider.ErrNotFound)
+	_, err = store.Get("cn-1")
+	suite.ErrorIs(err, state.ErrNotFound)
+}
+
+func (suite *BursterTestSuite) TestLocked() {
+	// Arrange
+	ctx := context.Background()
+	locker := lock.NewMemoryLocker()
+	b, err := burster.New(&conf, func(cl *config.Cloud) (cloud.DataSource, error) {
+		return &fake.DataSource{}, nil
+	}, burster.WithLocker(locker, 0))
+	suite.Require().NoError(err)
+	held, err := locker.TryLock("cn-1")
+	suite.Require().NoError(err)
+
+	// Act
+	err = b.Create(ctx, "cn-1")
+
+	// Assert
+	suite.ErrorIs(err, lock.ErrLocked)
+
+	// Act
+	suite.NoError(held.Release())
+	err = b.Create(ctx, "cn-1")
+
+	// Assert
+	suite.NoError(err)
+}
+
+func (suite *BursterTestSuite) TestOnExists() {
+	tests := []struct {
+		input         burster.OnExists
+		existing      provider.State
+		expectedCalls []string
+		expectedErr   error
+		title         string
+	}{
+		{
+			input:         burster.OnExistsSkip,
+			existing:      provider.StateRunning,
+			expectedCalls: []string{},
+			title:         "Skip",
+		},
+		{
+			input:         burster.OnExistsFail,
+			existing:      provider.StateRunning,
+			expectedCalls: []string{},
+			expectedErr:   burster.ErrExists,
+			title:         "Fail",
+		},
+		{
+			input:         burster.OnExistsRecreate,
+			existing:      provider.StateRunning,
+			expectedCalls: []string{"delete cn-1", "create cn-1"},
+			title:         "Recreate",
+		},
+		{
+			input:         burster.OnExistsFail,
+			existing:      provider.StateTerminated,
+			expectedCalls: []string{"create cn-1"},
+			title:         "Ignore terminated instances",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Arrange
+			ds := &fake.DataSource{
+				Instances: []provider.Instance{
+					{Name: "cn-1", ProviderID: "old", State: tt.existing},
+				},
+				Calls: []string{},
+			}
+			b, err := burster.New(&conf, func(cl *config.Cloud) (cloud.DataSource, error) {
+				return ds, nil
+			}, burster.WithOnExists(tt.input))
+			suite.Require().NoError(err)
+
+			// Act
+			err = b.Create(context.Background(), "cn-1")
+
+			// Assert
+			if tt.expectedErr != nil {
+				suite.ErrorIs(err, tt.expectedErr)
+			} else {
+				suite.NoError(err)
+			}
+			suite.Equal(tt.expectedCalls, ds.Calls)
+		})
+	}
+}
+
+func (suite *BursterTestSuite) TestParseOnExists() {
+	// Act
+	policy, err := burster.ParseOnExists("recreate")
+	suite.NoError(err)
+	_, errUnknown := burster.ParseOnExists("adopt")
+
+	// Assert
+	suite.Equal(burster.OnExistsRecreate, policy)
+	suite.Error(errUnknown)
+}
+
+func (suite *BursterTestSuite) TestReadinessRollback() {
+	// Arrange
+	readinessConf := conf
+	readinessConf.Clouds = []config.Cloud{conf.Clouds[0], conf.Clouds[1]}
+	readinessConf.Clouds[1].Readiness = &config.Readiness{
+		Timeout:  200 * time.Millisecond,
+		Interval: 50 * time.Millisecond,
+		Rollback: true,
+		Probes: []config.Probe{
+			{Type: config.ProbeState},
+			{Type: config.ProbePhoneHome, ListenAddress: "127.0.0.1:0"},
+		},
+	}
+	ds := &fake.DataSource{}
+	b, err := burster.New(&readinessConf, func(cl *config.Cloud) (cloud.DataSource, error) {
+		return ds, nil
+	})
+	suite.Require().NoError(err)
+
+	// Act
+	err = b.Create(context.Background(), "cn-1")
+
+	// Assert
+	suite.ErrorIs(err, readiness.ErrTimeout)
+	suite.Equal([]string{"create cn-1", "delete server cn-1"}, ds.Calls)
+	suite.Empty(ds.Instances)
+	_, err = b.Store().Get("cn-1")
+	suite.ErrorIs(err, state.ErrNotFound)
+}
+
+func (suite *BursterTestSuite) TestCreateError() {
+	// Arrange
+	ds, err := suite.impl.DataSource(1)
+	suite.Require().NoError(err)
+	ds.(*fake.DataSource).CreateErr = map[string]error{"cn-1": errors.New("boom")}
+
+	// Act
+	err = suite.impl.Create(context.Background(), "cn-1")
+
+	// Assert
+	suite.ErrorContains(err, "boom")
+}
+
+func (suite *BursterTestSuite) TestList() {
+	// Arrange
+	suite.Require().NoError(suite.impl.Create(context.Background(), "cn-1"))
+
+	tests := []struct {
+		hostnames []string
+		expected  []burster.Row
+		title     string
+	}{
+		{
+			expected: []burster.Row{
+				{Hostname: "host.example.com", Cloud: "0/openstack", IP: "10.0.0.1", State: provider.StateAbsent},
+				{Hostname: "cn-1", Cloud: "1/exoscale", IP: "10.0.1.1", State: provider.StateRunning},
+				{Hostname: "cn-2", Cloud: "1/exoscale", IP: "10.0.1.2", State: provider.StateAbsent},
+			},
+			title: "Every host",
+		},
+		{
+			hostnames: []string{"host", "cn-2"},
+			expected: []burster.Row{
+				{Hostname: "host.example.com", Cloud: "0/openstack", IP: "10.0.0.1", State: provider.StateAbsent},
+				{Hostname: "cn-2", Cloud: "1/exoscale", IP: "10.0.1.2", State: provider.StateAbsent},
+			},
+			title: "Hostnames with a search suffix",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Act
+			rows, err := suite.impl.List(context.Background(), tt.hostnames)
+
+			// Assert
+			suite.NoError(err)
+			for i := range rows {
+				rows[i].Instance = nil
+			}
+			suite.Equal(tt.expected, rows)
+		})
+	}
+}
+
+func TestBursterTestSuite(t *testing.T) {
+	suite.Run(t, &BursterTestSuite{})
+}
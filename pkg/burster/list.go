@@ -0,0 +1,103 @@
+package burster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/squarefactory/cloud-burster/logger"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/provider"
+	"go.uber.org/zap"
+)
+
+// Row is a configured host next to its live instance.
+type Row struct {
+	Hostname string             `json:"hostname"           yaml:"hostname"`
+	Cloud    string             `json:"cloud"              yaml:"cloud"`
+	IP       string             `json:"ip,omitempty"       yaml:"ip,omitempty"`
+	IPv6     string             `json:"ipv6,omitempty"     yaml:"ipv6,omitempty"`
+	State    provider.State     `json:"state"              yaml:"state"`
+	Instance *provider.Instance `json:"instance,omitempty" yaml:"instance,omitempty"`
+}
+
+// List returns the configured hosts matching hostnames, directly or with a search suffix, with
+// their live instance. If hostnames is empty, every host is returned.
+//
+// The hosts of a cloud whose instances cannot be listed stay in the unknown state: the rows are
+// returned along the last listing error.
+func (b *Burster) List(ctx context.Context, hostnames []string) ([]Row, error) {
+	var rows []Row
+	var listErr error
+	for idx := range b.conf.Clouds {
+		cl := &b.conf.Clouds[idx]
+		hosts, err := cl.GenerateHosts()
+		if err != nil {
+			return nil, err
+		}
+		hosts = filterHosts(hosts, hostnames, b.conf.SuffixSearch)
+		if len(hosts) == 0 {
+			continue
+		}
+
+		cloudRows := make([]Row, 0, len(hosts))
+		for _, host := range hosts {
+			cloudRows = append(cloudRows, Row{
+				Hostname: host.Name,
+				Cloud:    fmt.Sprintf("%d/%s", idx, cl.Type),
+				IP:       host.IP,
+				IPv6:     host.IPv6,
+				State:    provider.StateUnknown,
+			})
+		}
+
+		ds, err := b.DataSource(idx)
+		if err != nil {
+			return nil, err
+		}
+		instances, lErr := ds.List(ctx)
+		if lErr != nil {
+			logger.I.Error(
+				"couldn't list the instances",
+				zap.Error(lErr),
+				zap.Int("cloud", idx),
+			)
+			listErr = lErr
+		} else {
+			for i := range cloudRows {
+				instance, gErr := provider.Newest(instances, cloudRows[i].Hostname)
+				if errors.Is(gErr, provider.ErrNotFound) {
+					cloudRows[i].State = provider.StateAbsent
+					continue
+				}
+				cloudRows[i].State = instance.State
+				cloudRows[i].Instance = instance
+			}
+		}
+		rows = append(rows, cloudRows...)
+	}
+	return rows, listErr
+}
+
+// filterHosts keeps the hosts matching a hostname, directly or with a search suffix.
+//
+// If hostnames is empty, every host is kept.
+func filterHosts(hosts []config.Host, hostnames []string, suffixes []string) []config.Host {
+	if len(hostnames) == 0 {
+		return hosts
+	}
+	wanted := make(map[string]struct{}, len(hostnames)*(len(suffixes)+1))
+	for _, hostname := range hostnames {
+		wanted[hostname] = struct{}{}
+		for _, suffix := range suffixes {
+			wanted[hostname+suffix] = struct{}{}
+		}
+	}
+	var out []config.Host
+	for _, host := range hosts {
+		if _, ok := wanted[host.Name]; ok {
+			out = append(out, host)
+		}
+	}
+	return out
+}
@@ -2,13 +2,12 @@ package cloud
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"sync"
 
 	"github.com/squarefactory/cloud-burster/logger"
 	"github.com/squarefactory/cloud-burster/pkg/config"
-	"github.com/squarefactory/cloud-burster/pkg/exoscale"
-	"github.com/squarefactory/cloud-burster/pkg/openstack"
-	"github.com/squarefactory/cloud-burster/pkg/shadow"
+	"github.com/squarefactory/cloud-burster/pkg/provider"
 	"go.uber.org/zap"
 )
 
@@ -22,39 +21,102 @@ type DataSource interface {
 		ctx context.Context,
 		name string,
 	) error
+	// List returns every instance reported by the provider.
+	List(
+		ctx context.Context,
+	) ([]provider.Instance, error)
+	// Get returns the instance named name, or provider.ErrNotFound.
+	Get(
+		ctx context.Context,
+		name string,
+	) (*provider.Instance, error)
 }
 
-func New(conf *config.Cloud) (DataSource, error) {
-	switch conf.Type {
-	case "openstack":
-		return openstack.New(
-			conf.Openstack.IdentityEndpoint,
-			conf.Openstack.UserName,
-			conf.Openstack.Password,
-			conf.Openstack.TenantID,
-			conf.Openstack.TenantName,
-			conf.Openstack.Region,
-			conf.Openstack.DomainID,
-		), nil
-	case "exoscale":
-		return exoscale.New(
-			conf.Exoscale.APIKey,
-			conf.Exoscale.APISecret,
-			conf.Exoscale.Zone,
-		), nil
-	case "shadow":
-		return shadow.New(
-			conf.Shadow.Username,
-			conf.Shadow.Password,
-			conf.Shadow.Zone,
-			conf.Shadow.SSHKey,
-		), nil
+// Reconciler is implemented by data sources able to report and delete
+// resources which no longer back any instance.
+type Reconciler interface {
+	// ListDangling returns the sub-resources (ports, block devices...) left behind by failed
+	// operations on the hosts of the cloud. Each resource is named after its host: resources
+	// which cannot be tied to a configured host are not returned.
+	ListDangling(
+		ctx context.Context,
+		cloud *config.Cloud,
+	) ([]provider.Resource, error)
+	// DeleteResource deletes a single resource, servers included.
+	DeleteResource(
+		ctx context.Context,
+		resource provider.Resource,
+	) error
+}
+
+// Checker is implemented by data sources able to check the configuration against the provider.
+type Checker interface {
+	// Check resolves the images, flavors, networks and subnets referenced by the cloud, and
+	// compares the quotas with the demand of all its hosts. Every problem is returned as a finding.
+	Check(
+		ctx context.Context,
+		cloud *config.Cloud,
+	) ([]provider.Finding, error)
+}
+
+// Provider is a provider of data sources, registered under the type of its clouds.
+type Provider struct {
+	// Description is a one-line description of the provider.
+	Description string
+	// Options returns empty options, which the provider block of a cloud is decoded into and
+	// validated with. It must return a pointer.
+	Options func() config.ProviderOptions
+	// New returns the data source of a cloud, whose Provider holds the decoded options.
+	New func(conf *config.Cloud) (DataSource, error)
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = make(map[string]Provider)
+)
+
+// Register makes a provider available to the clouds of type name, usually from the init
+// function of the provider package.
+//
+// It panics if the provider is already registered or incomplete.
+func Register(name string, p Provider) {
+	if p.Options == nil || p.New == nil {
+		panic("incomplete provider: " + name)
+	}
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	if _, ok := providers[name]; ok {
+		panic("provider already registered: " + name)
 	}
+	config.RegisterSchema(name, p.Options)
+	providers[name] = p
+}
 
-	logger.I.Error(
-		"no cloud associated with the configuration",
-		zap.Any("cloud configuration", conf),
-	)
+// Lookup returns the provider registered as name.
+func Lookup(name string) (Provider, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	p, ok := providers[name]
+	return p, ok
+}
 
-	return nil, errors.New("no cloud associated with the configuration")
+// New returns the data source of the cloud, from the provider registered for its type.
+func New(conf *config.Cloud) (DataSource, error) {
+	p, ok := Lookup(conf.Type)
+	if !ok {
+		logger.I.Error(
+			"no cloud associated with the configuration",
+			zap.String("type", conf.Type),
+			zap.Strings("providers", config.Providers()),
+		)
+		return nil, fmt.Errorf("no provider registered for the type %q", conf.Type)
+	}
+	if conf.Provider == nil {
+		return nil, fmt.Errorf("%s: the provider block is missing", conf.Type)
+	}
+	ds, err := p.New(conf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", conf.Type, err)
+	}
+	return ds, nil
 }
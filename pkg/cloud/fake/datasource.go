@@ -0,0 +1,107 @@
+// Package fake provides an in-memory cloud.DataSource for tests.
+package fake
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/provider"
+)
+
+// DataSource is an in-memory data source.
+//
+// CreateErr and DeleteErr, when set, are returned by Create and Delete for the given host names.
+// DeleteResourceErr is returned by DeleteResource for the given resource IDs.
+type DataSource struct {
+	mu                sync.Mutex
+	Instances         []provider.Instance
+	Dangling          []provider.Resource
+	Deleted           []provider.Resource
+	CreateErr         map[string]error
+	DeleteErr         map[string]error
+	DeleteResourceErr map[string]error
+	Calls             []string
+}
+
+func (f *DataSource) Create(ctx context.Context, host *config.Host, cloud *config.Cloud) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Calls = append(f.Calls, "create "+host.Name)
+	if err := f.CreateErr[host.Name]; err != nil {
+		return err
+	}
+	now := time.Now()
+	ips := []string{host.IP}
+	if host.IPv6 != "" {
+		ips = append(ips, host.IPv6)
+	}
+	f.Instances = append(f.Instances, provider.Instance{
+		Name:       host.Name,
+		ProviderID: host.Name,
+		State:      provider.StateRunning,
+		IPs:        ips,
+		Flavor:     host.FlavorName,
+		Image:      host.ImageName,
+		CreatedAt:  &now,
+		Managed:    true,
+	})
+	return nil
+}
+
+func (f *DataSource) Delete(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Calls = append(f.Calls, "delete "+name)
+	if err := f.DeleteErr[name]; err != nil {
+		return err
+	}
+	for i, instance := range f.Instances {
+		if instance.Name == name {
+			f.Instances = append(f.Instances[:i], f.Instances[i+1:]...)
+			return nil
+		}
+	}
+	return provider.ErrNotFound
+}
+
+func (f *DataSource) List(ctx context.Context) ([]provider.Instance, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]provider.Instance(nil), f.Instances...), nil
+}
+
+func (f *DataSource) Get(ctx context.Context, name string) (*provider.Instance, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return provider.Newest(f.Instances, name)
+}
+
+func (f *DataSource) ListDangling(
+	ctx context.Context,
+	cloud *config.Cloud,
+) ([]provider.Resource, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.Dangling, nil
+}
+
+func (f *DataSource) DeleteResource(ctx context.Context, resource provider.Resource) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Calls = append(f.Calls, "delete "+string(resource.Kind)+" "+resource.ID)
+	if err := f.DeleteResourceErr[resource.ID]; err != nil {
+		return err
+	}
+	f.Deleted = append(f.Deleted, resource)
+	if resource.Kind == provider.ResourceServer {
+		for i, instance := range f.Instances {
+			if instance.ProviderID == resource.ID {
+				f.Instances = append(f.Instances[:i], f.Instances[i+1:]...)
+				break
+			}
+		}
+	}
+	return nil
+}
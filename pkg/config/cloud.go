@@ -1,6 +1,12 @@
 package config
 
-import "github.com/squarefactory/cloud-burster/validate"
+import (
+	"encoding/base64"
+	"fmt"
+	"net/netip"
+
+	"github.com/squarefactory/cloud-burster/validate"
+)
 
 type Cloud struct {
 	AuthorizedKeys []string        `yaml:"authorizedKeys"`
@@ -10,9 +16,12 @@ type Cloud struct {
 	GroupsHost     []GroupHost            `yaml:"groupsHost,omitempty"   validate:"omitempty,dive"`
 	Hosts          []Host                 `yaml:"hosts,omitempty"        validate:"omitempty,dive"`
 	CustomConfig   map[string]interface{} `yaml:"customConfig,omitempty" validate:"omitempty"`
-	*Openstack     `yaml:"openstack,omitempty" validate:"required_if=Type openstack,excluded_unless=Type openstack"`
-	*Exoscale      `yaml:"exoscale,omitempty" validate:"required_if=Type exoscale,excluded_unless=Type exoscale"`
-	*Shadow        `yaml:"shadow,omitempty" validate:"required_if=Type shadow,excluded_unless=Type shadow"`
+	Readiness      *Readiness             `yaml:"readiness,omitempty"    validate:"omitempty"`
+	Retry          *Retry                 `yaml:"retry,omitempty"        validate:"omitempty"`
+	RateLimit      *RateLimit             `yaml:"rateLimit,omitempty"    validate:"omitempty"`
+	// Provider holds the options of the provider of Type, decoded from the provider block by
+	// UnmarshalYAML.
+	Provider ProviderOptions `yaml:"-" validate:"-"`
 }
 
 type PostScriptsOpts struct {
@@ -25,6 +34,199 @@ type GitOpts struct {
 	Ref string `yaml:"ref,omitempty" validate:"omitempty"`
 }
 
+// Validate checks the fields of the cloud, then the options of its provider.
 func (c *Cloud) Validate() error {
-	return validate.I.Struct(c)
+	if err := validate.I.Struct(c); err != nil {
+		return err
+	}
+	return c.validateProvider()
+}
+
+// issues returns the semantic issues of the git options, which are at p.
+func (c *GitOpts) issues(p path) Issues {
+	if c.Key == "" {
+		return nil
+	}
+	if _, err := base64.StdEncoding.DecodeString(c.Key); err != nil {
+		return Issues{newIssue(p.Key("key"), "the key is not valid base64: %s", err)}
+	}
+	return nil
+}
+
+// issues returns the semantic issues of the cloud, which is at p.
+//
+// Every host must have a unique name and IP in the cloud, and its IPs must be in the subnets of
+// the cloud without being its gateways or nameservers.
+func (c *Cloud) issues(p path) Issues {
+	out := c.PostScripts.Git.issues(p.Key("postScripts").Key("git"))
+
+	type located struct {
+		Host
+		at path
+	}
+	hosts := make([]located, 0, len(c.Hosts))
+	for k, host := range c.Hosts {
+		at := p.Key("hosts").Index(k)
+		out = append(out, host.issues(at)...)
+		hosts = append(hosts, located{host, at})
+	}
+	for j, groupsHost := range c.GroupsHost {
+		at := p.Key("groupsHost").Index(j)
+		out = append(out, groupsHost.HostTemplate.issues(at.Key("template"))...)
+		for k, override := range groupsHost.Overrides {
+			// The fields of the host are inlined
+			out = append(out, override.Host.issues(at.Key("overrides").Index(k))...)
+		}
+		generated, err := groupsHost.GenerateHosts()
+		if err == nil {
+			err = c.checkAllocated(generated)
+		}
+		if err != nil {
+			out = append(out, newIssue(at, "%s", err))
+			continue
+		}
+		for _, host := range generated {
+			hosts = append(hosts, located{host, at})
+		}
+	}
+
+	subnets, reserved := c.addressing()
+	names := make(map[string]path, len(hosts))
+	owners := make(map[netip.Addr]string, len(hosts))
+	for idx, host := range hosts {
+		if first, ok := names[host.Name]; ok {
+			out = append(out, newIssue(host.at, "hostname %s is already defined at %s", host.Name, first))
+		} else {
+			names[host.Name] = host.at
+		}
+
+		for _, f := range [][2]string{{"ip", host.IP}, {"ipv6", host.IPv6}} {
+			key, ip := f[0], f[1]
+			addr, err := netip.ParseAddr(ip)
+			if err != nil {
+				// Empty or reported by the validator
+				continue
+			}
+			at := host.at
+			if idx < len(c.Hosts) {
+				at = at.Key(key)
+				// The IPs of the groups are checked by checkAllocated
+				if c.Network != nil && !inSubnets(subnets, addr) {
+					out = append(out, newIssue(at, "IP %s of %s is outside of the subnets %v", addr, host.Name, subnets))
+				}
+				if what, ok := reserved[addr]; ok {
+					out = append(out, newIssue(at, "IP %s of %s is %s", addr, host.Name, what))
+				}
+			}
+			if owner, ok := owners[addr]; ok {
+				out = append(out, newIssue(at, "IP %s of %s is already assigned to %s", addr, host.Name, owner))
+			} else {
+				owners[addr] = host.Name
+			}
+		}
+	}
+	return out
+}
+
+// GenerateHosts returns the static hosts followed by the hosts generated from every group.
+//
+// The IPs allocated to the groups must be in the subnets of the cloud, and must not be its
+// gateways or nameservers. An IP cannot be shared by two hosts.
+func (c *Cloud) GenerateHosts() ([]Host, error) {
+	out := make([]Host, 0, len(c.Hosts))
+	out = append(out, c.Hosts...)
+	for _, groupsHost := range c.GroupsHost {
+		hosts, err := groupsHost.GenerateHosts()
+		if err != nil {
+			return nil, err
+		}
+		if err := c.checkAllocated(hosts); err != nil {
+			return nil, fmt.Errorf("%s: %w", groupsHost.NamePattern, err)
+		}
+		out = append(out, hosts...)
+	}
+
+	owners := make(map[netip.Addr]string, len(out))
+	for _, host := range out {
+		for _, ip := range []string{host.IP, host.IPv6} {
+			if ip == "" {
+				continue
+			}
+			addr, err := netip.ParseAddr(ip)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", host.Name, err)
+			}
+			if owner, ok := owners[addr]; ok {
+				return nil, fmt.Errorf("IP %s is assigned to both %s and %s", addr, owner, host.Name)
+			}
+			owners[addr] = host.Name
+		}
+	}
+	return out, nil
+}
+
+// checkAllocated checks the IPs allocated to a group against the network of the cloud.
+func (c *Cloud) checkAllocated(hosts []Host) error {
+	if c.Network == nil {
+		return nil
+	}
+	for _, s := range []string{c.SubnetCIDR, c.SubnetCIDR6} {
+		if _, err := netip.ParsePrefix(s); s != "" && err != nil {
+			return err
+		}
+	}
+	subnets, reserved := c.addressing()
+
+	for _, host := range hosts {
+		for _, ip := range []string{host.IP, host.IPv6} {
+			if ip == "" {
+				continue
+			}
+			addr, err := netip.ParseAddr(ip)
+			if err != nil {
+				return err
+			}
+			if !inSubnets(subnets, addr) {
+				return fmt.Errorf("IP %s of %s is outside of the subnets %v", addr, host.Name, subnets)
+			}
+			if what, ok := reserved[addr]; ok {
+				return fmt.Errorf("IP %s of %s is %s, add it to excludeIPs", addr, host.Name, what)
+			}
+		}
+	}
+	return nil
+}
+
+// addressing returns the subnets of the network of the cloud, and its reserved addresses.
+// Invalid values are skipped.
+func (c *Cloud) addressing() (subnets []netip.Prefix, reserved map[netip.Addr]string) {
+	reserved = make(map[netip.Addr]string)
+	if c.Network == nil {
+		return nil, reserved
+	}
+	for _, s := range []string{c.SubnetCIDR, c.SubnetCIDR6} {
+		if subnet, err := netip.ParsePrefix(s); err == nil {
+			subnets = append(subnets, subnet)
+		}
+	}
+	for _, gateway := range []string{c.Gateway, c.Gateway6} {
+		if addr, err := netip.ParseAddr(gateway); err == nil {
+			reserved[addr] = "the gateway"
+		}
+	}
+	for _, dns := range c.Nameservers() {
+		if addr, err := netip.ParseAddr(dns); err == nil {
+			reserved[addr] = "a nameserver"
+		}
+	}
+	return subnets, reserved
+}
+
+func inSubnets(subnets []netip.Prefix, addr netip.Addr) bool {
+	for _, subnet := range subnets {
+		if subnet.Contains(addr) {
+			return true
+		}
+	}
+	return false
 }
@@ -3,19 +3,63 @@
 package config_test
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/pem"
 	"testing"
 
 	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/exoscale"
+	"github.com/squarefactory/cloud-burster/pkg/openstack"
+	"github.com/squarefactory/cloud-burster/pkg/shadow"
 	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/ssh"
 )
 
+var cleanOpenstack = openstack.Options{
+	IdentityEndpoint: "https://auth.cloud.ovh.net/",
+	UserName:         "user",
+	Password:         "password",
+	TenantID:         "tenantID",
+	TenantName:       "tenantName",
+	DomainID:         "default",
+	Region:           "GRA9",
+}
+
+var cleanExoscale = exoscale.Options{
+	APIKey:    "key",
+	APISecret: "secret",
+	Zone:      "zone",
+}
+
+var cleanShadow = shadow.Options{
+	Username: "username",
+	Password: "password",
+	Zone:     "zone",
+	SSHKey:   generateSSHKey(),
+}
+
+// generateSSHKey returns a new base64-encoded OpenSSH private key.
+func generateSSHKey() string {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		panic(err)
+	}
+	return base64.StdEncoding.EncodeToString(pem.EncodeToMemory(block))
+}
+
 var cleanOpenstackCloud = config.Cloud{
 	AuthorizedKeys: []string{
 		"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIDUnXMBGq6bV6H+c7P5QjDn1soeB6vkodi6OswcZsMwH nguye@PC-DARKNESS4",
 	},
 	PostScripts: config.PostScriptsOpts{
 		Git: config.GitOpts{
-			Key: "key",
+			Key: "a2V5",
 			URL: "git@github.com:SquareFactory/compute-configs.git",
 			Ref: "main",
 		},
@@ -35,8 +79,8 @@ var cleanOpenstackCloud = config.Cloud{
 			},
 		},
 	},
-	Type:      "openstack",
-	Openstack: &cleanOpenstack,
+	Type:     "openstack",
+	Provider: &cleanOpenstack,
 }
 
 var cleanExoscaleCloud = config.Cloud{
@@ -45,7 +89,7 @@ var cleanExoscaleCloud = config.Cloud{
 	},
 	PostScripts: config.PostScriptsOpts{
 		Git: config.GitOpts{
-			Key: "key",
+			Key: "a2V5",
 			URL: "git@github.com:SquareFactory/compute-configs.git",
 			Ref: "main",
 		},
@@ -66,13 +110,13 @@ var cleanExoscaleCloud = config.Cloud{
 		},
 	},
 	Type:     "exoscale",
-	Exoscale: &cleanExoscale,
+	Provider: &cleanExoscale,
 }
 
 var cleanShadowCloud = config.Cloud{
 	PostScripts: config.PostScriptsOpts{
 		Git: config.GitOpts{
-			Key: "key",
+			Key: "a2V5",
 			URL: "git@github.com:SquareFactory/compute-configs.git",
 			Ref: "main",
 		},
@@ -90,7 +134,7 @@ var cleanShadowCloud = config.Cloud{
 	GroupsHost:   nil,
 	CustomConfig: nil,
 	Type:         "shadow",
-	Shadow:       &cleanShadow,
+	Provider:     &cleanShadow,
 }
 
 type CloudTestSuite struct {
@@ -120,15 +164,16 @@ func (suite *CloudTestSuite) TestValidate() {
 				GroupsHost: []config.GroupHost{
 					{},
 				},
-				Openstack: cleanOpenstackCloud.Openstack,
-				Type:      cleanOpenstackCloud.Type,
+				Provider: cleanOpenstackCloud.Provider,
+				Type:     cleanOpenstackCloud.Type,
 			},
 			title: "GroupsHost valid",
 		},
 		{
 			isError: true,
 			errorContains: []string{
-				"Openstack",
+				"IdentityEndpoint",
+				"url",
 			},
 			input: &config.Cloud{
 				AuthorizedKeys: cleanOpenstackCloud.AuthorizedKeys,
@@ -136,7 +181,7 @@ func (suite *CloudTestSuite) TestValidate() {
 				Network:        cleanOpenstackCloud.Network,
 				GroupsHost:     cleanOpenstackCloud.GroupsHost,
 				Type:           cleanOpenstackCloud.Type,
-				Openstack: &config.Openstack{
+				Provider: &openstack.Options{
 					IdentityEndpoint: "aaa",
 				},
 			},
@@ -145,8 +190,7 @@ func (suite *CloudTestSuite) TestValidate() {
 		{
 			isError: true,
 			errorContains: []string{
-				"required_if",
-				"Openstack",
+				"the provider block is missing",
 			},
 			input: &config.Cloud{
 				AuthorizedKeys: cleanOpenstackCloud.AuthorizedKeys,
@@ -155,13 +199,12 @@ func (suite *CloudTestSuite) TestValidate() {
 				GroupsHost:     cleanOpenstackCloud.GroupsHost,
 				Type:           "openstack",
 			},
-			title: "If type == openstack, openstack is required",
+			title: "If type == openstack, the provider block is required",
 		},
 		{
 			isError: true,
 			errorContains: []string{
-				"required_if",
-				"Exoscale",
+				"the provider block is missing",
 			},
 			input: &config.Cloud{
 				AuthorizedKeys: cleanOpenstackCloud.AuthorizedKeys,
@@ -170,7 +213,23 @@ func (suite *CloudTestSuite) TestValidate() {
 				GroupsHost:     cleanOpenstackCloud.GroupsHost,
 				Type:           "exoscale",
 			},
-			title: "If type == exoscale, exoscale is required",
+			title: "If type == exoscale, the provider block is required",
+		},
+		{
+			isError: true,
+			errorContains: []string{
+				`unknown provider "aws"`,
+				"exoscale, openstack, shadow",
+			},
+			input: &config.Cloud{
+				AuthorizedKeys: cleanOpenstackCloud.AuthorizedKeys,
+				PostScripts:    cleanOpenstackCloud.PostScripts,
+				Network:        cleanOpenstackCloud.Network,
+				GroupsHost:     cleanOpenstackCloud.GroupsHost,
+				Type:           "aws",
+				Provider:       cleanOpenstackCloud.Provider,
+			},
+			title: "Unknown provider",
 		},
 	}
 
@@ -192,6 +251,155 @@ func (suite *CloudTestSuite) TestValidate() {
 	}
 }
 
+func (suite *CloudTestSuite) TestGenerateHosts() {
+	// Arrange
+	cloud := config.Cloud{
+		Hosts: []config.Host{cleanHost},
+		GroupsHost: []config.GroupHost{
+			{
+				NamePattern:  "cn-[1-2]",
+				IPCidr:       "172.28.0.0/20",
+				IPOffset:     256,
+				HostTemplate: cleanHost,
+			},
+		},
+	}
+	expected := []config.Host{
+		cleanHost,
+		{
+			Name:       "cn-1",
+			DiskSize:   cleanHost.DiskSize,
+			FlavorName: cleanHost.FlavorName,
+			ImageName:  cleanHost.ImageName,
+			IP:         "172.28.1.1",
+		},
+		{
+			Name:       "cn-2",
+			DiskSize:   cleanHost.DiskSize,
+			FlavorName: cleanHost.FlavorName,
+			ImageName:  cleanHost.ImageName,
+			IP:         "172.28.1.2",
+		},
+	}
+
+	// Act
+	actual, err := cloud.GenerateHosts()
+
+	// Assert
+	suite.NoError(err)
+	suite.Equal(expected, actual)
+}
+
+func (suite *CloudTestSuite) TestGenerateHostsConflicts() {
+	tests := []struct {
+		input         config.Cloud
+		errorContains string
+		title         string
+	}{
+		{
+			input: config.Cloud{
+				Network: &cleanNetwork,
+				GroupsHost: []config.GroupHost{
+					{NamePattern: "cn-[1-2]", IPCidr: "172.29.0.0/24", HostTemplate: cleanHost},
+				},
+			},
+			errorContains: "outside of the subnet",
+			title:         "Outside of the subnet",
+		},
+		{
+			input: config.Cloud{
+				Network: &cleanNetwork,
+				GroupsHost: []config.GroupHost{
+					{NamePattern: "cn-[1-2]", IPCidr: "172.28.0.0/24", HostTemplate: cleanHost},
+				},
+			},
+			errorContains: "is the gateway",
+			title:         "Gateway",
+		},
+		{
+			input: config.Cloud{
+				Hosts: []config.Host{
+					{Name: "login", IP: "172.28.1.2"},
+				},
+				GroupsHost: []config.GroupHost{
+					{NamePattern: "cn-[1-2]", IPs: []string{"172.28.1.1-172.28.1.2"}, HostTemplate: cleanHost},
+				},
+			},
+			errorContains: "assigned to both login and cn-2",
+			title:         "Shared with a static host",
+		},
+		{
+			input: config.Cloud{
+				GroupsHost: []config.GroupHost{
+					{NamePattern: "a-[1-2]", IPCidr: "172.28.1.0/24", HostTemplate: cleanHost},
+					{NamePattern: "b-[1-2]", IPCidr: "172.28.1.0/24", IPOffset: 1, HostTemplate: cleanHost},
+				},
+			},
+			errorContains: "assigned to both a-2 and b-1",
+			title:         "Shared between groups",
+		},
+		{
+			input: config.Cloud{
+				Network: &cleanNetwork,
+				GroupsHost: []config.GroupHost{
+					{
+						NamePattern:  "cn-[1-2]",
+						IPCidr:       "172.28.1.0/24",
+						IPv6Cidr:     "2001:db8::/64",
+						HostTemplate: cleanHost,
+					},
+				},
+			},
+			errorContains: "IP 2001:db8::1 of cn-1 is outside of the subnets",
+			title:         "IPv6 without IPv6 subnet",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Act
+			_, err := tt.input.GenerateHosts()
+
+			// Assert
+			suite.ErrorContains(err, tt.errorContains)
+		})
+	}
+}
+
+func (suite *CloudTestSuite) TestDemand() {
+	// Arrange
+	cl := config.Cloud{
+		Network: &cleanNetwork,
+		Hosts: []config.Host{
+			{Name: "login", DiskSize: 20, FlavorName: "small", ImageName: "rocky", IP: "172.28.15.254"},
+		},
+		GroupsHost: []config.GroupHost{
+			{
+				NamePattern:  "cn-[1-3]",
+				IPCidr:       "172.28.1.0/24",
+				HostTemplate: config.Host{DiskSize: 50, GPU: 1, FlavorName: "gpu", ImageName: "rocky"},
+				Overrides: []config.HostOverride{
+					{NamePattern: "cn-3", Host: config.Host{ImageName: "ubuntu"}},
+				},
+			},
+		},
+	}
+
+	// Act
+	actual, err := cl.Demand()
+
+	// Assert
+	suite.NoError(err)
+	suite.Equal(&config.Demand{
+		Hosts:    4,
+		Images:   map[string]int{"rocky": 3, "ubuntu": 1},
+		Flavors:  map[string]int{"small": 1, "gpu": 3},
+		DiskSize: 170,
+		GPU:      3,
+	}, actual)
+	suite.Equal([]string{"rocky", "ubuntu"}, config.Names(actual.Images))
+}
+
 func TestCloudTestSuite(t *testing.T) {
 	suite.Run(t, &CloudTestSuite{})
 }
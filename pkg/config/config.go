@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"reflect"
 	"strings"
 
 	"github.com/go-playground/validator/v10"
@@ -30,12 +31,41 @@ type Config struct {
 	APIVersion   string   `yaml:"apiVersion"   validate:"equalAPI"`
 	Clouds       []Cloud  `yaml:"clouds"       validate:"dive"`
 	SuffixSearch []string `yaml:"suffixSearch"`
+
+	// source is the parsed document, used to locate the issues.
+	source *yaml.Node
 }
 
+// Validate checks the fields of the configuration, then its consistency: IPs in the subnets,
+// unique hostnames and IPs in each cloud, decodable keys, and the options of the providers.
+//
+// Every issue is returned in Issues, with its line if the configuration was parsed from a file.
 func (c *Config) Validate() error {
-	return validate.I.Struct(c)
+	var issues Issues
+	if err := validate.I.Struct(c); err != nil {
+		converted, err := issuesOf(err, reflect.TypeOf(c), nil)
+		if err != nil {
+			return err
+		}
+		issues = append(issues, converted...)
+	}
+	for i := range c.Clouds {
+		p := path{"clouds", i}
+		issues = append(issues, c.Clouds[i].providerIssues(p, c.source)...)
+		issues = append(issues, c.Clouds[i].issues(p)...)
+	}
+	if len(issues) == 0 {
+		return nil
+	}
+	for i := range issues {
+		issues[i].Line = issues[i].at.line(c.source)
+	}
+	return issues
 }
 
+// SearchHostByHostName returns the first host named hostname, and its cloud.
+//
+// Deprecated: groups are expanded on each call, and duplicates are not detected. Use Resolver.
 func (c *Config) SearchHostByHostName(hostname string) (*Host, *Cloud, error) {
 	var found bool
 	var foundCloud *Cloud
@@ -92,22 +122,15 @@ func (c *Config) GenerateHosts() (string, error) {
 	// Search in all clouds
 	for _, cloud := range c.Clouds {
 		logger.I.Debug("found cloud", zap.Any("cloud", cloud))
-		// Search in all hosts
-		for _, host := range cloud.Hosts {
+		hosts, err := cloud.GenerateHosts()
+		if err != nil {
+			return "", err
+		}
+		for _, host := range hosts {
 			logger.I.Debug("found host", zap.Any("host", host))
 			sb.WriteString(fmt.Sprintf("%s %s\n", host.IP, host.Name))
-		}
-
-		// Search in all groups host
-		for _, groupsHost := range cloud.GroupsHost {
-			logger.I.Debug("found groupsHost", zap.Any("groupsHost", groupsHost))
-			hosts, err := groupsHost.GenerateHosts()
-			if err != nil {
-				return "", err
-			}
-			for _, host := range hosts {
-				logger.I.Debug("found host", zap.Any("host", host))
-				sb.WriteString(fmt.Sprintf("%s %s\n", host.IP, host.Name))
+			if host.IPv6 != "" {
+				sb.WriteString(fmt.Sprintf("%s %s\n", host.IPv6, host.Name))
 			}
 		}
 	}
@@ -120,10 +143,18 @@ func ParseFile(filePath string) (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
 	config := &Config{}
-	err = yaml.Unmarshal(data, config)
-	if err != nil {
+	if node.Kind == 0 {
+		// Empty document
+		return config, nil
+	}
+	if err := node.Decode(config); err != nil {
 		return nil, err
 	}
+	config.source = &node
 	return config, nil
 }
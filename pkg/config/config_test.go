@@ -3,10 +3,13 @@
 package config_test
 
 import (
+	"errors"
+	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/shadow"
 	"github.com/squarefactory/cloud-burster/utils/projectpath"
 	"github.com/stretchr/testify/suite"
 )
@@ -50,7 +53,8 @@ func (suite *ConfigTestSuite) TestValidate() {
 		{
 			isError: true,
 			errorContains: []string{
-				"Cloud",
+				"clouds[0].type",
+				"'required' tag",
 			},
 			input: &config.Config{
 				APIVersion: config.APIVersion,
@@ -80,18 +84,101 @@ func (suite *ConfigTestSuite) TestValidate() {
 	}
 }
 
+func (suite *ConfigTestSuite) TestValidateIssues() {
+	// Arrange
+	content := `apiVersion: 'cloud-burster.squarefactory.io/v1alpha1'
+clouds:
+  - type: openstack
+    network:
+      name: 'net'
+      subnetCIDR: '172.28.0.0/20'
+      dns: 1.1.1.1
+      gateway: 172.28.0.2
+    hosts:
+      - name: 'host'
+        diskSize: -1
+        flavorName: 'd2-2'
+        imageName: 'Rocky Linux 9'
+        ip: 172.28.16.254
+      - name: 'cn-2'
+        diskSize: 50
+        flavorName: 'd2-2'
+        imageName: 'Rocky Linux 9'
+        ip: 172.28.1.1
+    groupsHost:
+      - namePattern: cn-[1-2]
+        ipCIDR: 172.28.0.0/20
+        ipOffset: 256
+        template:
+          flavorName: 'd2-2'
+          imageName: 'Rocky Linux 9'
+    postScripts:
+      git:
+        key: 'not base64'
+    provider:
+      identityEndpoint: auth.cloud.ovh.net
+      username: user
+      password: password
+      region: GRA9
+      tenantID: tenantID
+      tenantName: 'tenantName'
+      domainID: default
+  - type: shadow
+    shadow:
+      username: username
+      password: password
+      zone: zone
+  - type: aws
+    provider:
+      region: eu-west-1
+`
+	file := filepath.Join(suite.T().TempDir(), "config.yaml")
+	suite.Require().NoError(os.WriteFile(file, []byte(content), 0o600))
+	conf, err := config.ParseFile(file)
+	suite.Require().NoError(err)
+	expected := []string{
+		"line 25: clouds[0].groupsHost[0].template.diskSize: field validation for 'DiskSize' failed on the 'required' tag",
+		"line 11: clouds[0].hosts[0].diskSize: must be positive, got -1",
+		"line 14: clouds[0].hosts[0].ip: IP 172.28.16.254 of host is outside of the subnets [172.28.0.0/20]",
+		"line 21: clouds[0].groupsHost[0]: IP 172.28.1.1 of cn-1 is already assigned to cn-2",
+		"line 21: clouds[0].groupsHost[0]: hostname cn-2 is already defined at clouds[0].hosts[1]",
+		"line 29: clouds[0].postScripts.git.key: the key is not valid base64: illegal base64 data at input byte 3",
+		"line 31: clouds[0].provider.identityEndpoint: field validation for 'IdentityEndpoint' failed on the 'url' tag",
+		"line 40: clouds[1].shadow.sshKey: the private key is empty",
+		`line 43: clouds[2].type: unknown provider "aws", expected one of: exoscale, openstack, shadow`,
+	}
+
+	// Act
+	err = conf.Validate()
+
+	// Assert
+	var issues config.Issues
+	suite.Require().True(errors.As(err, &issues))
+	actual := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		actual = append(actual, issue.String())
+	}
+	suite.ElementsMatch(expected, actual)
+}
+
 func (suite *ConfigTestSuite) TestParseFile() {
 	// Arrange
 	expected := &cleanConfig
 
 	// Act
 	config, err := config.ParseFile(filepath.Join(projectpath.Root, "config.yaml.example"))
-	suite.NoError(err)
+	suite.Require().NoError(err)
+	// The example holds a placeholder instead of a private key
+	options := config.Clouds[2].Provider.(*shadow.Options)
+	suite.Equal("<base64 of your private key>", options.SSHKey)
+	options.SSHKey = cleanShadow.SSHKey
 	err = config.Validate()
 
 	// Assert
 	suite.NoError(err)
-	suite.Equal(expected, config)
+	suite.Equal(expected.APIVersion, config.APIVersion)
+	suite.Equal(expected.Clouds, config.Clouds)
+	suite.Equal(expected.SuffixSearch, config.SuffixSearch)
 }
 
 func (suite *ConfigTestSuite) TestSearchHostByHostName() {
@@ -155,8 +242,7 @@ func (suite *ConfigTestSuite) TestGenerateHosts() {
 				AuthorizedKeys: cleanConfig.Clouds[0].AuthorizedKeys,
 				PostScripts:    cleanConfig.Clouds[0].PostScripts,
 				Network:        cleanConfig.Clouds[0].Network,
-				Openstack:      cleanConfig.Clouds[0].Openstack,
-				Exoscale:       cleanConfig.Clouds[0].Exoscale,
+				Provider:       cleanConfig.Clouds[0].Provider,
 				Type:           cleanConfig.Clouds[0].Type,
 				GroupsHost: []config.GroupHost{
 					{
@@ -176,14 +262,14 @@ func (suite *ConfigTestSuite) TestGenerateHosts() {
 						DiskSize:   50,
 						FlavorName: "flavor",
 						ImageName:  "image",
-						IP:         "10.10.10.10",
+						IP:         "172.28.10.10",
 					},
 				},
 			},
 		},
 	}
 	err := conf.Validate()
-	expected := `10.10.10.10 cn-test
+	expected := `172.28.10.10 cn-test
 172.28.1.1 cn-1
 172.28.1.2 cn-2
 172.28.1.3 cn-3
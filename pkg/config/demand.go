@@ -0,0 +1,47 @@
+package config
+
+import "sort"
+
+// Demand sums up the resources referenced by the hosts of a cloud, groups included.
+type Demand struct {
+	// Hosts is the number of hosts.
+	Hosts int
+	// Images counts the hosts by image name.
+	Images map[string]int
+	// Flavors counts the hosts by flavor name.
+	Flavors map[string]int
+	// DiskSize is the total disk size.
+	DiskSize int
+	// GPU is the total number of GPUs.
+	GPU int
+}
+
+// Demand returns the resources needed to create every host of the cloud at once.
+func (c *Cloud) Demand() (*Demand, error) {
+	hosts, err := c.GenerateHosts()
+	if err != nil {
+		return nil, err
+	}
+	out := &Demand{
+		Hosts:   len(hosts),
+		Images:  make(map[string]int),
+		Flavors: make(map[string]int),
+	}
+	for _, host := range hosts {
+		out.Images[host.ImageName]++
+		out.Flavors[host.FlavorName]++
+		out.DiskSize += host.DiskSize
+		out.GPU += host.GPU
+	}
+	return out, nil
+}
+
+// Names returns the sorted keys of a count by name, like Demand.Images.
+func Names(counts map[string]int) []string {
+	out := make([]string, 0, len(counts))
+	for name := range counts {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
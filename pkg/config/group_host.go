@@ -1,7 +1,8 @@
 package config
 
 import (
-	"errors"
+	"fmt"
+	"net/netip"
 
 	"github.com/squarefactory/cloud-burster/logger"
 	"github.com/squarefactory/cloud-burster/utils/cidr"
@@ -14,41 +15,101 @@ type GroupHost struct {
 	// NamePattern overrides the host name
 	NamePattern string `yaml:"namePattern" validate:"required"`
 	// IPCidr overrides the IP. Based on NamePattern, each host will have an IP allocated.
-	IPCidr string `yaml:"ipCIDR"      validate:"required,cidr"`
+	// The network and broadcast addresses are never allocated.
+	IPCidr string `yaml:"ipCIDR,omitempty" validate:"required_without=IPs,excluded_with=IPs,omitempty,cidr"`
+	// IPs is an explicit pool of addresses, ranges (first-last) or CIDRs, used instead of IPCidr.
+	IPs []string `yaml:"ips,omitempty" validate:"omitempty"`
 	// IPOffset offsets the selection of IP.
-	IPOffset int `yaml:"ipOffset"    validate:"omitempty"`
+	IPOffset int `yaml:"ipOffset"    validate:"omitempty,min=0"`
+	// IPv6Cidr is the pool of the IPv6 addresses of a dual-stack group, without the subnet-router anycast address.
+	IPv6Cidr string `yaml:"ipv6CIDR,omitempty" validate:"omitempty,cidrv6"`
+	// IPv6Offset offsets the selection of IPv6.
+	IPv6Offset int `yaml:"ipv6Offset,omitempty" validate:"omitempty,min=0"`
+	// ExcludeIPs are never allocated: addresses, ranges or CIDRs, like the gateway or reserved addresses.
+	ExcludeIPs []string `yaml:"excludeIPs,omitempty" validate:"omitempty"`
 	// HostTemplate defines helps to define a Host
 	HostTemplate Host `yaml:"template"`
+	// Overrides are merged on top of HostTemplate, in order, for the hosts matching their pattern.
+	Overrides []HostOverride `yaml:"overrides,omitempty" validate:"omitempty,dive"`
+}
+
+// HostOverride replaces the fields of the template of a group for some of its hosts.
+type HostOverride struct {
+	// NamePattern selects the hosts of the group.
+	NamePattern string `yaml:"namePattern" validate:"required"`
+	// Host holds the replaced fields. Name, IP and IPv6 are ignored.
+	Host `yaml:",inline" validate:"-"`
+}
+
+// matches returns the set of names selected by the override.
+func (o *HostOverride) matches() (map[string]bool, error) {
+	names, err := generators.Expand(o.NamePattern)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]bool, len(names))
+	for _, name := range names {
+		out[name] = true
+	}
+	return out, nil
 }
 
 func (g *GroupHost) GenerateHosts() ([]Host, error) {
 	var out []Host
 
 	// Generates names based on Name Pattern
-	names := generators.ExpandBrackets(g.NamePattern)
+	names, err := generators.Expand(g.NamePattern)
+	if err != nil {
+		return []Host{}, err
+	}
 
 	// Generates IPs
-	ipAddresses := cidr.Hosts(g.IPCidr)
-
-	if len(names) > len(ipAddresses) {
+	ipAddresses, err := g.allocate(len(names))
+	if err != nil {
 		logger.I.Error(
-			"not enough IP addresses in CIDR",
+			"couldn't allocate the IP addresses",
+			zap.Error(err),
 			zap.String("namePattern", g.NamePattern),
 			zap.Int("len(namePattern)", len(names)),
-			zap.String("ipCIDR", g.IPCidr),
-			zap.Int("len(ipAddresses)", len(ipAddresses)),
 		)
-		return []Host{}, errors.New("not enough IP addresses in CIDR")
+		return []Host{}, fmt.Errorf("%s: %w", g.NamePattern, err)
+	}
+	var ipv6Addresses []netip.Addr
+	if g.IPv6Cidr != "" {
+		ipv6Addresses, err = g.allocate6(len(names))
+		if err != nil {
+			logger.I.Error(
+				"couldn't allocate the IPv6 addresses",
+				zap.Error(err),
+				zap.String("namePattern", g.NamePattern),
+				zap.Int("len(namePattern)", len(names)),
+			)
+			return []Host{}, fmt.Errorf("%s: %w", g.NamePattern, err)
+		}
+	}
+
+	overrides := make([]map[string]bool, 0, len(g.Overrides))
+	for i := range g.Overrides {
+		matches, err := g.Overrides[i].matches()
+		if err != nil {
+			return []Host{}, fmt.Errorf("%s: override: %w", g.NamePattern, err)
+		}
+		overrides = append(overrides, matches)
 	}
 
 	// Map the names into host
 	for idx, name := range names {
-		host := Host{
-			Name:       name,
-			DiskSize:   g.HostTemplate.DiskSize,
-			FlavorName: g.HostTemplate.FlavorName,
-			ImageName:  g.HostTemplate.ImageName,
-			IP:         ipAddresses[idx+g.IPOffset],
+		host := g.HostTemplate
+		for i, matches := range overrides {
+			if matches[name] {
+				host = host.Merge(g.Overrides[i].Host)
+			}
+		}
+		host.Name = name
+		host.IP = ipAddresses[idx].String()
+		host.IPv6 = ""
+		if ipv6Addresses != nil {
+			host.IPv6 = ipv6Addresses[idx].String()
 		}
 		out = append(out, host)
 	}
@@ -56,6 +117,41 @@ func (g *GroupHost) GenerateHosts() ([]Host, error) {
 	return out, nil
 }
 
+// allocate returns n addresses of the pool of the group.
+func (g *GroupHost) allocate(n int) ([]netip.Addr, error) {
+	var pool []cidr.Range
+	if len(g.IPs) > 0 {
+		var err error
+		if pool, err = cidr.ParsePool(g.IPs); err != nil {
+			return nil, err
+		}
+	} else {
+		prefix, err := netip.ParsePrefix(g.IPCidr)
+		if err != nil {
+			return nil, err
+		}
+		pool = []cidr.Range{cidr.Hosts(prefix)}
+	}
+	exclude, err := cidr.ParseRanges(g.ExcludeIPs)
+	if err != nil {
+		return nil, err
+	}
+	return cidr.Allocate(pool, exclude, g.IPOffset, n)
+}
+
+// allocate6 returns n addresses of the IPv6 pool of the group.
+func (g *GroupHost) allocate6(n int) ([]netip.Addr, error) {
+	prefix, err := netip.ParsePrefix(g.IPv6Cidr)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := cidr.ParseRanges(g.ExcludeIPs)
+	if err != nil {
+		return nil, err
+	}
+	return cidr.Allocate([]cidr.Range{cidr.Hosts(prefix)}, exclude, g.IPv6Offset, n)
+}
+
 func (g *GroupHost) Validate() error {
 	return validate.I.Struct(g)
 }
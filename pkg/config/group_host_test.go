@@ -7,6 +7,7 @@ import (
 
 	"github.com/squarefactory/cloud-burster/pkg/config"
 	"github.com/stretchr/testify/suite"
+	"gopkg.in/yaml.v3"
 )
 
 var cleanGroupHost = config.GroupHost{
@@ -88,6 +89,20 @@ func (suite *GroupHostTestSuite) TestValidate() {
 			},
 			title: "hostTemplate valid",
 		},
+		{
+			isError: true,
+			errorContains: []string{
+				"IPCidr",
+				"excluded_with",
+			},
+			input: &config.GroupHost{
+				NamePattern:  cleanGroupHost.NamePattern,
+				IPCidr:       cleanGroupHost.IPCidr,
+				IPs:          []string{"172.28.0.10"},
+				HostTemplate: cleanGroupHost.HostTemplate,
+			},
+			title: "ipCIDR and ips exclusive",
+		},
 	}
 
 	for _, tt := range tests {
@@ -167,6 +182,143 @@ func (suite *GroupHostTestSuite) TestGenerateHosts() {
 			},
 			title: "Positive test",
 		},
+		{
+			input: config.GroupHost{
+				NamePattern: "cn[1-3]",
+				IPCidr:      "172.20.0.0/24",
+				HostTemplate: config.Host{
+					DiskSize:   50,
+					RAM:        8,
+					GPU:        1,
+					FlavorName: "flavor",
+					ImageName:  "image",
+				},
+				Overrides: []config.HostOverride{
+					{
+						NamePattern: "cn[2-3]",
+						Host:        config.Host{FlavorName: "big", Name: "ignored"},
+					},
+					{
+						NamePattern: "cn3",
+						Host:        config.Host{ImageName: "other", GPU: 2},
+					},
+				},
+			},
+			expected: []config.Host{
+				{
+					Name:       "cn1",
+					DiskSize:   50,
+					RAM:        8,
+					GPU:        1,
+					FlavorName: "flavor",
+					ImageName:  "image",
+					IP:         "172.20.0.1",
+				},
+				{
+					Name:       "cn2",
+					DiskSize:   50,
+					RAM:        8,
+					GPU:        1,
+					FlavorName: "big",
+					ImageName:  "image",
+					IP:         "172.20.0.2",
+				},
+				{
+					Name:       "cn3",
+					DiskSize:   50,
+					RAM:        8,
+					GPU:        2,
+					FlavorName: "big",
+					ImageName:  "other",
+					IP:         "172.20.0.3",
+				},
+			},
+			title: "Full template and overrides",
+		},
+		{
+			input: config.GroupHost{
+				NamePattern:  "cn[1-3]",
+				IPs:          []string{"172.20.0.10-172.20.0.12", "172.20.1.0/30"},
+				ExcludeIPs:   []string{"172.20.0.11", "172.20.0.12"},
+				HostTemplate: hostTemplate,
+			},
+			expected: []config.Host{
+				{
+					Name:       "cn1",
+					DiskSize:   hostTemplate.DiskSize,
+					FlavorName: hostTemplate.FlavorName,
+					ImageName:  hostTemplate.ImageName,
+					IP:         "172.20.0.10",
+				},
+				{
+					Name:       "cn2",
+					DiskSize:   hostTemplate.DiskSize,
+					FlavorName: hostTemplate.FlavorName,
+					ImageName:  hostTemplate.ImageName,
+					IP:         "172.20.1.1",
+				},
+				{
+					Name:       "cn3",
+					DiskSize:   hostTemplate.DiskSize,
+					FlavorName: hostTemplate.FlavorName,
+					ImageName:  hostTemplate.ImageName,
+					IP:         "172.20.1.2",
+				},
+			},
+			title: "Explicit IPs and exclusions",
+		},
+		{
+			input: config.GroupHost{
+				NamePattern:  "cn[1-2]",
+				IPCidr:       "172.20.0.0/24",
+				IPv6Cidr:     "2001:db8::/64",
+				IPv6Offset:   15,
+				HostTemplate: hostTemplate,
+			},
+			expected: []config.Host{
+				{
+					Name:       "cn1",
+					DiskSize:   hostTemplate.DiskSize,
+					FlavorName: hostTemplate.FlavorName,
+					ImageName:  hostTemplate.ImageName,
+					IP:         "172.20.0.1",
+					IPv6:       "2001:db8::10",
+				},
+				{
+					Name:       "cn2",
+					DiskSize:   hostTemplate.DiskSize,
+					FlavorName: hostTemplate.FlavorName,
+					ImageName:  hostTemplate.ImageName,
+					IP:         "172.20.0.2",
+					IPv6:       "2001:db8::11",
+				},
+			},
+			title: "Dual stack",
+		},
+		{
+			input: config.GroupHost{
+				NamePattern:  "cn[1-2]",
+				IPCidr:       "172.20.0.0/24",
+				IPOffset:     300,
+				HostTemplate: hostTemplate,
+			},
+			isError: true,
+			errorContains: []string{
+				"not enough IP",
+			},
+			expected: []config.Host{},
+			title:    "Offset out of the CIDR",
+		},
+		{
+			input: config.GroupHost{
+				NamePattern:  "cn[1-2]",
+				IPs:          []string{"172.20.0.300"},
+				HostTemplate: hostTemplate,
+			},
+			isError:  true,
+			expected: []config.Host{},
+			title:    "Invalid IP",
+		},
 		{
 			input: config.GroupHost{
 				NamePattern:  "cn[1-2000]",
@@ -201,6 +353,32 @@ func (suite *GroupHostTestSuite) TestGenerateHosts() {
 	}
 }
 
+func (suite *GroupHostTestSuite) TestUnmarshalOverrides() {
+	// Arrange
+	data := []byte(`
+namePattern: cn[1-50]
+ipCIDR: 172.20.0.0/24
+template:
+  diskSize: 50
+  flavorName: flavor
+  imageName: image
+overrides:
+  - namePattern: cn[40-50]
+    flavorName: big
+`)
+	var actual config.GroupHost
+
+	// Act
+	err := yaml.Unmarshal(data, &actual)
+
+	// Assert
+	suite.NoError(err)
+	suite.Equal([]config.HostOverride{
+		{NamePattern: "cn[40-50]", Host: config.Host{FlavorName: "big"}},
+	}, actual.Overrides)
+	suite.NoError(actual.Validate())
+}
+
 func TestGroupHostTestSuite(t *testing.T) {
 	suite.Run(t, &GroupHostTestSuite{})
 }
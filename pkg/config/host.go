@@ -1,6 +1,10 @@
 package config
 
-import "github.com/squarefactory/cloud-burster/validate"
+import (
+	"reflect"
+
+	"github.com/squarefactory/cloud-burster/validate"
+)
 
 type Host struct {
 	Name       string `yaml:"name,omitempty" validate:"omitempty"`
@@ -10,8 +14,35 @@ type Host struct {
 	FlavorName string `yaml:"flavorName"     validate:"required"`
 	ImageName  string `yaml:"imageName"      validate:"required"`
 	IP         string `yaml:"ip,omitempty"   validate:"omitempty,ip"`
+	IPv6       string `yaml:"ipv6,omitempty" validate:"omitempty,ipv6"`
 }
 
 func (c *Host) Validate() error {
 	return validate.I.Struct(c)
 }
+
+// issues returns the semantic issues of the host, which is at p.
+func (h *Host) issues(p path) Issues {
+	var out Issues
+	for _, f := range []struct {
+		key   string
+		value int
+	}{{"diskSize", h.DiskSize}, {"ram", h.RAM}, {"gpu", h.GPU}} {
+		if f.value < 0 {
+			out = append(out, newIssue(p.Key(f.key), "must be positive, got %d", f.value))
+		}
+	}
+	return out
+}
+
+// Merge returns h with every non-zero field of o.
+func (h Host) Merge(o Host) Host {
+	dst := reflect.ValueOf(&h).Elem()
+	src := reflect.ValueOf(o)
+	for i := 0; i < src.NumField(); i++ {
+		if f := src.Field(i); !f.IsZero() {
+			dst.Field(i).Set(f)
+		}
+	}
+	return h
+}
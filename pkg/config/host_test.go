@@ -14,7 +14,7 @@ var cleanHost = config.Host{
 	DiskSize:   50,
 	FlavorName: "d2-2",
 	ImageName:  "Rocky Linux 9",
-	IP:         "172.28.16.254",
+	IP:         "172.28.15.254",
 }
 
 type HostTestSuite struct {
@@ -0,0 +1,186 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"gopkg.in/yaml.v3"
+)
+
+// Issue is a problem found in the configuration.
+type Issue struct {
+	// Path is the YAML path of the value, like clouds[0].hosts[1].ip.
+	Path string `json:"path"`
+	// Line is the line of the value in the parsed file. 0 if unknown.
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+
+	at path
+}
+
+func newIssue(at path, format string, args ...interface{}) Issue {
+	return Issue{Path: at.String(), Message: fmt.Sprintf(format, args...), at: at}
+}
+
+func (i Issue) String() string {
+	if i.Line > 0 {
+		return fmt.Sprintf("line %d: %s: %s", i.Line, i.Path, i.Message)
+	}
+	return fmt.Sprintf("%s: %s", i.Path, i.Message)
+}
+
+// Issues is the error returned by Config.Validate, with every issue of the configuration.
+type Issues []Issue
+
+func (is Issues) Error() string {
+	lines := make([]string, 0, len(is)+1)
+	lines = append(lines, fmt.Sprintf("%d issue(s) in the configuration:", len(is)))
+	for _, i := range is {
+		lines = append(lines, i.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// path is a YAML path made of keys and sequence indexes.
+type path []interface{}
+
+// Key returns a copy of p followed by a mapping key.
+func (p path) Key(key string) path {
+	return append(p[:len(p):len(p)], key)
+}
+
+// Index returns a copy of p followed by a sequence index.
+func (p path) Index(idx int) path {
+	return append(p[:len(p):len(p)], idx)
+}
+
+func (p path) String() string {
+	var sb strings.Builder
+	for _, segment := range p {
+		switch s := segment.(type) {
+		case int:
+			sb.WriteString("[" + strconv.Itoa(s) + "]")
+		default:
+			if sb.Len() > 0 {
+				sb.WriteString(".")
+			}
+			sb.WriteString(fmt.Sprint(s))
+		}
+	}
+	return sb.String()
+}
+
+// line returns the line of the value at p in node. If the value is missing, the line of
+// its closest parent is returned.
+func (p path) line(node *yaml.Node) int {
+	found, _ := p.lookup(node)
+	if found == nil {
+		return 0
+	}
+	return found.Line
+}
+
+// lookup returns the value at p in node, or its closest parent if the value is missing.
+// complete is true if the value was found.
+func (p path) lookup(node *yaml.Node) (found *yaml.Node, complete bool) {
+	if node == nil {
+		return nil, false
+	}
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	for _, segment := range p {
+		var next *yaml.Node
+		switch s := segment.(type) {
+		case int:
+			if node.Kind == yaml.SequenceNode && s < len(node.Content) {
+				next = node.Content[s]
+			}
+		case string:
+			next = mappingValue(node, s)
+		}
+		if next == nil {
+			return node, false
+		}
+		node = next
+	}
+	return node, true
+}
+
+// issuesOf converts the errors of the validator of root, which is at p, into issues.
+func issuesOf(err error, root reflect.Type, p path) (Issues, error) {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return nil, err
+	}
+	out := make(Issues, 0, len(validationErrors))
+	for _, fe := range validationErrors {
+		tag := fe.Tag()
+		if fe.Param() != "" {
+			tag += "=" + fe.Param()
+		}
+		out = append(out, newIssue(
+			append(p[:len(p):len(p)], yamlPath(root, fe.StructNamespace())...),
+			"field validation for '%s' failed on the '%s' tag",
+			fe.StructField(),
+			tag,
+		))
+	}
+	return out, nil
+}
+
+// yamlPath converts a namespace of the validator, like Config.Clouds[0].Network.SubnetCIDR,
+// into the YAML path clouds[0].network.subnetCIDR.
+func yamlPath(t reflect.Type, namespace string) path {
+	var out path
+	segments := strings.Split(namespace, ".")
+	// The first segment is the root struct
+	for _, segment := range segments[1:] {
+		name, rest, _ := strings.Cut(segment, "[")
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			break
+		}
+		field, ok := t.FieldByName(name)
+		if !ok {
+			break
+		}
+		key, inline := yamlKey(field)
+		if !inline {
+			out = out.Key(key)
+		}
+		t = field.Type
+		for _, idx := range strings.Split(strings.TrimSuffix(rest, "]"), "][") {
+			if idx == "" {
+				continue
+			}
+			for t.Kind() == reflect.Pointer {
+				t = t.Elem()
+			}
+			if i, err := strconv.Atoi(idx); err == nil && t.Kind() != reflect.Map {
+				out = out.Index(i)
+			} else {
+				out = out.Key(idx)
+			}
+			t = t.Elem()
+		}
+	}
+	return out
+}
+
+// yamlKey returns the key of a field, following the rules of yaml.v3.
+func yamlKey(field reflect.StructField) (key string, inline bool) {
+	tag := field.Tag.Get("yaml")
+	key, opts, _ := strings.Cut(tag, ",")
+	inline = strings.Contains(opts, "inline")
+	if key == "" {
+		key = strings.ToLower(field.Name)
+	}
+	return key, inline
+}
@@ -8,6 +8,24 @@ type Network struct {
 	DNS        string `yaml:"dns"              validate:"required,ip"`
 	Search     string `yaml:"search,omitempty" validate:"omitempty"`
 	Gateway    string `yaml:"gateway"          validate:"required,ip"`
+	// SubnetCIDR6 is the IPv6 subnet of a dual-stack network.
+	SubnetCIDR6 string `yaml:"subnetCIDR6,omitempty" validate:"omitempty,cidrv6"`
+	// Gateway6 is the IPv6 gateway of a dual-stack network.
+	Gateway6 string `yaml:"gateway6,omitempty" validate:"omitempty,ipv6"`
+	// DNSServers are additional nameservers, IPv4 or IPv6.
+	DNSServers []string `yaml:"dnsServers,omitempty" validate:"omitempty,dive,ip"`
+}
+
+// Nameservers returns DNS followed by DNSServers.
+func (c *Network) Nameservers() []string {
+	out := make([]string, 0, len(c.DNSServers)+1)
+	out = append(out, c.DNS)
+	for _, dns := range c.DNSServers {
+		if dns != c.DNS {
+			out = append(out, dns)
+		}
+	}
+	return out
 }
 
 func (c *Network) Validate() error {
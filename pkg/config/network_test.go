@@ -153,6 +153,18 @@ func (suite *NetworkTestSuite) TestValidate() {
 	}
 }
 
+func (suite *NetworkTestSuite) TestNameservers() {
+	// Arrange
+	network := cleanNetwork
+	network.DNSServers = []string{"1.1.1.1", "2606:4700:4700::1111"}
+
+	// Act
+	actual := network.Nameservers()
+
+	// Assert
+	suite.Equal([]string{"1.1.1.1", "2606:4700:4700::1111"}, actual)
+}
+
 func TestNetworkTestSuite(t *testing.T) {
 	suite.Run(t, &NetworkTestSuite{})
 }
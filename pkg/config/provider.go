@@ -0,0 +1,178 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProviderOptions are the options of a provider, decoded from the provider block of a cloud.
+type ProviderOptions interface {
+	// Validate checks the options. Errors of the validator and OptionErrors are reported with
+	// the path of the faulty option.
+	Validate() error
+}
+
+// OptionError is an error of a single option, at Key in the provider block.
+type OptionError struct {
+	Key string
+	Err error
+}
+
+func (e *OptionError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Key, e.Err)
+}
+
+func (e *OptionError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	schemasMu sync.RWMutex
+	schemas   = make(map[string]func() ProviderOptions)
+)
+
+// RegisterSchema registers the options of the provider name. newOptions must return a pointer
+// to empty options, which the provider block of the clouds of this type is decoded into.
+//
+// It panics if the provider is already registered.
+func RegisterSchema(name string, newOptions func() ProviderOptions) {
+	schemasMu.Lock()
+	defer schemasMu.Unlock()
+	if _, ok := schemas[name]; ok {
+		panic("provider already registered: " + name)
+	}
+	schemas[name] = newOptions
+}
+
+// Schema returns a new instance of the options of the provider name.
+func Schema(name string) (ProviderOptions, bool) {
+	schemasMu.RLock()
+	defer schemasMu.RUnlock()
+	newOptions, ok := schemas[name]
+	if !ok {
+		return nil, false
+	}
+	return newOptions(), true
+}
+
+// Providers returns the sorted names of the registered providers.
+func Providers() []string {
+	schemasMu.RLock()
+	defer schemasMu.RUnlock()
+	out := make([]string, 0, len(schemas))
+	for name := range schemas {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// UnmarshalYAML decodes the cloud, then its provider block into the options registered for
+// its type.
+//
+// The provider block is read from the "provider" key, or from the key named after the type
+// (e.g. "openstack") for the configurations written before the registry.
+func (c *Cloud) UnmarshalYAML(node *yaml.Node) error {
+	type plain Cloud
+	if err := node.Decode((*plain)(c)); err != nil {
+		return err
+	}
+	block := mappingValue(node, "provider")
+	if block == nil && c.Type != "" {
+		block = mappingValue(node, c.Type)
+	}
+	options, ok := Schema(c.Type)
+	if !ok || block == nil {
+		// Reported by Validate
+		return nil
+	}
+	if err := block.Decode(options); err != nil {
+		return err
+	}
+	c.Provider = options
+	return nil
+}
+
+// validateProvider checks the type of the cloud and the options of its provider.
+func (c *Cloud) validateProvider() error {
+	if _, ok := Schema(c.Type); !ok {
+		return fmt.Errorf(
+			"unknown provider %q, expected one of: %s",
+			c.Type,
+			strings.Join(Providers(), ", "),
+		)
+	}
+	if c.Provider == nil {
+		return errors.New("the provider block is missing")
+	}
+	return c.Provider.Validate()
+}
+
+// providerIssues returns the issues of the type and of the provider block of the cloud, which
+// is at p. source is the parsed document, nil if unknown.
+func (c *Cloud) providerIssues(p path, source *yaml.Node) Issues {
+	if c.Type == "" {
+		// Reported by the validator
+		return nil
+	}
+	if _, ok := Schema(c.Type); !ok {
+		return Issues{newIssue(
+			p.Key("type"),
+			"unknown provider %q, expected one of: %s",
+			c.Type,
+			strings.Join(Providers(), ", "),
+		)}
+	}
+	at := p.Key("provider")
+	if _, found := at.lookup(source); !found {
+		if _, found := p.Key(c.Type).lookup(source); found {
+			at = p.Key(c.Type)
+		}
+	}
+	if c.Provider == nil {
+		return Issues{newIssue(at, "the provider block is missing")}
+	}
+	return optionIssues(c.Provider.Validate(), c.Provider, at)
+}
+
+// optionIssues converts the errors of the validation of options, which are at p, into issues.
+func optionIssues(err error, options ProviderOptions, p path) Issues {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var out Issues
+		for _, err := range joined.Unwrap() {
+			out = append(out, optionIssues(err, options, p)...)
+		}
+		return out
+	}
+	var optionErr *OptionError
+	if errors.As(err, &optionErr) {
+		return Issues{newIssue(p.Key(optionErr.Key), "%s", optionErr.Err)}
+	}
+	converted, err := issuesOf(err, reflect.TypeOf(options), p)
+	if err != nil {
+		return Issues{newIssue(p, "%s", err)}
+	}
+	return converted
+}
+
+// mappingValue returns the value of key in a mapping node, nil if missing.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
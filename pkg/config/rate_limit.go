@@ -0,0 +1,31 @@
+package config
+
+import (
+	"math"
+
+	"github.com/squarefactory/cloud-burster/validate"
+	"golang.org/x/time/rate"
+)
+
+// RateLimit bounds the API requests sent to a cloud, with a token bucket shared by every host of the cloud.
+type RateLimit struct {
+	RequestsPerSecond float64 `yaml:"requestsPerSecond" validate:"required,gt=0"`
+	// Burst defaults to the requests per second, rounded up.
+	Burst int `yaml:"burst,omitempty" validate:"omitempty,min=1"`
+}
+
+func (r *RateLimit) Validate() error {
+	return validate.I.Struct(r)
+}
+
+// Limiter returns the token bucket of the rate limit, or nil if r is nil.
+func (r *RateLimit) Limiter() *rate.Limiter {
+	if r == nil {
+		return nil
+	}
+	burst := r.Burst
+	if burst == 0 {
+		burst = int(math.Ceil(r.RequestsPerSecond))
+	}
+	return rate.NewLimiter(rate.Limit(r.RequestsPerSecond), burst)
+}
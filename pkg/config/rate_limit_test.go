@@ -0,0 +1,68 @@
+//go:build unit
+
+package config_test
+
+import (
+	"testing"
+
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/time/rate"
+)
+
+type RateLimitTestSuite struct {
+	suite.Suite
+}
+
+func (suite *RateLimitTestSuite) TestLimiter() {
+	tests := []struct {
+		input         *config.RateLimit
+		expectedLimit rate.Limit
+		expectedBurst int
+		title         string
+	}{
+		{
+			input:         &config.RateLimit{RequestsPerSecond: 2.5},
+			expectedLimit: 2.5,
+			expectedBurst: 3,
+			title:         "Default burst",
+		},
+		{
+			input:         &config.RateLimit{RequestsPerSecond: 10, Burst: 1},
+			expectedLimit: 10,
+			expectedBurst: 1,
+			title:         "Explicit burst",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Act
+			actual := tt.input.Limiter()
+
+			// Assert
+			suite.Equal(tt.expectedLimit, actual.Limit())
+			suite.Equal(tt.expectedBurst, actual.Burst())
+		})
+	}
+}
+
+func (suite *RateLimitTestSuite) TestLimiterNil() {
+	// Act
+	var r *config.RateLimit
+
+	// Assert
+	suite.Nil(r.Limiter())
+}
+
+func (suite *RateLimitTestSuite) TestValidate() {
+	// Act
+	err := (&config.RateLimit{RequestsPerSecond: 0}).Validate()
+
+	// Assert
+	suite.ErrorContains(err, "RequestsPerSecond")
+}
+
+func TestRateLimitTestSuite(t *testing.T) {
+	suite.Run(t, &RateLimitTestSuite{})
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"time"
+
+	"github.com/squarefactory/cloud-burster/validate"
+)
+
+const (
+	ProbeState     = "state"
+	ProbeTCP       = "tcp"
+	ProbeSSH       = "ssh"
+	ProbePhoneHome = "phoneHome"
+)
+
+// Readiness describes how to check that a created host is usable.
+//
+// The probes run in order: each one must pass before the next one starts.
+type Readiness struct {
+	// Timeout is the time given to the probes. Defaults to 10 minutes.
+	Timeout time.Duration `yaml:"timeout,omitempty"  validate:"omitempty,min=0"`
+	// Interval is the delay between two attempts of a probe. Defaults to 5 seconds.
+	Interval time.Duration `yaml:"interval,omitempty" validate:"omitempty,min=0"`
+	// Rollback deletes the host if it is not ready in time.
+	Rollback bool    `yaml:"rollback,omitempty"`
+	Probes   []Probe `yaml:"probes"             validate:"required,dive"`
+}
+
+type Probe struct {
+	Type string `yaml:"type" validate:"required,oneof=state tcp ssh phoneHome"`
+	// Port is the port of the tcp and ssh probes. Defaults to 22.
+	Port int `yaml:"port,omitempty" validate:"omitempty,min=1,max=65535"`
+	// User is the user of the ssh probe.
+	User string `yaml:"user,omitempty" validate:"required_if=Type ssh"`
+	// PrivateKey is the base64-encoded private key of the ssh probe.
+	PrivateKey string `yaml:"privateKey,omitempty" validate:"required_if=Type ssh,omitempty,base64"`
+	// Command is run by the ssh probe. Defaults to "true".
+	Command string `yaml:"command,omitempty"`
+	// ListenAddress is where the phoneHome probe receives the callbacks of cloud-init.
+	ListenAddress string `yaml:"listenAddress,omitempty" validate:"required_if=Type phoneHome,omitempty,hostname_port"`
+}
+
+func (r *Readiness) Validate() error {
+	return validate.I.Struct(r)
+}
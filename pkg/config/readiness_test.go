@@ -0,0 +1,116 @@
+//go:build unit
+
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/yaml.v3"
+)
+
+type ReadinessTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ReadinessTestSuite) TestValidate() {
+	tests := []struct {
+		input         *config.Readiness
+		isError       bool
+		errorContains []string
+		title         string
+	}{
+		{
+			input: &config.Readiness{
+				Probes: []config.Probe{
+					{Type: config.ProbeState},
+					{Type: config.ProbeTCP, Port: 22},
+					{Type: config.ProbeSSH, User: "root", PrivateKey: "a2V5"},
+					{Type: config.ProbePhoneHome, ListenAddress: "0.0.0.0:8081"},
+				},
+			},
+			title: "Positive test",
+		},
+		{
+			isError: true,
+			errorContains: []string{
+				"Type",
+				"oneof",
+			},
+			input: &config.Readiness{
+				Probes: []config.Probe{{Type: "icmp"}},
+			},
+			title: "Unknown probe",
+		},
+		{
+			isError: true,
+			errorContains: []string{
+				"User",
+				"PrivateKey",
+			},
+			input: &config.Readiness{
+				Probes: []config.Probe{{Type: config.ProbeSSH}},
+			},
+			title: "Required SSH credentials",
+		},
+		{
+			isError: true,
+			errorContains: []string{
+				"ListenAddress",
+			},
+			input: &config.Readiness{
+				Probes: []config.Probe{{Type: config.ProbePhoneHome}},
+			},
+			title: "Required listen address",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Act
+			err := tt.input.Validate()
+
+			// Assert
+			if tt.isError {
+				suite.Error(err)
+				for _, contain := range tt.errorContains {
+					suite.ErrorContains(err, contain)
+				}
+			} else {
+				suite.NoError(err)
+			}
+		})
+	}
+}
+
+func (suite *ReadinessTestSuite) TestUnmarshal() {
+	// Arrange
+	input := `
+timeout: 5m
+interval: 10s
+rollback: true
+probes:
+  - type: tcp
+    port: 22
+`
+	expected := config.Readiness{
+		Timeout:  5 * time.Minute,
+		Interval: 10 * time.Second,
+		Rollback: true,
+		Probes:   []config.Probe{{Type: config.ProbeTCP, Port: 22}},
+	}
+
+	// Act
+	var actual config.Readiness
+	err := yaml.Unmarshal([]byte(input), &actual)
+
+	// Assert
+	suite.NoError(err)
+	suite.Equal(expected, actual)
+}
+
+func TestReadinessTestSuite(t *testing.T) {
+	suite.Run(t, &ReadinessTestSuite{})
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrHostNotFound is returned when a hostname is not in the configuration.
+var ErrHostNotFound = errors.New("hostname not found")
+
+// ErrAmbiguousHost is returned when a hostname matches several hosts.
+var ErrAmbiguousHost = errors.New("ambiguous hostname")
+
+type resolverEntry struct {
+	host  Host
+	cloud int
+}
+
+// Resolver finds the hosts of a configuration by name.
+//
+// The hosts are indexed once: groups are not expanded on each lookup.
+type Resolver struct {
+	suffixes []string
+	hosts    map[string][]resolverEntry
+}
+
+// NewResolver indexes the hosts of every cloud.
+func NewResolver(c *Config) (*Resolver, error) {
+	r := &Resolver{
+		suffixes: c.SuffixSearch,
+		hosts:    make(map[string][]resolverEntry),
+	}
+	for idx := range c.Clouds {
+		hosts, err := c.Clouds[idx].GenerateHosts()
+		if err != nil {
+			return nil, err
+		}
+		for _, host := range hosts {
+			r.hosts[host.Name] = append(r.hosts[host.Name], resolverEntry{host: host, cloud: idx})
+		}
+	}
+	return r, nil
+}
+
+// Resolve searches a host by its name, and by its short name completed with the search suffixes.
+//
+// It returns the host and the index of its cloud. A hostname matching several names,
+// or a name defined several times, returns ErrAmbiguousHost.
+func (r *Resolver) Resolve(hostname string) (*Host, int, error) {
+	var names []string
+	candidates := make([]string, 0, len(r.suffixes)+1)
+	for _, suffix := range r.suffixes {
+		candidates = append(candidates, hostname+suffix)
+	}
+	candidates = append(candidates, hostname)
+	for _, name := range candidates {
+		if _, ok := r.hosts[name]; ok && !contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	switch len(names) {
+	case 0:
+		return nil, -1, fmt.Errorf("%s: %w", hostname, ErrHostNotFound)
+	case 1:
+	default:
+		return nil, -1, fmt.Errorf(
+			"%s: %w: matches %s",
+			hostname,
+			ErrAmbiguousHost,
+			strings.Join(names, ", "),
+		)
+	}
+
+	entries := r.hosts[names[0]]
+	if len(entries) > 1 {
+		return nil, -1, fmt.Errorf(
+			"%s: %w: defined in clouds %v",
+			hostname,
+			ErrAmbiguousHost,
+			clouds(entries),
+		)
+	}
+	host := entries[0].host
+	return &host, entries[0].cloud, nil
+}
+
+// Duplicates returns the hostnames defined several times, with the indexes of their clouds.
+func (r *Resolver) Duplicates() map[string][]int {
+	out := make(map[string][]int)
+	for name, entries := range r.hosts {
+		if len(entries) > 1 {
+			out[name] = clouds(entries)
+		}
+	}
+	return out
+}
+
+func clouds(entries []resolverEntry) []int {
+	out := make([]int, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, e.cloud)
+	}
+	sort.Ints(out)
+	return out
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
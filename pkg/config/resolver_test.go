@@ -0,0 +1,117 @@
+//go:build unit
+
+package config_test
+
+import (
+	"testing"
+
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/stretchr/testify/suite"
+)
+
+type ResolverTestSuite struct {
+	suite.Suite
+	impl *config.Resolver
+}
+
+func (suite *ResolverTestSuite) BeforeTest(suiteName, testName string) {
+	conf := &config.Config{
+		SuffixSearch: []string{".a.example.com", ".b.example.com"},
+		Clouds: []config.Cloud{
+			{
+				Type: "openstack",
+				Hosts: []config.Host{
+					{Name: "login.a.example.com", IP: "10.0.0.1"},
+					{Name: "shared", IP: "10.0.0.2"},
+				},
+				GroupsHost: []config.GroupHost{
+					{NamePattern: "cn-[1-2].a.example.com", IPCidr: "10.0.1.0/24"},
+				},
+			},
+			{
+				Type: "exoscale",
+				Hosts: []config.Host{
+					{Name: "shared", IP: "10.0.2.2"},
+				},
+				GroupsHost: []config.GroupHost{
+					{NamePattern: "cn-[2-3].b.example.com", IPCidr: "10.0.3.0/24"},
+				},
+			},
+		},
+	}
+	r, err := config.NewResolver(conf)
+	suite.Require().NoError(err)
+	suite.impl = r
+}
+
+func (suite *ResolverTestSuite) TestResolve() {
+	tests := []struct {
+		input         string
+		expectedName  string
+		expectedCloud int
+		expectedErr   error
+		title         string
+	}{
+		{
+			input:         "login",
+			expectedName:  "login.a.example.com",
+			expectedCloud: 0,
+			title:         "Short name",
+		},
+		{
+			input:         "cn-3.b.example.com",
+			expectedName:  "cn-3.b.example.com",
+			expectedCloud: 1,
+			title:         "Full name",
+		},
+		{
+			input:         "cn-1",
+			expectedName:  "cn-1.a.example.com",
+			expectedCloud: 0,
+			title:         "Group host",
+		},
+		{
+			input:       "cn-2",
+			expectedErr: config.ErrAmbiguousHost,
+			title:       "Short name matching several suffixes",
+		},
+		{
+			input:       "shared",
+			expectedErr: config.ErrAmbiguousHost,
+			title:       "Name defined in several clouds",
+		},
+		{
+			input:       "cn-4",
+			expectedErr: config.ErrHostNotFound,
+			title:       "Not found",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Act
+			host, idx, err := suite.impl.Resolve(tt.input)
+
+			// Assert
+			if tt.expectedErr != nil {
+				suite.ErrorIs(err, tt.expectedErr)
+			} else {
+				suite.NoError(err)
+				suite.Equal(tt.expectedName, host.Name)
+				suite.Equal(tt.expectedCloud, idx)
+			}
+		})
+	}
+}
+
+func (suite *ResolverTestSuite) TestDuplicates() {
+	// Act
+	actual := suite.impl.Duplicates()
+
+	// Assert
+	suite.Equal(map[string][]int{"shared": {0, 1}}, actual)
+}
+
+func TestResolverTestSuite(t *testing.T) {
+	suite.Run(t, &ResolverTestSuite{})
+}
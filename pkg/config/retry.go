@@ -0,0 +1,48 @@
+package config
+
+import (
+	"time"
+
+	"github.com/squarefactory/cloud-burster/utils/try"
+	"github.com/squarefactory/cloud-burster/validate"
+)
+
+// Retry overrides the retry policy of a data source. Unset fields keep the defaults of the data source.
+type Retry struct {
+	MaxAttempts  int           `yaml:"maxAttempts,omitempty"  validate:"omitempty,min=1"`
+	MaxElapsed   time.Duration `yaml:"maxElapsed,omitempty"   validate:"omitempty,min=0"`
+	InitialDelay time.Duration `yaml:"initialDelay,omitempty" validate:"omitempty,min=0"`
+	MaxDelay     time.Duration `yaml:"maxDelay,omitempty"     validate:"omitempty,min=0"`
+	Multiplier   float64       `yaml:"multiplier,omitempty"   validate:"omitempty,min=1"`
+	Jitter       float64       `yaml:"jitter,omitempty"       validate:"omitempty,min=0,max=1"`
+}
+
+func (r *Retry) Validate() error {
+	return validate.I.Struct(r)
+}
+
+// Apply returns the policy with the fields set in r.
+func (r *Retry) Apply(policy try.Policy) try.Policy {
+	if r == nil {
+		return policy
+	}
+	if r.MaxAttempts != 0 {
+		policy.MaxAttempts = r.MaxAttempts
+	}
+	if r.MaxElapsed != 0 {
+		policy.MaxElapsed = r.MaxElapsed
+	}
+	if r.InitialDelay != 0 {
+		policy.InitialDelay = r.InitialDelay
+	}
+	if r.MaxDelay != 0 {
+		policy.MaxDelay = r.MaxDelay
+	}
+	if r.Multiplier != 0 {
+		policy.Multiplier = r.Multiplier
+	}
+	if r.Jitter != 0 {
+		policy.Jitter = r.Jitter
+	}
+	return policy
+}
@@ -0,0 +1,73 @@
+//go:build unit
+
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/utils/try"
+	"github.com/stretchr/testify/suite"
+)
+
+type RetryTestSuite struct {
+	suite.Suite
+}
+
+func (suite *RetryTestSuite) TestApply() {
+	// Arrange
+	defaults := try.Policy{
+		MaxElapsed:   time.Minute,
+		InitialDelay: time.Second,
+		Multiplier:   2,
+	}
+
+	tests := []struct {
+		input    *config.Retry
+		expected try.Policy
+		title    string
+	}{
+		{
+			input:    nil,
+			expected: defaults,
+			title:    "No override",
+		},
+		{
+			input: &config.Retry{
+				MaxElapsed: 5 * time.Minute,
+				Jitter:     0.5,
+			},
+			expected: try.Policy{
+				MaxElapsed:   5 * time.Minute,
+				InitialDelay: time.Second,
+				Multiplier:   2,
+				Jitter:       0.5,
+			},
+			title: "Partial override",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Act
+			actual := tt.input.Apply(defaults)
+
+			// Assert
+			suite.Equal(tt.expected, actual)
+		})
+	}
+}
+
+func (suite *RetryTestSuite) TestValidate() {
+	// Act
+	err := (&config.Retry{Multiplier: 0.5, Jitter: 2}).Validate()
+
+	// Assert
+	suite.ErrorContains(err, "Multiplier")
+	suite.ErrorContains(err, "Jitter")
+}
+
+func TestRetryTestSuite(t *testing.T) {
+	suite.Run(t, &RetryTestSuite{})
+}
@@ -1,14 +0,0 @@
-package config
-
-import "github.com/squarefactory/cloud-burster/validate"
-
-type Shadow struct {
-	Username string `yaml:"username"`
-	Password string `yaml:"password"`
-	Zone     string `yaml:"zone"`
-	SSHKey   string `yaml:"sshKey"`
-}
-
-func (c *Shadow) Validate() error {
-	return validate.I.Struct(c)
-}
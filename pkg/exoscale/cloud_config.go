@@ -15,10 +15,13 @@ import (
 type CloudConfigOpts struct {
 	AuthorizedKeys []string
 	PostScripts    config.PostScriptsOpts
-	// AddressCIDR follows the format <ip>/<mask>
-	AddressCIDR       string
-	Gateway           string
-	DNS               string
+	// AddressCIDR follows the format <ip>/<mask>. Empty for IPv6-only hosts.
+	AddressCIDR string
+	Gateway     string
+	// AddressCIDR6 follows the format <ipv6>/<mask>. Empty for IPv4-only hosts.
+	AddressCIDR6      string
+	Gateway6          string
+	DNS               []string
 	Search            string
 	CustomCloudConfig string
 }
@@ -35,7 +38,7 @@ write_files:
   - path: /etc/systemd/resolved.conf
     content: |
       [Resolve]
-      DNS={{ .DNS }}
+      DNS={{ join " " .DNS }}
       DNSStubListener=no
 
   - path: /etc/NetworkManager/NetworkManager.conf
@@ -48,7 +51,9 @@ write_files:
 
   - path: /etc/resolv.conf
     content: |
-      nameserver {{ .DNS }}
+{{- range .DNS }}
+      nameserver {{ . }}
+{{- end }}
 {{- if .Search }}
       search {{ .Search }}
 {{ end }}
@@ -69,11 +74,22 @@ runcmd:
   - [ xfs_growfs, "/" ]
   - [ resize2fs, "/dev/vda2" ]
   - [ nmcli, connection, modify, "Wired connection 1", connection.autoconnect, "yes" ]
+{{- if .AddressCIDR }}
   - [ nmcli, connection, modify, "Wired connection 1", ipv4.addresses, "{{ .AddressCIDR }}" ]
   - [ nmcli, connection, modify, "Wired connection 1", ipv4.gateway, "{{ .Gateway }}" ]
   - [ nmcli, connection, modify, "Wired connection 1", ipv4.route-metric, "1" ]
   - [ nmcli, connection, modify, "Wired connection 1", ipv4.never-default, "no" ]
   - [ nmcli, connection, modify, "Wired connection 1", ipv4.method, manual ]
+{{- end }}
+{{- if .AddressCIDR6 }}
+  - [ nmcli, connection, modify, "Wired connection 1", ipv6.addresses, "{{ .AddressCIDR6 }}" ]
+{{- if .Gateway6 }}
+  - [ nmcli, connection, modify, "Wired connection 1", ipv6.gateway, "{{ .Gateway6 }}" ]
+{{- end }}
+  - [ nmcli, connection, modify, "Wired connection 1", ipv6.route-metric, "1" ]
+  - [ nmcli, connection, modify, "Wired connection 1", ipv6.never-default, "no" ]
+  - [ nmcli, connection, modify, "Wired connection 1", ipv6.method, manual ]
+{{- end }}
   - [ nmcli, connection, up, "Wired connection 1" ]
   - [ nmcli, connection, down, "System ens3" ]
   - [ nmcli, connection, modify, "System ens3", connection.autoconnect, "no" ]
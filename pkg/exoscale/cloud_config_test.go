@@ -20,7 +20,7 @@ func (suite *CloudConfigTestSuite) TestGenerateCloudConfig() {
 		AuthorizedKeys: []string{
 			"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIDUnXMBGq6bV6H+c7P5QjDn1soeB6vkodi6OswcZsMwH nguye@PC-DARKNESS4",
 		},
-		DNS:    "1.1.1.1",
+		DNS:    []string{"1.1.1.1"},
 		Search: "example.com",
 		PostScripts: config.PostScriptsOpts{
 			Git: config.GitOpts{
@@ -117,6 +117,29 @@ users:
 	suite.Equal(expected, string(res))
 }
 
+func (suite *CloudConfigTestSuite) TestGenerateCloudConfigDualStack() {
+	// Arrange
+	opts := exoscale.CloudConfigOpts{
+		DNS:          []string{"1.1.1.1", "2606:4700:4700::1111"},
+		AddressCIDR:  "172.28.0.1/20",
+		Gateway:      "172.28.0.2",
+		AddressCIDR6: "2001:db8::10/64",
+		Gateway6:     "2001:db8::1",
+	}
+
+	// Act
+	res, err := exoscale.GenerateCloudConfig(&opts)
+
+	// Assert
+	suite.NoError(err)
+	suite.Contains(string(res), "DNS=1.1.1.1 2606:4700:4700::1111\n")
+	suite.Contains(string(res), "nameserver 1.1.1.1\n      nameserver 2606:4700:4700::1111\n")
+	suite.Contains(string(res), `ipv4.addresses, "172.28.0.1/20"`)
+	suite.Contains(string(res), `ipv6.addresses, "2001:db8::10/64"`)
+	suite.Contains(string(res), `ipv6.gateway, "2001:db8::1"`)
+	suite.Contains(string(res), `ipv6.method, manual`)
+}
+
 func TestCloudConfigTestSuite(t *testing.T) {
 	suite.Run(t, &CloudConfigTestSuite{})
 }
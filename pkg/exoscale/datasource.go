@@ -5,40 +5,117 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
-	"net"
+	"net/netip"
 	"strings"
 	"time"
 
 	egoscalev2 "github.com/exoscale/egoscale/v2"
+	exoapi "github.com/exoscale/egoscale/v2/api"
+	"github.com/hashicorp/go-retryablehttp"
 	"github.com/squarefactory/cloud-burster/logger"
 	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/middlewares"
+	"github.com/squarefactory/cloud-burster/pkg/provider"
 	"github.com/squarefactory/cloud-burster/utils/ptr"
+	"github.com/squarefactory/cloud-burster/utils/rollback"
 	"github.com/squarefactory/cloud-burster/utils/try"
 	"go.uber.org/zap"
+	"golang.org/x/time/rate"
 	"gopkg.in/yaml.v3"
 )
 
+// LabelHostname is the label of the instances holding their hostname. It marks the instances
+// created by cloud-burster.
+const LabelHostname = "cloud-burster-hostname"
+
+// DefaultRetry is the default retry policy of the data source.
+var DefaultRetry = try.Policy{
+	MaxElapsed:   2 * time.Minute,
+	InitialDelay: 2 * time.Second,
+	MaxDelay:     15 * time.Second,
+	Multiplier:   2,
+	Jitter:       0.2,
+	Retryable:    Retryable,
+}
+
+// Retryable returns false for the invalid requests and the missing resources.
+func Retryable(err error) bool {
+	return !errors.Is(err, exoapi.ErrInvalidRequest) &&
+		!errors.Is(err, exoapi.ErrNotFound) &&
+		!errors.Is(err, exoapi.ErrTooManyFound)
+}
+
 type DataSource struct {
+	// Retry is the retry policy of the operations which are eventually consistent.
+	Retry  try.Policy
 	client *egoscalev2.Client
 	zone   string
 }
 
-func New(
-	apiKey string,
-	apiSecret string,
-	zoneName string,
-) *DataSource {
+// New returns a data source managing the instances of the zone of the options.
+func New(options *Options, limiter *rate.Limiter) (*DataSource, error) {
+	var opts []egoscalev2.ClientOpt
+	if limiter != nil {
+		// Keep the retries of the default client, each attempt waiting for a token
+		rc := retryablehttp.NewClient()
+		rc.Logger = nil
+		rc.HTTPClient.Transport = middlewares.Transport(limiter)
+		opts = append(opts, egoscalev2.ClientOptWithHTTPClient(rc.StandardClient()))
+	}
 	client, err := egoscalev2.NewClient(
-		apiKey,
-		apiSecret,
+		options.APIKey,
+		options.APISecret,
+		opts...,
 	)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	return &DataSource{
+		Retry:  DefaultRetry,
 		client: client,
-		zone:   zoneName,
+		zone:   options.Zone,
+	}, nil
+}
+
+// addressCIDRs returns the addresses of a host with the length of their subnet, by IP version.
+func addressCIDRs(host *config.Host, network *config.Network) (map[int]string, error) {
+	out := make(map[int]string, 2)
+	for _, ip := range []string{host.IP, host.IPv6} {
+		if ip == "" {
+			continue
+		}
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			return nil, err
+		}
+		version := 4
+		if addr.Is6() {
+			version = 6
+		}
+		subnet := family(version, network.SubnetCIDR, network.SubnetCIDR6)
+		if subnet == "" {
+			return nil, fmt.Errorf("no IPv%d subnet for %s", version, addr)
+		}
+		prefix, err := netip.ParsePrefix(subnet)
+		if err != nil {
+			return nil, err
+		}
+		out[version] = netip.PrefixFrom(addr, prefix.Bits()).String()
+	}
+	return out, nil
+}
+
+// family returns the first address or CIDR of the IP version.
+func family(version int, values ...string) string {
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+		if (version == 6) == strings.Contains(v, ":") {
+			return v
+		}
 	}
+	return ""
 }
 
 // FindImageID retrieves the image UUID from name
@@ -103,6 +180,52 @@ func (s *DataSource) FindNetworkID(ctx context.Context, name string) (string, er
 	return "", errors.New("didn't find a network")
 }
 
+// Check resolves the templates, instance types and private network of the cloud, and compares
+// the number of hosts with the instance quota of the organization
+func (s *DataSource) Check(ctx context.Context, cloud *config.Cloud) ([]provider.Finding, error) {
+	logger.I.Debug("Check called")
+	demand, err := cloud.Demand()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []provider.Finding
+	for _, name := range config.Names(demand.Images) {
+		if _, err := s.FindImageID(ctx, name); err != nil {
+			out = append(out, provider.Finding{
+				Kind:    "image",
+				Name:    name,
+				Message: fmt.Sprintf("%s, used by %d host(s)", err, demand.Images[name]),
+			})
+		}
+	}
+	for _, name := range config.Names(demand.Flavors) {
+		if _, err := s.FindFlavorID(ctx, name); err != nil {
+			out = append(out, provider.Finding{
+				Kind:    "flavor",
+				Name:    name,
+				Message: fmt.Sprintf("%s, used by %d host(s)", err, demand.Flavors[name]),
+			})
+		}
+	}
+	if cloud.Network == nil {
+		out = append(out, provider.Finding{Kind: "network", Message: "no network is configured"})
+	} else if _, err := s.FindNetworkID(ctx, cloud.Network.Name); err != nil {
+		out = append(out, provider.Finding{Kind: "network", Name: cloud.Network.Name, Message: err.Error()})
+	}
+
+	quota, err := s.client.GetQuota(ctx, s.zone, "instance")
+	if err != nil {
+		return out, err
+	}
+	if quota.Limit != nil {
+		if finding, ok := provider.CheckQuota("instance", *quota.Limit, int64(demand.Hosts)); ok {
+			out = append(out, finding)
+		}
+	}
+	return out, nil
+}
+
 // Create an instance
 func (s *DataSource) Create(
 	ctx context.Context,
@@ -126,11 +249,10 @@ func (s *DataSource) Create(
 	if err != nil {
 		return err
 	}
-	_, net, err := net.ParseCIDR(cloud.Network.SubnetCIDR)
+	addresses, err := addressCIDRs(host, cloud.Network)
 	if err != nil {
 		return err
 	}
-	mask, _ := net.Mask.Size()
 
 	var customConfig []byte
 	if len(cloud.CustomConfig) == 0 {
@@ -145,16 +267,33 @@ func (s *DataSource) Create(
 	userData, err := GenerateCloudConfig(&CloudConfigOpts{
 		AuthorizedKeys:    cloud.AuthorizedKeys,
 		PostScripts:       cloud.PostScripts,
-		DNS:               cloud.Network.DNS,
+		DNS:               cloud.Network.Nameservers(),
 		Search:            cloud.Network.Search,
-		AddressCIDR:       fmt.Sprintf("%s/%d", host.IP, mask),
-		Gateway:           cloud.Network.Gateway,
+		AddressCIDR:       addresses[4],
+		Gateway:           family(4, cloud.Network.Gateway, cloud.Network.Gateway6),
+		AddressCIDR6:      addresses[6],
+		Gateway6:          family(6, cloud.Network.Gateway, cloud.Network.Gateway6),
 		CustomCloudConfig: string(customConfig),
 	})
 	if err != nil {
 		return err
 	}
 	userDataB64 := base64.StdEncoding.EncodeToString(userData)
+
+	// CreateInstance waits for the operation: on failure or cancellation,
+	// the instance may exist without its ID being returned.
+	existing, err := s.instanceIDs(ctx, host.Name)
+	if err != nil {
+		return err
+	}
+	tx := rollback.New("create " + host.Name)
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+	tx.Push("instance "+host.Name, func(ctx context.Context) error {
+		return s.undoInstance(ctx, host.Name, existing)
+	})
+
 	instance, err := s.client.CreateInstance(ctx, s.zone, &egoscalev2.Instance{
 		Name:           &host.Name,
 		TemplateID:     &imageID,
@@ -162,6 +301,7 @@ func (s *DataSource) Create(
 		DiskSize:       ptr.Ref(int64(host.DiskSize)),
 		Zone:           &s.zone,
 		UserData:       &userDataB64,
+		Labels:         &map[string]string{LabelHostname: host.Name},
 		PrivateNetworkIDs: &[]string{
 			networkID,
 		},
@@ -169,10 +309,47 @@ func (s *DataSource) Create(
 	if err != nil {
 		return err
 	}
+	tx.Commit()
 	logger.I.Info("spawned a server", zap.Any("server", instance))
 	return nil
 }
 
+// instanceIDs returns the IDs of the instances named name.
+func (s *DataSource) instanceIDs(ctx context.Context, name string) (map[string]struct{}, error) {
+	instances, err := s.client.ListInstances(ctx, s.zone)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]struct{})
+	for _, vm := range instances {
+		if deref(vm.Name) == name {
+			out[deref(vm.ID)] = struct{}{}
+		}
+	}
+	return out, nil
+}
+
+// undoInstance deletes the instances named name, except the existing ones.
+func (s *DataSource) undoInstance(ctx context.Context, name string, existing map[string]struct{}) error {
+	instances, err := s.client.ListInstances(ctx, s.zone)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, vm := range instances {
+		if deref(vm.Name) != name {
+			continue
+		}
+		if _, ok := existing[deref(vm.ID)]; ok {
+			continue
+		}
+		if err := s.client.DeleteInstance(ctx, s.zone, vm); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func (s *DataSource) FindServer(
 	ctx context.Context,
 	name string,
@@ -196,7 +373,7 @@ func (s *DataSource) Delete(
 	name string,
 ) error {
 	logger.I.Warn("Delete called", zap.String("name", name))
-	server, err := try.Do(func() (*egoscalev2.Instance, error) {
+	server, err := try.Do(ctx, s.Retry, func() (*egoscalev2.Instance, error) {
 		vm, err := s.FindServer(ctx, name)
 		if err != nil {
 			return vm, err
@@ -216,7 +393,7 @@ func (s *DataSource) Delete(
 		}
 
 		return vm, nil
-	}, 10, 5*time.Second)
+	})
 	if err != nil {
 		return err
 	}
@@ -224,3 +401,113 @@ func (s *DataSource) Delete(
 	logger.I.Warn("deleted a server", zap.Any("server", server))
 	return nil
 }
+
+// List returns every instance of the zone
+func (s *DataSource) List(ctx context.Context) ([]provider.Instance, error) {
+	logger.I.Debug("List called")
+	instances, err := s.client.ListInstances(ctx, s.zone)
+	if err != nil {
+		return nil, err
+	}
+	networks, err := s.client.ListPrivateNetworks(ctx, s.zone)
+	if err != nil {
+		return nil, err
+	}
+	networkNames := make(map[string]string, len(networks))
+	for _, network := range networks {
+		networkNames[deref(network.ID)] = deref(network.Name)
+	}
+	out := make([]provider.Instance, 0, len(instances))
+	for _, vm := range instances {
+		out = append(out, toInstance(vm, networkNames))
+	}
+	return out, nil
+}
+
+// Get returns the most recent instance named name
+func (s *DataSource) Get(ctx context.Context, name string) (*provider.Instance, error) {
+	logger.I.Debug("Get called", zap.String("name", name))
+	list, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return provider.Newest(list, name)
+}
+
+func toInstance(vm *egoscalev2.Instance, networkNames map[string]string) provider.Instance {
+	var ips []string
+	if vm.PublicIPAddress != nil && len(*vm.PublicIPAddress) > 0 {
+		ips = append(ips, vm.PublicIPAddress.String())
+	}
+	if vm.IPv6Address != nil && len(*vm.IPv6Address) > 0 {
+		ips = append(ips, vm.IPv6Address.String())
+	}
+	var networks []string
+	if vm.PrivateNetworkIDs != nil {
+		for _, id := range *vm.PrivateNetworkIDs {
+			networks = append(networks, networkNames[id])
+		}
+	}
+
+	return provider.Instance{
+		Name:       deref(vm.Name),
+		ProviderID: deref(vm.ID),
+		State:      toState(deref(vm.State)),
+		IPs:        ips,
+		Networks:   networks,
+		Flavor:     deref(vm.InstanceTypeID),
+		Image:      deref(vm.TemplateID),
+		CreatedAt:  vm.CreatedAt,
+		Managed:    vm.Labels != nil && (*vm.Labels)[LabelHostname] != "",
+	}
+}
+
+// ListDangling returns nothing: Exoscale instances do not leave sub-resources behind
+func (s *DataSource) ListDangling(
+	ctx context.Context,
+	cloud *config.Cloud,
+) ([]provider.Resource, error) {
+	return nil, nil
+}
+
+// DeleteResource deletes an instance by UUID
+func (s *DataSource) DeleteResource(ctx context.Context, resource provider.Resource) error {
+	logger.I.Warn("DeleteResource called", zap.Any("resource", resource))
+	if resource.Kind != provider.ResourceServer {
+		return fmt.Errorf("unsupported resource kind: %s", resource.Kind)
+	}
+	vm, err := s.client.GetInstance(ctx, s.zone, resource.ID)
+	if errors.Is(err, exoapi.ErrNotFound) {
+		return provider.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return s.client.DeleteInstance(ctx, s.zone, vm)
+}
+
+func toState(state string) provider.State {
+	switch state {
+	case "running":
+		return provider.StateRunning
+	case "starting", "migrating":
+		return provider.StatePending
+	case "stopping", "stopped":
+		return provider.StateStopped
+	case "destroying", "expunging":
+		return provider.StateDeleting
+	case "destroyed":
+		return provider.StateTerminated
+	case "error":
+		return provider.StateError
+	default:
+		return provider.StateUnknown
+	}
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -77,6 +77,15 @@ func (suite *DataSourceTestSuite) TestFindNetworkID() {
 	fmt.Println(res)
 }
 
+func (suite *DataSourceTestSuite) TestCheck() {
+	// Act
+	findings, err := suite.impl.Check(context.Background(), &cloud)
+
+	// Assert
+	suite.NoError(err)
+	suite.Empty(findings)
+}
+
 func (suite *DataSourceTestSuite) TestCreate() {
 	// Act
 	ctx := context.Background()
@@ -93,11 +102,13 @@ func (suite *DataSourceTestSuite) TestCreate() {
 }
 
 func (suite *DataSourceTestSuite) BeforeTest(suiteName, testName string) {
-	suite.impl = exoscale.New(
-		suite.apiKey,
-		suite.apiSecret,
-		suite.zone,
-	)
+	impl, err := exoscale.New(&exoscale.Options{
+		APIKey:    suite.apiKey,
+		APISecret: suite.apiSecret,
+		Zone:      suite.zone,
+	}, nil)
+	suite.Require().NoError(err)
+	suite.impl = impl
 }
 func TestDataSourceTestSuite(t *testing.T) {
 	if err := godotenv.Load(".env.test"); err != nil {
@@ -0,0 +1,33 @@
+package exoscale
+
+import (
+	"github.com/squarefactory/cloud-burster/pkg/cloud"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	validation "github.com/squarefactory/cloud-burster/validate"
+)
+
+func init() {
+	cloud.Register("exoscale", cloud.Provider{
+		Description: "Exoscale compute instances, in a single zone.",
+		Options:     func() config.ProviderOptions { return &Options{} },
+		New: func(conf *config.Cloud) (cloud.DataSource, error) {
+			ds, err := New(conf.Provider.(*Options), conf.RateLimit.Limiter())
+			if err != nil {
+				return nil, err
+			}
+			ds.Retry = conf.Retry.Apply(ds.Retry)
+			return ds, nil
+		},
+	})
+}
+
+// Options are the options of the provider block of the exoscale clouds.
+type Options struct {
+	APIKey    string `yaml:"apiKey"`
+	APISecret string `yaml:"apiSecret"`
+	Zone      string `yaml:"zone"`
+}
+
+func (o *Options) Validate() error {
+	return validation.I.Struct(o)
+}
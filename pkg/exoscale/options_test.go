@@ -1,37 +1,37 @@
 //go:build unit
 
-package config_test
+package exoscale_test
 
 import (
 	"testing"
 
-	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/exoscale"
 	"github.com/stretchr/testify/suite"
 )
 
-var cleanExoscale = config.Exoscale{
+var cleanOptions = exoscale.Options{
 	APIKey:    "key",
 	APISecret: "secret",
 	Zone:      "zone",
 }
 
-type ExoscaleTestSuite struct {
+type OptionsTestSuite struct {
 	suite.Suite
 }
 
-func (suite *ExoscaleTestSuite) TestValidate() {
+func (suite *OptionsTestSuite) TestValidate() {
 	tests := []struct {
-		input         *config.Exoscale
+		input         *exoscale.Options
 		isError       bool
 		errorContains []string
 		title         string
 	}{
 		{
-			input: &cleanExoscale,
+			input: &cleanOptions,
 			title: "Positive test",
 		},
 		{
-			input: &config.Exoscale{},
+			input: &exoscale.Options{},
 			title: "Positive test: Empty fields",
 		},
 	}
@@ -54,6 +54,6 @@ func (suite *ExoscaleTestSuite) TestValidate() {
 	}
 }
 
-func TestExoscaleTestSuite(t *testing.T) {
-	suite.Run(t, &ExoscaleTestSuite{})
+func TestOptionsTestSuite(t *testing.T) {
+	suite.Run(t, &OptionsTestSuite{})
 }
@@ -0,0 +1,99 @@
+package hetzner
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/squarefactory/cloud-burster/logger"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+type CloudConfigOpts struct {
+	Hostname          string
+	AuthorizedKeys    []string
+	PostScripts       config.PostScriptsOpts
+	DNS               []string
+	Search            string
+	CustomCloudConfig string
+}
+
+// cloudConfigTemplate leaves the network to DHCP: Hetzner serves the fixed IP of the private
+// network.
+const cloudConfigTemplate = `#cloud-config
+disable_root: false
+hostname: {{ .Hostname }}
+
+ssh_authorized_keys:
+{{- range .AuthorizedKeys }}
+  - {{ . }}
+{{- end }}
+
+write_files:
+{{- if .DNS }}
+  - path: /etc/resolv.conf
+    content: |
+{{- range .DNS }}
+      nameserver {{ . }}
+{{- end }}
+{{- if .Search }}
+      search {{ .Search }}
+{{- end }}
+{{- end }}
+
+{{- if .PostScripts.Git.Key }}
+  - path: /key
+    content: |-
+      {{- .PostScripts.Git.Key | nindent 6 }}
+    encoding: b64
+    permissions: '0600'
+{{- end }}
+
+{{- if and .PostScripts.Git.URL .PostScripts.Git.Ref }}
+
+runcmd:
+  - mkdir -p /configs && GIT_SSH_COMMAND='ssh -i /key -o UserKnownHostsFile=/dev/null -o StrictHostKeyChecking=no -o IdentitiesOnly=yes' git clone -b {{ .PostScripts.Git.Ref }} {{ .PostScripts.Git.URL }} /configs
+  - if [ -f /configs/post.sh ] && [ -x /configs/post.sh ]; then cd /configs && ./post.sh compute; fi
+  - [ rm, -f, /key ]
+  - [ chmod, -R, "g-rwx,o-rwx", /configs ]
+{{- end }}
+
+{{ .CustomCloudConfig }}
+`
+
+func validate(cloudConfig []byte) error {
+	m := make(map[interface{}]interface{})
+	err := yaml.Unmarshal(cloudConfig, &m)
+	if err != nil {
+		logger.I.Error(
+			"cloud config validation failed",
+			zap.Error(err),
+			zap.String("cloud-config", string(cloudConfig)),
+		)
+		return fmt.Errorf("cloud config validation failed: %s", err.Error())
+	}
+	return nil
+}
+
+func GenerateCloudConfig(options *CloudConfigOpts) ([]byte, error) {
+	t, err := template.New("cloud-config").Funcs(sprig.TxtFuncMap()).Parse(cloudConfigTemplate)
+	if err != nil {
+		return []byte{}, err
+	}
+
+	var out bytes.Buffer
+	if err := t.Execute(&out, options); err != nil {
+		return []byte{}, err
+	}
+
+	outb := out.Bytes()
+
+	if err := validate(outb); err != nil {
+		return []byte{}, err
+	}
+
+	return outb, nil
+}
@@ -0,0 +1,483 @@
+package hetzner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/squarefactory/cloud-burster/logger"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/middlewares"
+	"github.com/squarefactory/cloud-burster/pkg/provider"
+	"github.com/squarefactory/cloud-burster/utils/rollback"
+	"github.com/squarefactory/cloud-burster/utils/try"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/time/rate"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// LabelHostname is the label of the servers, whose value is the hostname.
+	LabelHostname = "cloud-burster/hostname"
+	// LabelManaged is the label of the SSH keys registered by cloud-burster.
+	LabelManaged = "cloud-burster/managed"
+)
+
+// DefaultRetry is the default retry policy of the data source.
+var DefaultRetry = try.Policy{
+	MaxElapsed:   2 * time.Minute,
+	InitialDelay: 2 * time.Second,
+	MaxDelay:     15 * time.Second,
+	Multiplier:   2,
+	Jitter:       0.2,
+	Retryable:    Retryable,
+}
+
+// Retryable returns true for the transient errors of the API, like a server locked by a
+// running action, and for the network errors. The failed actions are not retried.
+func Retryable(err error) bool {
+	var apiErr hcloud.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case hcloud.ErrorCodeLocked,
+			hcloud.ErrorCodeConflict,
+			hcloud.ErrorCodeRateLimitExceeded,
+			hcloud.ErrorCodeServiceError,
+			hcloud.ErrorCodeUnknownError,
+			hcloud.ErrorCodeMaintenance,
+			hcloud.ErrorCodeResourceUnavailable:
+			return true
+		}
+		return false
+	}
+	var actionErr hcloud.ActionError
+	return !errors.As(err, &actionErr)
+}
+
+type DataSource struct {
+	// Retry is the retry policy of the requests on a server, which is locked while an action
+	// runs.
+	Retry   try.Policy
+	client  *hcloud.Client
+	options *Options
+}
+
+// New returns a data source managing the servers of the project of the token.
+func New(opts *Options, limiter *rate.Limiter) (*DataSource, error) {
+	clientOpts := []hcloud.ClientOption{
+		hcloud.WithToken(opts.Token),
+		hcloud.WithApplication("cloud-burster", ""),
+		hcloud.WithHTTPClient(&http.Client{Transport: middlewares.Transport(limiter)}),
+	}
+	if opts.Endpoint != "" {
+		clientOpts = append(clientOpts, hcloud.WithEndpoint(opts.Endpoint))
+	}
+	return &DataSource{
+		Retry:   DefaultRetry,
+		client:  hcloud.NewClient(clientOpts...),
+		options: opts,
+	}, nil
+}
+
+// Create creates a stopped server with the cloud-config as user-data, attaches it to the
+// private network with the IP of the host, then starts it.
+func (s *DataSource) Create(
+	ctx context.Context,
+	host *config.Host,
+	cloud *config.Cloud,
+) error {
+	logger.I.Debug(
+		"Create called",
+		zap.Any("host", host),
+		zap.Any("cloud", cloud),
+	)
+	if cloud.Network == nil {
+		return errors.New("the network of the cloud is required")
+	}
+	if host.IPv6 != "" {
+		return errors.New("the private networks of Hetzner are IPv4-only")
+	}
+	var ip net.IP
+	if host.IP != "" {
+		if ip = net.ParseIP(host.IP); ip == nil {
+			return fmt.Errorf("invalid IP %s", host.IP)
+		}
+	}
+
+	serverType, err := s.FindServerType(ctx, host.FlavorName)
+	if err != nil {
+		return err
+	}
+	if host.DiskSize > serverType.Disk {
+		return fmt.Errorf(
+			"the disk of the server type %s is %d GB, %d GB required",
+			serverType.Name,
+			serverType.Disk,
+			host.DiskSize,
+		)
+	}
+	image, err := s.FindImage(ctx, host.ImageName, serverType.Architecture)
+	if err != nil {
+		return err
+	}
+	network, err := s.FindNetwork(ctx, cloud.Network.Name)
+	if err != nil {
+		return err
+	}
+	sshKeys, err := s.ensureSSHKeys(ctx, cloud.AuthorizedKeys)
+	if err != nil {
+		return err
+	}
+
+	var customConfig []byte
+	if len(cloud.CustomConfig) > 0 {
+		customConfig, err = yaml.Marshal(cloud.CustomConfig)
+		if err != nil {
+			return err
+		}
+	}
+	userData, err := GenerateCloudConfig(&CloudConfigOpts{
+		Hostname:          host.Name,
+		AuthorizedKeys:    cloud.AuthorizedKeys,
+		PostScripts:       cloud.PostScripts,
+		DNS:               cloud.Network.Nameservers(),
+		Search:            cloud.Network.Search,
+		CustomCloudConfig: string(customConfig),
+	})
+	if err != nil {
+		return err
+	}
+
+	tx := rollback.New("create " + host.Name)
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	createOpts := hcloud.ServerCreateOpts{
+		Name:       host.Name,
+		ServerType: serverType,
+		Image:      image,
+		SSHKeys:    sshKeys,
+		UserData:   string(userData),
+		// Started once attached to the private network
+		StartAfterCreate: hcloud.Ptr(false),
+		Labels:           map[string]string{LabelHostname: host.Name},
+	}
+	if s.options.Location != "" {
+		createOpts.Location = &hcloud.Location{Name: s.options.Location}
+	}
+	if s.options.PrivateOnly {
+		createOpts.PublicNet = &hcloud.ServerCreatePublicNet{}
+	}
+	result, _, err := s.client.Server.Create(ctx, createOpts)
+	if err != nil {
+		return fmt.Errorf("couldn't create the server: %w", err)
+	}
+	server := result.Server
+	tx.Push("server "+strconv.FormatInt(server.ID, 10), func(ctx context.Context) error {
+		return s.deleteServers(ctx, []*hcloud.Server{server})
+	})
+	actions := append([]*hcloud.Action{result.Action}, result.NextActions...)
+	if err := s.client.Action.WaitFor(ctx, actions...); err != nil {
+		return fmt.Errorf("couldn't create the server: %w", err)
+	}
+
+	action, err := try.Do(ctx, s.Retry, func() (*hcloud.Action, error) {
+		action, _, err := s.client.Server.AttachToNetwork(ctx, server, hcloud.ServerAttachToNetworkOpts{
+			Network: network,
+			IP:      ip,
+		})
+		return action, err
+	})
+	if err == nil {
+		err = s.client.Action.WaitFor(ctx, action)
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't attach the server to the network %s: %w", network.Name, err)
+	}
+
+	action, err = try.Do(ctx, s.Retry, func() (*hcloud.Action, error) {
+		action, _, err := s.client.Server.Poweron(ctx, server)
+		return action, err
+	})
+	if err == nil {
+		err = s.client.Action.WaitFor(ctx, action)
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't start the server: %w", err)
+	}
+
+	tx.Commit()
+	logger.I.Info(
+		"created server",
+		zap.String("name", host.Name),
+		zap.Int64("serverID", server.ID),
+	)
+	return nil
+}
+
+// Delete deletes the servers labeled with the hostname.
+func (s *DataSource) Delete(ctx context.Context, name string) error {
+	logger.I.Debug("Delete called", zap.String("name", name))
+
+	servers, err := s.servers(ctx, LabelHostname+"="+name)
+	if err != nil {
+		return err
+	}
+	if len(servers) == 0 {
+		return provider.ErrNotFound
+	}
+	return s.deleteServers(ctx, servers)
+}
+
+// List returns the servers labeled by cloud-burster.
+func (s *DataSource) List(ctx context.Context) ([]provider.Instance, error) {
+	servers, err := s.servers(ctx, LabelHostname)
+	if err != nil {
+		return nil, err
+	}
+	return s.toInstances(ctx, servers)
+}
+
+// Get returns the newest server labeled with the hostname.
+func (s *DataSource) Get(ctx context.Context, name string) (*provider.Instance, error) {
+	servers, err := s.servers(ctx, LabelHostname+"="+name)
+	if err != nil {
+		return nil, err
+	}
+	out, err := s.toInstances(ctx, servers)
+	if err != nil {
+		return nil, err
+	}
+	return provider.Newest(out, name)
+}
+
+// toInstances converts servers, whose private networks are only embedded by ID, and named
+// after the networks of the project.
+func (s *DataSource) toInstances(ctx context.Context, servers []*hcloud.Server) ([]provider.Instance, error) {
+	networkNames := make(map[int64]string)
+	attached := slices.ContainsFunc(servers, func(server *hcloud.Server) bool {
+		return len(server.PrivateNet) > 0
+	})
+	if attached {
+		networks, err := s.client.Network.All(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, network := range networks {
+			networkNames[network.ID] = network.Name
+		}
+	}
+	out := make([]provider.Instance, 0, len(servers))
+	for _, server := range servers {
+		out = append(out, toInstance(server, networkNames))
+	}
+	return out, nil
+}
+
+// FindServerType returns a server type by name, like cx22.
+func (s *DataSource) FindServerType(ctx context.Context, name string) (*hcloud.ServerType, error) {
+	serverType, _, err := s.client.ServerType.GetByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if serverType == nil {
+		return nil, fmt.Errorf("server type %s not found", name)
+	}
+	return serverType, nil
+}
+
+// FindImage returns an image of the architecture by ID, by name (like rocky-9), or the newest
+// snapshot whose description is name.
+func (s *DataSource) FindImage(
+	ctx context.Context,
+	name string,
+	architecture hcloud.Architecture,
+) (*hcloud.Image, error) {
+	image, _, err := s.client.Image.GetForArchitecture(ctx, name, architecture)
+	if err != nil {
+		return nil, err
+	}
+	if image != nil {
+		return image, nil
+	}
+
+	snapshots, err := s.client.Image.AllWithOpts(ctx, hcloud.ImageListOpts{
+		Type:         []hcloud.ImageType{hcloud.ImageTypeSnapshot},
+		Architecture: []hcloud.Architecture{architecture},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.Description != name {
+			continue
+		}
+		if image == nil || snapshot.Created.After(image.Created) {
+			image = snapshot
+		}
+	}
+	if image == nil {
+		return nil, fmt.Errorf("image %s not found for %s", name, architecture)
+	}
+	return image, nil
+}
+
+// FindNetwork returns a private network by name.
+func (s *DataSource) FindNetwork(ctx context.Context, name string) (*hcloud.Network, error) {
+	network, _, err := s.client.Network.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if network == nil {
+		return nil, fmt.Errorf("network %s not found", name)
+	}
+	return network, nil
+}
+
+// ensureSSHKeys returns the SSH keys of the project matching the authorized keys, and
+// registers the missing ones. Without SSH key, Hetzner sends a root password by mail.
+func (s *DataSource) ensureSSHKeys(ctx context.Context, authorizedKeys []string) ([]*hcloud.SSHKey, error) {
+	out := make([]*hcloud.SSHKey, 0, len(authorizedKeys))
+	for _, authorizedKey := range authorizedKeys {
+		publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(authorizedKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid authorized key %q: %w", authorizedKey, err)
+		}
+		fingerprint := ssh.FingerprintLegacyMD5(publicKey)
+		key, _, err := s.client.SSHKey.GetByFingerprint(ctx, fingerprint)
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			key, err = s.registerSSHKey(ctx, authorizedKey, fingerprint)
+			if err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, key)
+	}
+	return out, nil
+}
+
+// registerSSHKey registers an authorized key as an SSH key of the project.
+//
+// Parallel creations register the same keys: if another one won, its key is returned.
+func (s *DataSource) registerSSHKey(
+	ctx context.Context,
+	authorizedKey string,
+	fingerprint string,
+) (*hcloud.SSHKey, error) {
+	key, _, err := s.client.SSHKey.Create(ctx, hcloud.SSHKeyCreateOpts{
+		Name:      "cloud-burster-" + strings.ReplaceAll(fingerprint, ":", ""),
+		PublicKey: authorizedKey,
+		Labels:    map[string]string{LabelManaged: "true"},
+	})
+	if hcloud.IsError(err, hcloud.ErrorCodeUniquenessError) {
+		key, _, err = s.client.SSHKey.GetByFingerprint(ctx, fingerprint)
+		if err == nil && key == nil {
+			err = errors.New("registered by another operation, but not found")
+		}
+	} else if err == nil {
+		logger.I.Info("registered SSH key", zap.String("fingerprint", fingerprint))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("couldn't register the SSH key %s: %w", fingerprint, err)
+	}
+	return key, nil
+}
+
+// servers returns the servers matching the label selector.
+func (s *DataSource) servers(ctx context.Context, selector string) ([]*hcloud.Server, error) {
+	return s.client.Server.AllWithOpts(ctx, hcloud.ServerListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: selector},
+	})
+}
+
+// deleteServers deletes the servers and waits for their deletion.
+func (s *DataSource) deleteServers(ctx context.Context, servers []*hcloud.Server) error {
+	actions := make([]*hcloud.Action, 0, len(servers))
+	for _, server := range servers {
+		result, err := try.Do(ctx, s.Retry, func() (*hcloud.ServerDeleteResult, error) {
+			result, _, err := s.client.Server.DeleteWithResult(ctx, server)
+			return result, err
+		})
+		if hcloud.IsError(err, hcloud.ErrorCodeNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("couldn't delete the server %d: %w", server.ID, err)
+		}
+		actions = append(actions, result.Action)
+	}
+	return s.client.Action.WaitFor(ctx, actions...)
+}
+
+// toInstance converts a server into a provider-neutral record. networkNames maps the IDs of
+// the networks to their names.
+func toInstance(server *hcloud.Server, networkNames map[int64]string) provider.Instance {
+	created := server.Created
+	hostname, managed := server.Labels[LabelHostname]
+	out := provider.Instance{
+		Name:       hostname,
+		ProviderID: strconv.FormatInt(server.ID, 10),
+		State:      toState(server.Status),
+		CreatedAt:  &created,
+		Managed:    managed,
+	}
+	for _, private := range server.PrivateNet {
+		if private.IP != nil {
+			out.IPs = append(out.IPs, private.IP.String())
+		}
+		if private.Network == nil {
+			continue
+		}
+		name := private.Network.Name
+		if name == "" {
+			name = networkNames[private.Network.ID]
+		}
+		out.Networks = append(out.Networks, name)
+	}
+	if !server.PublicNet.IPv4.IsUnspecified() {
+		out.IPs = append(out.IPs, server.PublicNet.IPv4.IP.String())
+	}
+	if server.ServerType != nil {
+		out.Flavor = server.ServerType.Name
+	}
+	if server.Image != nil {
+		out.Image = server.Image.Name
+		if out.Image == "" {
+			out.Image = server.Image.Description
+		}
+	}
+	for _, volume := range server.Volumes {
+		out.Volumes = append(out.Volumes, strconv.FormatInt(volume.ID, 10))
+	}
+	sort.Strings(out.Volumes)
+	return out
+}
+
+func toState(status hcloud.ServerStatus) provider.State {
+	switch status {
+	case hcloud.ServerStatusInitializing, hcloud.ServerStatusStarting,
+		hcloud.ServerStatusRebuilding, hcloud.ServerStatusMigrating:
+		return provider.StatePending
+	case hcloud.ServerStatusRunning:
+		return provider.StateRunning
+	case hcloud.ServerStatusOff, hcloud.ServerStatusStopping:
+		return provider.StateStopped
+	case hcloud.ServerStatusDeleting:
+		return provider.StateDeleting
+	default:
+		return provider.StateUnknown
+	}
+}
@@ -0,0 +1,528 @@
+//go:build unit
+
+package hetzner_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/hetzner"
+	"github.com/squarefactory/cloud-burster/pkg/provider"
+	"github.com/stretchr/testify/suite"
+)
+
+const authorizedKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIDUnXMBGq6bV6H+c7P5QjDn1soeB6vkodi6OswcZsMwH nguye@PC-DARKNESS4"
+
+var cleanCloud = config.Cloud{
+	Type:           "hetzner",
+	AuthorizedKeys: []string{authorizedKey},
+	Network: &config.Network{
+		Name:       "net",
+		SubnetCIDR: "172.28.0.0/20",
+		DNS:        "1.1.1.1",
+		Gateway:    "172.28.0.1",
+	},
+}
+
+// responses are recorded responses of the Hetzner API, by request. The pagination is
+// omitted from the queries.
+var responses = map[string]string{
+	"GET /server_types?name=cx22": `{"server_types": [
+		{"id": 22, "name": "cx22", "cores": 2, "memory": 4.0, "disk": 40, "architecture": "x86"}
+	]}`,
+	"GET /server_types?name=cx99": `{"server_types": []}`,
+	"GET /images?architecture=x86&include_deprecated=true&name=rocky-9": `{"images": [
+		{"id": 10, "type": "system", "name": "rocky-9", "description": "Rocky Linux 9", "architecture": "x86", "created": "2023-01-01T00:00:00+00:00"}
+	]}`,
+	"GET /images?architecture=x86&include_deprecated=true&name=golden":  `{"images": []}`,
+	"GET /images?architecture=x86&include_deprecated=true&name=missing": `{"images": []}`,
+	"GET /images?architecture=x86&type=snapshot": `{"images": [
+		{"id": 11, "type": "snapshot", "name": null, "description": "golden", "architecture": "x86", "created": "2024-01-01T00:00:00+00:00"},
+		{"id": 12, "type": "snapshot", "name": null, "description": "golden", "architecture": "x86", "created": "2024-02-01T00:00:00+00:00"},
+		{"id": 13, "type": "snapshot", "name": null, "description": "other", "architecture": "x86", "created": "2024-03-01T00:00:00+00:00"}
+	]}`,
+	"GET /networks": `{"networks": [
+		{"id": 20, "name": "net", "ip_range": "172.28.0.0/16"},
+		{"id": 21, "name": "other", "ip_range": "10.0.0.0/16"}
+	]}`,
+	"GET /networks?name=net": `{"networks": [
+		{"id": 20, "name": "net", "ip_range": "172.28.0.0/16", "subnets": [{"type": "cloud", "ip_range": "172.28.0.0/20", "network_zone": "eu-central", "gateway": "172.28.0.1"}]}
+	]}`,
+	"GET /ssh_keys?fingerprint=01:d0:d4:94:d9:61:35:f4:6a:c0:4b:4b:7b:e4:20:06": `{"ssh_keys": []}`,
+	"POST /ssh_keys": `{"ssh_key": {"id": 30, "name": "cloud-burster-01d0d494d96135f46ac04b4b7be42006", "fingerprint": "01:d0:d4:94:d9:61:35:f4:6a:c0:4b:4b:7b:e4:20:06"}}`,
+	"POST /servers": `{
+		"server": {"id": 40, "name": "cn1", "status": "initializing", "created": "2024-04-01T00:00:00+00:00", "labels": {"cloud-burster/hostname": "cn1"}},
+		"action": {"id": 1, "command": "create_server", "status": "success"},
+		"next_actions": [{"id": 2, "command": "start_server", "status": "success"}],
+		"root_password": null
+	}`,
+	"POST /servers/40/actions/attach_to_network": `{"action": {"id": 3, "command": "attach_to_network", "status": "success"}}`,
+	"POST /servers/40/actions/poweron":           `{"action": {"id": 4, "command": "start_server", "status": "success"}}`,
+	"DELETE /servers/40":                         `{"action": {"id": 5, "command": "delete_server", "status": "success"}}`,
+	"DELETE /servers/41":                         `{"action": {"id": 6, "command": "delete_server", "status": "success"}}`,
+	"GET /servers?label_selector=cloud-burster/hostname": `{"servers": [
+		{
+			"id": 40, "name": "cn1", "status": "running", "created": "2024-04-01T00:00:00+00:00",
+			"labels": {"cloud-burster/hostname": "cn1"},
+			"public_net": {"ipv4": {"ip": "203.0.113.1"}},
+			"private_net": [{"network": 20, "ip": "172.28.1.1", "mac_address": "86:00:00:00:00:01"}],
+			"server_type": {"id": 22, "name": "cx22"},
+			"image": {"id": 10, "name": "rocky-9"}
+		},
+		{
+			"id": 41, "name": "cn2", "status": "off", "created": "2024-04-02T00:00:00+00:00",
+			"labels": {"cloud-burster/hostname": "cn2"},
+			"public_net": {"ipv4": null},
+			"private_net": [],
+			"server_type": {"id": 22, "name": "cx22"},
+			"image": {"id": 12, "name": null, "description": "golden"}
+		}
+	]}`,
+	"GET /servers?label_selector=cloud-burster/hostname=cn1": `{"servers": [
+		{
+			"id": 40, "name": "cn1", "status": "running", "created": "2024-04-01T00:00:00+00:00",
+			"labels": {"cloud-burster/hostname": "cn1"},
+			"private_net": [{"network": 20, "ip": "172.28.1.1", "mac_address": "86:00:00:00:00:01"}],
+			"server_type": {"id": 22, "name": "cx22"},
+			"image": {"id": 10, "name": "rocky-9"}
+		}
+	]}`,
+	"GET /servers?label_selector=cloud-burster/hostname=missing": `{"servers": []}`,
+}
+
+// replay serves the responses, and records the requests with their bodies.
+type replay struct {
+	mu        sync.Mutex
+	responses map[string]string
+	// failures replace the responses of some requests by an API error.
+	failures map[string]string
+	// failedOnce replace the first response of some requests by an API error.
+	failedOnce map[string]string
+	// queued are served in order before the responses, to replay changes.
+	queued   map[string][]string
+	requests []string
+	bodies   map[string]map[string]interface{}
+}
+
+func (r *replay) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	query.Del("page")
+	query.Del("per_page")
+	key := req.Method + " " + req.URL.Path
+	if len(query) > 0 {
+		unescaped, _ := url.QueryUnescape(query.Encode())
+		key += "?" + unescaped
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, key)
+	if data, _ := io.ReadAll(req.Body); len(data) > 0 {
+		body := make(map[string]interface{})
+		_ = json.Unmarshal(data, &body)
+		r.bodies[key] = body
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	code, ok := r.failures[key]
+	if once, found := r.failedOnce[key]; found {
+		code, ok = once, true
+		delete(r.failedOnce, key)
+	}
+	if ok {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		fmt.Fprintf(w, `{"error": {"code": %q, "message": "replayed failure"}}`, code)
+		return
+	}
+	response, ok := r.responses[key]
+	if queue := r.queued[key]; len(queue) > 0 {
+		response, ok = queue[0], true
+		r.queued[key] = queue[1:]
+	}
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"error": {"code": "not_found", "message": "no recorded response for %s"}}`, key)
+		return
+	}
+	fmt.Fprint(w, response)
+}
+
+type DataSourceTestSuite struct {
+	suite.Suite
+	replay *replay
+	server *httptest.Server
+	impl   *hetzner.DataSource
+}
+
+func (suite *DataSourceTestSuite) BeforeTest(suiteName, testName string) {
+	suite.replay = &replay{
+		responses: responses,
+		failures:  make(map[string]string),
+		queued:    make(map[string][]string),
+		bodies:    make(map[string]map[string]interface{}),
+	}
+	suite.server = httptest.NewServer(suite.replay)
+	impl, err := hetzner.New(&hetzner.Options{
+		Token:    "token",
+		Endpoint: suite.server.URL,
+		Location: "fsn1",
+	}, nil)
+	suite.Require().NoError(err)
+	impl.Retry.InitialDelay = time.Millisecond
+	suite.impl = impl
+}
+
+func (suite *DataSourceTestSuite) AfterTest(suiteName, testName string) {
+	suite.server.Close()
+}
+
+func (suite *DataSourceTestSuite) TestCreate() {
+	tests := []struct {
+		host             config.Host
+		failures         map[string]string
+		failedOnce       map[string]string
+		queued           map[string][]string
+		sshKeyID         float64
+		isError          bool
+		errorContains    []string
+		expectedRequests []string
+		title            string
+	}{
+		{
+			host: config.Host{
+				Name:       "cn1",
+				DiskSize:   40,
+				FlavorName: "cx22",
+				ImageName:  "rocky-9",
+				IP:         "172.28.1.1",
+			},
+			expectedRequests: []string{
+				"GET /server_types?name=cx22",
+				"GET /images?architecture=x86&include_deprecated=true&name=rocky-9",
+				"GET /networks?name=net",
+				"GET /ssh_keys?fingerprint=01:d0:d4:94:d9:61:35:f4:6a:c0:4b:4b:7b:e4:20:06",
+				"POST /ssh_keys",
+				"POST /servers",
+				"POST /servers/40/actions/attach_to_network",
+				"POST /servers/40/actions/poweron",
+			},
+			sshKeyID: 30,
+			title:    "Positive test",
+		},
+		{
+			host: config.Host{
+				Name:       "cn1",
+				DiskSize:   40,
+				FlavorName: "cx22",
+				ImageName:  "rocky-9",
+				IP:         "172.28.1.1",
+			},
+			failures: map[string]string{
+				"POST /ssh_keys": "uniqueness_error",
+			},
+			queued: map[string][]string{
+				"GET /ssh_keys?fingerprint=01:d0:d4:94:d9:61:35:f4:6a:c0:4b:4b:7b:e4:20:06": {
+					`{"ssh_keys": []}`,
+					`{"ssh_keys": [{"id": 31, "name": "cloud-burster-01d0d494d96135f46ac04b4b7be42006", "fingerprint": "01:d0:d4:94:d9:61:35:f4:6a:c0:4b:4b:7b:e4:20:06"}]}`,
+				},
+			},
+			expectedRequests: []string{
+				"GET /server_types?name=cx22",
+				"GET /images?architecture=x86&include_deprecated=true&name=rocky-9",
+				"GET /networks?name=net",
+				"GET /ssh_keys?fingerprint=01:d0:d4:94:d9:61:35:f4:6a:c0:4b:4b:7b:e4:20:06",
+				"POST /ssh_keys",
+				"GET /ssh_keys?fingerprint=01:d0:d4:94:d9:61:35:f4:6a:c0:4b:4b:7b:e4:20:06",
+				"POST /servers",
+				"POST /servers/40/actions/attach_to_network",
+				"POST /servers/40/actions/poweron",
+			},
+			sshKeyID: 31,
+			title:    "SSH key registered by a parallel creation",
+		},
+		{
+			host: config.Host{
+				Name:       "cn1",
+				DiskSize:   40,
+				FlavorName: "cx99",
+				ImageName:  "rocky-9",
+				IP:         "172.28.1.1",
+			},
+			isError:       true,
+			errorContains: []string{"server type cx99 not found"},
+			title:         "Unknown server type",
+		},
+		{
+			host: config.Host{
+				Name:       "cn1",
+				DiskSize:   80,
+				FlavorName: "cx22",
+				ImageName:  "rocky-9",
+			},
+			isError:       true,
+			errorContains: []string{"the disk of the server type cx22 is 40 GB, 80 GB required"},
+			title:         "Disk too large",
+		},
+		{
+			host: config.Host{
+				Name:       "cn1",
+				DiskSize:   40,
+				FlavorName: "cx22",
+				ImageName:  "rocky-9",
+				IP:         "172.28.1.1",
+				IPv6:       "fd00::1",
+			},
+			isError:       true,
+			errorContains: []string{"IPv4-only"},
+			title:         "IPv6",
+		},
+		{
+			host: config.Host{
+				Name:       "cn1",
+				DiskSize:   40,
+				FlavorName: "cx22",
+				ImageName:  "rocky-9",
+				IP:         "172.28.1.1",
+			},
+			failures: map[string]string{
+				"POST /servers/40/actions/attach_to_network": "ip_not_available",
+			},
+			isError:       true,
+			errorContains: []string{"couldn't attach the server to the network net", "ip_not_available"},
+			expectedRequests: []string{
+				"GET /server_types?name=cx22",
+				"GET /images?architecture=x86&include_deprecated=true&name=rocky-9",
+				"GET /networks?name=net",
+				"GET /ssh_keys?fingerprint=01:d0:d4:94:d9:61:35:f4:6a:c0:4b:4b:7b:e4:20:06",
+				"POST /ssh_keys",
+				"POST /servers",
+				"POST /servers/40/actions/attach_to_network",
+				"DELETE /servers/40",
+			},
+			title: "Rollback",
+		},
+		{
+			host: config.Host{
+				Name:       "cn1",
+				DiskSize:   40,
+				FlavorName: "cx22",
+				ImageName:  "rocky-9",
+				IP:         "172.28.1.1",
+			},
+			failedOnce: map[string]string{
+				"POST /servers/40/actions/attach_to_network": "locked",
+			},
+			expectedRequests: []string{
+				"GET /server_types?name=cx22",
+				"GET /images?architecture=x86&include_deprecated=true&name=rocky-9",
+				"GET /networks?name=net",
+				"GET /ssh_keys?fingerprint=01:d0:d4:94:d9:61:35:f4:6a:c0:4b:4b:7b:e4:20:06",
+				"POST /ssh_keys",
+				"POST /servers",
+				"POST /servers/40/actions/attach_to_network",
+				"POST /servers/40/actions/attach_to_network",
+				"POST /servers/40/actions/poweron",
+			},
+			sshKeyID: 30,
+			title:    "Server locked by the creation",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Arrange
+			suite.replay.requests = nil
+			suite.replay.failures = tt.failures
+			suite.replay.failedOnce = tt.failedOnce
+			suite.replay.queued = tt.queued
+
+			// Act
+			err := suite.impl.Create(context.Background(), &tt.host, &cleanCloud)
+
+			// Assert
+			if tt.isError {
+				suite.Error(err)
+				for _, contain := range tt.errorContains {
+					suite.ErrorContains(err, contain)
+				}
+			} else {
+				suite.NoError(err)
+				server := suite.replay.bodies["POST /servers"]
+				suite.Equal("cn1", server["name"])
+				suite.Equal(false, server["start_after_create"])
+				suite.Equal("fsn1", server["location"])
+				suite.Equal([]interface{}{tt.sshKeyID}, server["ssh_keys"])
+				suite.Equal(map[string]interface{}{"cloud-burster/hostname": "cn1"}, server["labels"])
+				suite.Contains(server["user_data"], "hostname: cn1")
+				suite.Contains(server["user_data"], authorizedKey)
+				suite.Equal(map[string]interface{}{
+					"network": float64(20),
+					"ip":      "172.28.1.1",
+				}, suite.replay.bodies["POST /servers/40/actions/attach_to_network"])
+			}
+			if tt.expectedRequests != nil {
+				suite.Equal(tt.expectedRequests, suite.replay.requests)
+			}
+		})
+	}
+}
+
+func (suite *DataSourceTestSuite) TestDelete() {
+	tests := []struct {
+		name             string
+		isError          bool
+		errorIs          error
+		expectedRequests []string
+		title            string
+	}{
+		{
+			name: "cn1",
+			expectedRequests: []string{
+				"GET /servers?label_selector=cloud-burster/hostname=cn1",
+				"DELETE /servers/40",
+			},
+			title: "Positive test",
+		},
+		{
+			name:    "missing",
+			isError: true,
+			errorIs: provider.ErrNotFound,
+			expectedRequests: []string{
+				"GET /servers?label_selector=cloud-burster/hostname=missing",
+			},
+			title: "Not found",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Arrange
+			suite.replay.requests = nil
+
+			// Act
+			err := suite.impl.Delete(context.Background(), tt.name)
+
+			// Assert
+			if tt.isError {
+				suite.ErrorIs(err, tt.errorIs)
+			} else {
+				suite.NoError(err)
+			}
+			suite.Equal(tt.expectedRequests, suite.replay.requests)
+		})
+	}
+}
+
+func (suite *DataSourceTestSuite) TestList() {
+	// Act
+	instances, err := suite.impl.List(context.Background())
+
+	// Assert
+	suite.NoError(err)
+	suite.Require().Len(instances, 2)
+	suite.Equal("cn1", instances[0].Name)
+	suite.Equal("40", instances[0].ProviderID)
+	suite.Equal(provider.StateRunning, instances[0].State)
+	suite.Equal([]string{"172.28.1.1", "203.0.113.1"}, instances[0].IPs)
+	suite.Equal([]string{"net"}, instances[0].Networks)
+	suite.True(instances[0].Managed)
+	suite.Equal("cx22", instances[0].Flavor)
+	suite.Equal("rocky-9", instances[0].Image)
+	suite.Equal("cn2", instances[1].Name)
+	suite.Equal(provider.StateStopped, instances[1].State)
+	suite.Empty(instances[1].IPs)
+	suite.Equal("golden", instances[1].Image)
+}
+
+func (suite *DataSourceTestSuite) TestGet() {
+	tests := []struct {
+		name    string
+		errorIs error
+		title   string
+	}{
+		{
+			name:  "cn1",
+			title: "Positive test",
+		},
+		{
+			name:    "missing",
+			errorIs: provider.ErrNotFound,
+			title:   "Not found",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Act
+			instance, err := suite.impl.Get(context.Background(), tt.name)
+
+			// Assert
+			if tt.errorIs != nil {
+				suite.ErrorIs(err, tt.errorIs)
+			} else {
+				suite.NoError(err)
+				suite.Equal(tt.name, instance.Name)
+				suite.Equal([]string{"172.28.1.1"}, instance.IPs)
+			}
+		})
+	}
+}
+
+func (suite *DataSourceTestSuite) TestFindImage() {
+	tests := []struct {
+		name          string
+		expected      int64
+		isError       bool
+		errorContains []string
+		title         string
+	}{
+		{
+			name:     "rocky-9",
+			expected: 10,
+			title:    "By name",
+		},
+		{
+			name:     "golden",
+			expected: 12,
+			title:    "Newest snapshot by description",
+		},
+		{
+			name:          "missing",
+			isError:       true,
+			errorContains: []string{"image missing not found for x86"},
+			title:         "Not found",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Act
+			image, err := suite.impl.FindImage(context.Background(), tt.name, hcloud.ArchitectureX86)
+
+			// Assert
+			if tt.isError {
+				suite.Error(err)
+				for _, contain := range tt.errorContains {
+					suite.ErrorContains(err, contain)
+				}
+			} else {
+				suite.NoError(err)
+				suite.Equal(tt.expected, image.ID)
+			}
+		})
+	}
+}
+
+func TestDataSourceTestSuite(t *testing.T) {
+	suite.Run(t, &DataSourceTestSuite{})
+}
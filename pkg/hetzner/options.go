@@ -0,0 +1,40 @@
+package hetzner
+
+import (
+	"github.com/squarefactory/cloud-burster/pkg/cloud"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	validation "github.com/squarefactory/cloud-burster/validate"
+)
+
+func init() {
+	cloud.Register("hetzner", cloud.Provider{
+		Description: "Hetzner Cloud servers, attached to a private network.",
+		Options:     func() config.ProviderOptions { return &Options{} },
+		New: func(conf *config.Cloud) (cloud.DataSource, error) {
+			ds, err := New(conf.Provider.(*Options), conf.RateLimit.Limiter())
+			if err != nil {
+				return nil, err
+			}
+			ds.Retry = conf.Retry.Apply(ds.Retry)
+			return ds, nil
+		},
+	})
+}
+
+// Options are the options of the provider block of the hetzner clouds.
+type Options struct {
+	// Token is an API token of the project, with read and write permissions.
+	Token string `yaml:"token" validate:"required"`
+	// Location is the location of the servers, like fsn1. It must be in the network zone of
+	// the private network. Hetzner picks one if empty.
+	Location string `yaml:"location,omitempty"`
+	// Endpoint overrides the endpoint of the API.
+	Endpoint string `yaml:"endpoint,omitempty" validate:"omitempty,url"`
+	// PrivateOnly disables the public IPv4 and IPv6 of the servers, which then reach the
+	// internet through the gateway of the private network.
+	PrivateOnly bool `yaml:"privateOnly,omitempty"`
+}
+
+func (o *Options) Validate() error {
+	return validation.I.Struct(o)
+}
@@ -0,0 +1,69 @@
+//go:build unit
+
+package hetzner_test
+
+import (
+	"testing"
+
+	"github.com/squarefactory/cloud-burster/pkg/hetzner"
+	"github.com/stretchr/testify/suite"
+)
+
+var cleanOptions = hetzner.Options{
+	Token:    "token",
+	Location: "fsn1",
+}
+
+type OptionsTestSuite struct {
+	suite.Suite
+}
+
+func (suite *OptionsTestSuite) TestValidate() {
+	tests := []struct {
+		input         *hetzner.Options
+		isError       bool
+		errorContains []string
+		title         string
+	}{
+		{
+			input: &cleanOptions,
+			title: "Positive test",
+		},
+		{
+			input:         &hetzner.Options{},
+			isError:       true,
+			errorContains: []string{"Token", "required"},
+			title:         "Required fields",
+		},
+		{
+			input: &hetzner.Options{
+				Token:    cleanOptions.Token,
+				Endpoint: "localhost",
+			},
+			isError:       true,
+			errorContains: []string{"Endpoint", "url"},
+			title:         "Valid endpoint",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Act
+			err := tt.input.Validate()
+
+			// Assert
+			if tt.isError {
+				suite.Error(err)
+				for _, contain := range tt.errorContains {
+					suite.ErrorContains(err, contain)
+				}
+			} else {
+				suite.NoError(err)
+			}
+		})
+	}
+}
+
+func TestOptionsTestSuite(t *testing.T) {
+	suite.Run(t, &OptionsTestSuite{})
+}
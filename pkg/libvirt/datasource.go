@@ -0,0 +1,503 @@
+// Package libvirt manages the instances of a cloud as the domains of a libvirt hypervisor.
+//
+// Each domain boots on a qcow2 overlay of its image, created in the storage pool, and gets
+// its cloud-config and static IPs from a NoCloud seed ISO uploaded next to it. The domains
+// carry Metadata, which tells them apart from the other domains of the hypervisor.
+package libvirt
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/digitalocean/go-libvirt"
+	"github.com/squarefactory/cloud-burster/logger"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/provider"
+	"github.com/squarefactory/cloud-burster/utils/rollback"
+	"github.com/squarefactory/cloud-burster/utils/try"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// DefaultRetry is the default retry policy of the connections to the hypervisor.
+var DefaultRetry = try.Policy{
+	MaxElapsed:   30 * time.Second,
+	InitialDelay: time.Second,
+	MaxDelay:     5 * time.Second,
+	Multiplier:   2,
+	Jitter:       0.2,
+}
+
+type DataSource struct {
+	// Retry is the retry policy of the connections to the hypervisor.
+	Retry   try.Policy
+	uri     *url.URL
+	options *Options
+	// limiter bounds the connections to the hypervisor, nil for no limit.
+	limiter *rate.Limiter
+}
+
+// New returns a data source managing the domains of the hypervisor of the options. Each
+// operation opens a connection, which waits for a token of limiter if not nil.
+func New(opts *Options, limiter *rate.Limiter) (*DataSource, error) {
+	uri, err := url.Parse(opts.URI)
+	if err != nil {
+		return nil, err
+	}
+	return &DataSource{
+		Retry:   DefaultRetry,
+		uri:     uri,
+		options: opts,
+		limiter: limiter,
+	}, nil
+}
+
+// Create creates the overlay and the seed of the host in the storage pool, then defines and
+// starts its domain on the network of the cloud.
+func (s *DataSource) Create(
+	ctx context.Context,
+	host *config.Host,
+	cloud *config.Cloud,
+) error {
+	logger.I.Debug(
+		"Create called",
+		zap.Any("host", host),
+		zap.Any("cloud", cloud),
+	)
+	if cloud.Network == nil {
+		return errors.New("the network of the cloud is required")
+	}
+	flavor, ok := s.options.Flavors[host.FlavorName]
+	if !ok {
+		return fmt.Errorf(
+			"unknown flavor %q, expected one of: %s",
+			host.FlavorName,
+			strings.Join(s.flavorNames(), ", "),
+		)
+	}
+
+	mac, err := randomMAC()
+	if err != nil {
+		return err
+	}
+	createdAt := time.Now().UTC().Truncate(time.Second)
+	seed, err := NewSeed(host, cloud, mac, fmt.Sprintf("%s-%d", host.Name, createdAt.Unix()))
+	if err != nil {
+		return err
+	}
+	iso, err := seed.ISO()
+	if err != nil {
+		return err
+	}
+
+	pool := s.options.PoolName()
+	diskName := host.Name + ".qcow2"
+	seedName := host.Name + "-seed.iso"
+	metadata := Metadata{
+		Hostname:  host.Name,
+		Network:   cloud.Network.Name,
+		Flavor:    host.FlavorName,
+		Image:     host.ImageName,
+		CreatedAt: createdAt,
+		Pool:      pool,
+		Volumes:   []string{diskName, seedName},
+	}
+	for _, ip := range []string{host.IP, host.IPv6} {
+		if ip != "" {
+			metadata.IPs = append(metadata.IPs, ip)
+		}
+	}
+
+	tx := rollback.New("create " + host.Name)
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	if err := s.do(ctx, func(l *libvirt.Libvirt) error {
+		if _, err := l.DomainLookupByName(host.Name); err == nil {
+			return fmt.Errorf("the domain %s already exists", host.Name)
+		} else if !libvirt.IsNotFound(err) {
+			return err
+		}
+		p, err := l.StoragePoolLookupByName(pool)
+		if err != nil {
+			return fmt.Errorf("couldn't find the storage pool %s: %w", pool, err)
+		}
+
+		base, err := findVolume(l, p, host.ImageName)
+		if err != nil {
+			return fmt.Errorf("couldn't find the image %s in the pool %s: %w", host.ImageName, pool, err)
+		}
+		overlay := volumeXML{Name: diskName}
+		overlay.Capacity.Unit = "GiB"
+		overlay.Capacity.Value = uint64(host.DiskSize)
+		overlay.Target.Format.Type = "qcow2"
+		overlay.BackingStore = &backingStoreXML{
+			Path:   base.Target.Path,
+			Format: base.Target.Format,
+		}
+		_, diskPath, err := s.createVolume(ctx, tx, l, p, &overlay)
+		if err != nil {
+			return fmt.Errorf("couldn't create the disk: %w", err)
+		}
+
+		seedVolume := volumeXML{Name: seedName}
+		seedVolume.Capacity.Unit = "B"
+		seedVolume.Capacity.Value = uint64(len(iso))
+		seedVolume.Target.Format.Type = "raw"
+		vol, seedPath, err := s.createVolume(ctx, tx, l, p, &seedVolume)
+		if err != nil {
+			return fmt.Errorf("couldn't create the seed: %w", err)
+		}
+		if err := l.StorageVolUpload(vol, bytes.NewReader(iso), 0, uint64(len(iso)), 0); err != nil {
+			return fmt.Errorf("couldn't upload the seed: %w", err)
+		}
+
+		def := Definition{
+			Name:     host.Name,
+			Type:     s.options.Type(),
+			Flavor:   flavor,
+			Disk:     diskPath,
+			Seed:     seedPath,
+			Network:  cloud.Network.Name,
+			MAC:      mac,
+			Metadata: metadata,
+		}
+		domXML, err := def.XML()
+		if err != nil {
+			return err
+		}
+		dom, err := l.DomainDefineXML(domXML)
+		if err != nil {
+			return fmt.Errorf("couldn't define the domain: %w", err)
+		}
+		tx.Push("domain "+host.Name, func(ctx context.Context) error {
+			return s.do(ctx, func(l *libvirt.Libvirt) error {
+				return undefine(l, host.Name)
+			})
+		})
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := l.DomainCreate(dom); err != nil {
+			return fmt.Errorf("couldn't start the domain: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	tx.Commit()
+	logger.I.Info(
+		"created domain",
+		zap.String("name", host.Name),
+		zap.String("uri", s.options.URI),
+	)
+	return nil
+}
+
+// Delete destroys and undefines the domain, then deletes its volumes.
+func (s *DataSource) Delete(ctx context.Context, name string) error {
+	logger.I.Debug("Delete called", zap.String("name", name))
+
+	return s.do(ctx, func(l *libvirt.Libvirt) error {
+		dom, metadata, err := lookup(l, name)
+		if err != nil {
+			return err
+		}
+		if err := l.DomainDestroy(dom); err != nil && !hasCode(err, libvirt.ErrOperationInvalid) {
+			// ErrOperationInvalid: the domain is not running
+			return fmt.Errorf("couldn't destroy the domain: %w", err)
+		}
+		if err := undefine(l, name); err != nil {
+			return err
+		}
+
+		p, err := l.StoragePoolLookupByName(metadata.Pool)
+		if err != nil {
+			return fmt.Errorf("couldn't find the storage pool %s: %w", metadata.Pool, err)
+		}
+		var errs []error
+		for _, volume := range metadata.Volumes {
+			if err := deleteVolume(l, p, volume); err != nil {
+				errs = append(errs, fmt.Errorf("couldn't delete the volume %s: %w", volume, err))
+			}
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// List returns the domains created by cloud-burster.
+func (s *DataSource) List(ctx context.Context) ([]provider.Instance, error) {
+	var out []provider.Instance
+	err := s.do(ctx, func(l *libvirt.Libvirt) error {
+		domains, _, err := l.ConnectListAllDomains(
+			1,
+			libvirt.ConnectListDomainsActive|libvirt.ConnectListDomainsInactive,
+		)
+		if err != nil {
+			return err
+		}
+		out = make([]provider.Instance, 0, len(domains))
+		for _, dom := range domains {
+			metadata, err := getMetadata(l, dom)
+			if errors.Is(err, provider.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			instance, err := toInstance(l, dom, metadata)
+			if err != nil {
+				return err
+			}
+			out = append(out, instance)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Get returns the domain named name, if created by cloud-burster.
+func (s *DataSource) Get(ctx context.Context, name string) (*provider.Instance, error) {
+	var out provider.Instance
+	err := s.do(ctx, func(l *libvirt.Libvirt) error {
+		dom, metadata, err := lookup(l, name)
+		if err != nil {
+			return err
+		}
+		out, err = toInstance(l, dom, metadata)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// do calls fn with a connection to the hypervisor. The connection is retried with the retry
+// policy, but not fn. The calls of the library cannot be canceled: the connection is closed
+// if ctx is done, which fails them.
+func (s *DataSource) do(ctx context.Context, fn func(l *libvirt.Libvirt) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	l, err := try.Do(ctx, s.Retry, func() (*libvirt.Libvirt, error) {
+		if s.limiter != nil {
+			if err := s.limiter.Wait(ctx); err != nil {
+				return nil, try.Permanent(err)
+			}
+		}
+		return libvirt.ConnectToURI(s.uri)
+	})
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		_ = l.Disconnect()
+	})
+	defer func() {
+		if stop() {
+			if err := l.Disconnect(); err != nil {
+				logger.I.Warn("couldn't disconnect from libvirt", zap.Error(err))
+			}
+		}
+	}()
+
+	err = fn(l)
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		return fmt.Errorf("%w: %w", ctxErr, err)
+	}
+	return err
+}
+
+// createVolume creates a volume in the pool, whose deletion is pushed to tx, and returns it
+// with its path.
+func (s *DataSource) createVolume(
+	ctx context.Context,
+	tx *rollback.Stack,
+	l *libvirt.Libvirt,
+	p libvirt.StoragePool,
+	volume *volumeXML,
+) (libvirt.StorageVol, string, error) {
+	desc, err := xml.Marshal(volume)
+	if err != nil {
+		return libvirt.StorageVol{}, "", err
+	}
+	vol, err := l.StorageVolCreateXML(p, string(desc), 0)
+	if err != nil {
+		return vol, "", err
+	}
+	tx.Push("volume "+volume.Name, func(ctx context.Context) error {
+		return s.do(ctx, func(l *libvirt.Libvirt) error {
+			pool, err := l.StoragePoolLookupByName(p.Name)
+			if err != nil {
+				return err
+			}
+			return deleteVolume(l, pool, volume.Name)
+		})
+	})
+	if err := ctx.Err(); err != nil {
+		return vol, "", err
+	}
+	path, err := l.StorageVolGetPath(vol)
+	return vol, path, err
+}
+
+func (s *DataSource) flavorNames() []string {
+	out := make([]string, 0, len(s.options.Flavors))
+	for name := range s.options.Flavors {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// findVolume returns the description of the volume name of the pool.
+func findVolume(l *libvirt.Libvirt, p libvirt.StoragePool, name string) (*volumeXML, error) {
+	vol, err := l.StorageVolLookupByName(p, name)
+	if err != nil {
+		return nil, err
+	}
+	desc, err := l.StorageVolGetXMLDesc(vol, 0)
+	if err != nil {
+		return nil, err
+	}
+	var out volumeXML
+	if err := xml.Unmarshal([]byte(desc), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// deleteVolume deletes the volume name of the pool, if it exists.
+func deleteVolume(l *libvirt.Libvirt, p libvirt.StoragePool, name string) error {
+	vol, err := l.StorageVolLookupByName(p, name)
+	if hasCode(err, libvirt.ErrNoStorageVol) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return l.StorageVolDelete(vol, libvirt.StorageVolDeleteNormal)
+}
+
+// undefine removes the definition of an inactive domain.
+func undefine(l *libvirt.Libvirt, name string) error {
+	dom, err := l.DomainLookupByName(name)
+	if libvirt.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := l.DomainUndefineFlags(
+		dom,
+		libvirt.DomainUndefineManagedSave|libvirt.DomainUndefineSnapshotsMetadata|libvirt.DomainUndefineNvram,
+	); err != nil {
+		return fmt.Errorf("couldn't undefine the domain: %w", err)
+	}
+	return nil
+}
+
+// lookup returns the domain named name and its metadata, or provider.ErrNotFound if the
+// domain does not exist or was not created by cloud-burster.
+func lookup(l *libvirt.Libvirt, name string) (libvirt.Domain, *Metadata, error) {
+	dom, err := l.DomainLookupByName(name)
+	if libvirt.IsNotFound(err) {
+		return dom, nil, provider.ErrNotFound
+	}
+	if err != nil {
+		return dom, nil, err
+	}
+	metadata, err := getMetadata(l, dom)
+	return dom, metadata, err
+}
+
+// getMetadata returns the metadata of the domain, or provider.ErrNotFound if it has none.
+func getMetadata(l *libvirt.Libvirt, dom libvirt.Domain) (*Metadata, error) {
+	desc, err := l.DomainGetMetadata(
+		dom,
+		int32(libvirt.DomainMetadataElement),
+		libvirt.OptString{MetadataNamespace},
+		libvirt.DomainAffectCurrent,
+	)
+	if hasCode(err, libvirt.ErrNoDomainMetadata) {
+		return nil, provider.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out Metadata
+	if err := xml.Unmarshal([]byte(desc), &out); err != nil {
+		return nil, fmt.Errorf("invalid metadata of the domain %s: %w", dom.Name, err)
+	}
+	return &out, nil
+}
+
+func toInstance(l *libvirt.Libvirt, dom libvirt.Domain, metadata *Metadata) (provider.Instance, error) {
+	state, _, err := l.DomainGetState(dom, 0)
+	if err != nil {
+		return provider.Instance{}, err
+	}
+	createdAt := metadata.CreatedAt
+	out := provider.Instance{
+		Name:       dom.Name,
+		ProviderID: formatUUID(dom.UUID),
+		State:      toState(libvirt.DomainState(state)),
+		IPs:        metadata.IPs,
+		Flavor:     metadata.Flavor,
+		Image:      metadata.Image,
+		Volumes:    metadata.Volumes,
+		Managed:    true,
+	}
+	if metadata.Network != "" {
+		out.Networks = []string{metadata.Network}
+	}
+	if !createdAt.IsZero() {
+		out.CreatedAt = &createdAt
+	}
+	return out, nil
+}
+
+func toState(state libvirt.DomainState) provider.State {
+	switch state {
+	case libvirt.DomainRunning, libvirt.DomainBlocked:
+		return provider.StateRunning
+	case libvirt.DomainPaused, libvirt.DomainShutdown, libvirt.DomainShutoff, libvirt.DomainPmsuspended:
+		return provider.StateStopped
+	case libvirt.DomainCrashed:
+		return provider.StateError
+	default:
+		return provider.StateUnknown
+	}
+}
+
+func hasCode(err error, code libvirt.ErrorNumber) bool {
+	var libvirtErr libvirt.Error
+	return errors.As(err, &libvirtErr) && libvirtErr.Code == uint32(code)
+}
+
+func formatUUID(uuid libvirt.UUID) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:16])
+}
+
+// randomMAC returns a MAC address in the range of QEMU.
+func randomMAC() (string, error) {
+	b := make([]byte, 3)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("52:54:00:%02x:%02x:%02x", b[0], b[1], b[2]), nil
+}
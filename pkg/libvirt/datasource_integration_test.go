@@ -0,0 +1,90 @@
+//go:build integration
+
+package libvirt_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/joho/godotenv"
+	"github.com/squarefactory/cloud-burster/logger"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/libvirt"
+	"github.com/squarefactory/cloud-burster/pkg/provider"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+)
+
+type DataSourceTestSuite struct {
+	suite.Suite
+	uri     string
+	image   string
+	network string
+	impl    *libvirt.DataSource
+}
+
+func (suite *DataSourceTestSuite) TestCreate() {
+	// Arrange
+	host := config.Host{
+		Name:       "delete-me-integration-test",
+		DiskSize:   10,
+		FlavorName: "tiny",
+		ImageName:  suite.image,
+		IP:         "192.168.122.254",
+	}
+	cloud := config.Cloud{
+		Network: &config.Network{
+			Name:       suite.network,
+			SubnetCIDR: "192.168.122.0/24",
+			DNS:        "192.168.122.1",
+			Gateway:    "192.168.122.1",
+		},
+		AuthorizedKeys: []string{
+			"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIDUnXMBGq6bV6H+c7P5QjDn1soeB6vkodi6OswcZsMwH nguye@PC-DARKNESS4",
+		},
+	}
+	ctx := context.Background()
+
+	// Act
+	err := suite.impl.Create(ctx, &host, &cloud)
+
+	// Assert
+	suite.NoError(err)
+	instance, err := suite.impl.Get(ctx, host.Name)
+	suite.NoError(err)
+	suite.Equal(provider.StateRunning, instance.State)
+	suite.Equal([]string{host.IP}, instance.IPs)
+
+	// Cleanup
+	err = suite.impl.Delete(ctx, host.Name)
+
+	// Assert
+	suite.NoError(err)
+	_, err = suite.impl.Get(ctx, host.Name)
+	suite.ErrorIs(err, provider.ErrNotFound)
+}
+
+func (suite *DataSourceTestSuite) BeforeTest(suiteName, testName string) {
+	impl, err := libvirt.New(&libvirt.Options{
+		URI: suite.uri,
+		Flavors: map[string]libvirt.Flavor{
+			"tiny": {CPUs: 1, RAM: 1024},
+		},
+	}, nil)
+	suite.Require().NoError(err)
+	suite.impl = impl
+}
+
+func TestDataSourceTestSuite(t *testing.T) {
+	if err := godotenv.Load(".env.test"); err != nil {
+		// Skip test if not defined
+		logger.I.Error("Error loading .env.test file", zap.Error(err))
+	} else {
+		suite.Run(t, &DataSourceTestSuite{
+			uri:     os.Getenv("LIBVIRT_URI"),
+			image:   os.Getenv("LIBVIRT_IMAGE"),
+			network: os.Getenv("LIBVIRT_NETWORK"),
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package libvirt
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// MetadataNamespace is the namespace of the metadata of the domains created by cloud-burster.
+const MetadataNamespace = "https://github.com/squarefactory/cloud-burster"
+
+// Metadata is stored in the domains created by cloud-burster. The domains without it are
+// ignored.
+type Metadata struct {
+	XMLName   xml.Name  `xml:"https://github.com/squarefactory/cloud-burster instance"`
+	Hostname  string    `xml:"hostname"`
+	IPs       []string  `xml:"ip,omitempty"`
+	Network   string    `xml:"network,omitempty"`
+	Flavor    string    `xml:"flavor,omitempty"`
+	Image     string    `xml:"image,omitempty"`
+	CreatedAt time.Time `xml:"createdAt"`
+	// Pool holds the Volumes, deleted with the domain.
+	Pool    string   `xml:"pool"`
+	Volumes []string `xml:"volume"`
+}
+
+// Definition describes the domain of a host.
+type Definition struct {
+	Name string
+	// Type is the domain type, kvm or qemu.
+	Type string
+	Flavor
+	// Disk and Seed are the paths of the overlay and of the seed ISO.
+	Disk string
+	Seed string
+	// Network is the name of the libvirt network of the interface.
+	Network  string
+	MAC      string
+	Metadata Metadata
+}
+
+type domainXML struct {
+	XMLName  xml.Name `xml:"domain"`
+	Type     string   `xml:"type,attr"`
+	Name     string   `xml:"name"`
+	Metadata struct {
+		Instance Metadata
+	} `xml:"metadata"`
+	Memory struct {
+		Unit  string `xml:"unit,attr"`
+		Value int    `xml:",chardata"`
+	} `xml:"memory"`
+	VCPU int `xml:"vcpu"`
+	OS   struct {
+		Type string `xml:"type"`
+		Boot struct {
+			Dev string `xml:"dev,attr"`
+		} `xml:"boot"`
+	} `xml:"os"`
+	Features struct {
+		ACPI struct{} `xml:"acpi"`
+		APIC struct{} `xml:"apic"`
+	} `xml:"features"`
+	CPU     *cpuXML `xml:"cpu"`
+	Devices struct {
+		Disks      []diskXML      `xml:"disk"`
+		Interfaces []interfaceXML `xml:"interface"`
+		Serial     struct {
+			Type string `xml:"type,attr"`
+		} `xml:"serial"`
+		Console struct {
+			Type string `xml:"type,attr"`
+		} `xml:"console"`
+	} `xml:"devices"`
+}
+
+type diskXML struct {
+	Type   string `xml:"type,attr"`
+	Device string `xml:"device,attr"`
+	Driver struct {
+		Name string `xml:"name,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"driver"`
+	Source struct {
+		File string `xml:"file,attr"`
+	} `xml:"source"`
+	Target struct {
+		Dev string `xml:"dev,attr"`
+		Bus string `xml:"bus,attr"`
+	} `xml:"target"`
+	ReadOnly *struct{} `xml:"readonly"`
+}
+
+// XML returns the domain XML of the definition: the overlay is the boot disk, and the seed
+// is a read-only CD-ROM.
+func (d *Definition) XML() (string, error) {
+	var dom domainXML
+	dom.Type = d.Type
+	dom.Name = d.Name
+	dom.Metadata.Instance = d.Metadata
+	dom.Memory.Unit = "MiB"
+	dom.Memory.Value = d.RAM
+	dom.VCPU = d.CPUs
+	dom.OS.Type = "hvm"
+	dom.OS.Boot.Dev = "hd"
+	if d.Type == "kvm" {
+		dom.CPU = &cpuXML{Mode: "host-passthrough"}
+	}
+
+	disk := diskXML{Type: "file", Device: "disk"}
+	disk.Driver.Name = "qemu"
+	disk.Driver.Type = "qcow2"
+	disk.Source.File = d.Disk
+	disk.Target.Dev = "vda"
+	disk.Target.Bus = "virtio"
+	seed := diskXML{Type: "file", Device: "cdrom", ReadOnly: &struct{}{}}
+	seed.Driver.Name = "qemu"
+	seed.Driver.Type = "raw"
+	seed.Source.File = d.Seed
+	seed.Target.Dev = "sda"
+	seed.Target.Bus = "sata"
+	dom.Devices.Disks = []diskXML{disk, seed}
+
+	var iface interfaceXML
+	iface.Type = "network"
+	iface.MAC.Address = d.MAC
+	iface.Source.Network = d.Network
+	iface.Model.Type = "virtio"
+	dom.Devices.Interfaces = []interfaceXML{iface}
+
+	dom.Devices.Serial.Type = "pty"
+	dom.Devices.Console.Type = "pty"
+
+	out, err := xml.MarshalIndent(&dom, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+type cpuXML struct {
+	Mode string `xml:"mode,attr"`
+}
+
+type interfaceXML struct {
+	Type string `xml:"type,attr"`
+	MAC  struct {
+		Address string `xml:"address,attr"`
+	} `xml:"mac"`
+	Source struct {
+		Network string `xml:"network,attr"`
+	} `xml:"source"`
+	Model struct {
+		Type string `xml:"type,attr"`
+	} `xml:"model"`
+}
+
+type formatXML struct {
+	Type string `xml:"type,attr"`
+}
+
+type volumeXML struct {
+	XMLName  xml.Name `xml:"volume"`
+	Name     string   `xml:"name"`
+	Capacity struct {
+		Unit  string `xml:"unit,attr"`
+		Value uint64 `xml:",chardata"`
+	} `xml:"capacity"`
+	Target struct {
+		Path   string    `xml:"path,omitempty"`
+		Format formatXML `xml:"format"`
+	} `xml:"target"`
+	BackingStore *backingStoreXML `xml:"backingStore"`
+}
+
+type backingStoreXML struct {
+	Path   string    `xml:"path"`
+	Format formatXML `xml:"format"`
+}
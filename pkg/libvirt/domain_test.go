@@ -0,0 +1,120 @@
+//go:build unit
+
+package libvirt_test
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/squarefactory/cloud-burster/pkg/libvirt"
+	"github.com/stretchr/testify/suite"
+)
+
+type DomainTestSuite struct {
+	suite.Suite
+}
+
+func (suite *DomainTestSuite) TestXML() {
+	// Arrange
+	def := libvirt.Definition{
+		Name:    "cn1",
+		Type:    "kvm",
+		Flavor:  libvirt.Flavor{CPUs: 2, RAM: 4096},
+		Disk:    "/var/lib/libvirt/images/cn1.qcow2",
+		Seed:    "/var/lib/libvirt/images/cn1-seed.iso",
+		Network: "overflow",
+		MAC:     "52:54:00:12:34:56",
+		Metadata: libvirt.Metadata{
+			Hostname:  "cn1",
+			IPs:       []string{"172.28.1.1"},
+			Network:   "overflow",
+			Flavor:    "d2-2",
+			Image:     "rocky-9.qcow2",
+			CreatedAt: time.Date(2023, 11, 20, 10, 0, 0, 0, time.UTC),
+			Pool:      "default",
+			Volumes:   []string{"cn1.qcow2", "cn1-seed.iso"},
+		},
+	}
+	expected := `<domain type="kvm">
+  <name>cn1</name>
+  <metadata>
+    <instance xmlns="https://github.com/squarefactory/cloud-burster">
+      <hostname>cn1</hostname>
+      <ip>172.28.1.1</ip>
+      <network>overflow</network>
+      <flavor>d2-2</flavor>
+      <image>rocky-9.qcow2</image>
+      <createdAt>2023-11-20T10:00:00Z</createdAt>
+      <pool>default</pool>
+      <volume>cn1.qcow2</volume>
+      <volume>cn1-seed.iso</volume>
+    </instance>
+  </metadata>
+  <memory unit="MiB">4096</memory>
+  <vcpu>2</vcpu>
+  <os>
+    <type>hvm</type>
+    <boot dev="hd"></boot>
+  </os>
+  <features>
+    <acpi></acpi>
+    <apic></apic>
+  </features>
+  <cpu mode="host-passthrough"></cpu>
+  <devices>
+    <disk type="file" device="disk">
+      <driver name="qemu" type="qcow2"></driver>
+      <source file="/var/lib/libvirt/images/cn1.qcow2"></source>
+      <target dev="vda" bus="virtio"></target>
+    </disk>
+    <disk type="file" device="cdrom">
+      <driver name="qemu" type="raw"></driver>
+      <source file="/var/lib/libvirt/images/cn1-seed.iso"></source>
+      <target dev="sda" bus="sata"></target>
+      <readonly></readonly>
+    </disk>
+    <interface type="network">
+      <mac address="52:54:00:12:34:56"></mac>
+      <source network="overflow"></source>
+      <model type="virtio"></model>
+    </interface>
+    <serial type="pty"></serial>
+    <console type="pty"></console>
+  </devices>
+</domain>`
+
+	// Act
+	out, err := def.XML()
+
+	// Assert
+	suite.NoError(err)
+	suite.Equal(expected, out)
+}
+
+func (suite *DomainTestSuite) TestMetadata() {
+	// Arrange
+	// As returned by libvirt, with a prefix
+	desc := `<cloud-burster:instance xmlns:cloud-burster="https://github.com/squarefactory/cloud-burster">
+  <cloud-burster:hostname>cn1</cloud-burster:hostname>
+  <cloud-burster:createdAt>2023-11-20T10:00:00Z</cloud-burster:createdAt>
+  <cloud-burster:pool>default</cloud-burster:pool>
+  <cloud-burster:volume>cn1.qcow2</cloud-burster:volume>
+  <cloud-burster:volume>cn1-seed.iso</cloud-burster:volume>
+</cloud-burster:instance>`
+
+	// Act
+	var metadata libvirt.Metadata
+	err := xml.Unmarshal([]byte(desc), &metadata)
+
+	// Assert
+	suite.NoError(err)
+	suite.Equal("cn1", metadata.Hostname)
+	suite.Equal(time.Date(2023, 11, 20, 10, 0, 0, 0, time.UTC), metadata.CreatedAt)
+	suite.Equal("default", metadata.Pool)
+	suite.Equal([]string{"cn1.qcow2", "cn1-seed.iso"}, metadata.Volumes)
+}
+
+func TestDomainTestSuite(t *testing.T) {
+	suite.Run(t, &DomainTestSuite{})
+}
@@ -0,0 +1,83 @@
+package libvirt
+
+import (
+	"errors"
+	"net/url"
+
+	"github.com/squarefactory/cloud-burster/pkg/cloud"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	validation "github.com/squarefactory/cloud-burster/validate"
+)
+
+func init() {
+	cloud.Register("libvirt", cloud.Provider{
+		Description: "libvirt/KVM domains on a hypervisor, with a qcow2 overlay per host.",
+		Options:     func() config.ProviderOptions { return &Options{} },
+		New: func(conf *config.Cloud) (cloud.DataSource, error) {
+			ds, err := New(conf.Provider.(*Options), conf.RateLimit.Limiter())
+			if err != nil {
+				return nil, err
+			}
+			ds.Retry = conf.Retry.Apply(ds.Retry)
+			return ds, nil
+		},
+	})
+}
+
+const (
+	// DefaultPool is the storage pool used if none is set.
+	DefaultPool = "default"
+	// DefaultDomainType is the domain type used if none is set.
+	DefaultDomainType = "kvm"
+)
+
+// Options are the options of the provider block of the libvirt clouds.
+type Options struct {
+	// URI is the libvirt URI of the hypervisor, like qemu:///system or
+	// qemu+ssh://root@hypervisor/system.
+	URI string `yaml:"uri" validate:"required"`
+	// Pool is the storage pool holding the images and the volumes of the instances.
+	Pool string `yaml:"pool,omitempty"`
+	// DomainType is kvm, or qemu for the hypervisors without hardware virtualization.
+	DomainType string `yaml:"domainType,omitempty" validate:"omitempty,oneof=kvm qemu"`
+	// Flavors maps the flavor names of the hosts to their resources.
+	Flavors map[string]Flavor `yaml:"flavors" validate:"required,dive"`
+}
+
+// Flavor are the resources of a domain.
+type Flavor struct {
+	CPUs int `yaml:"cpus" validate:"required,min=1"`
+	// RAM is in MiB.
+	RAM int `yaml:"ram" validate:"required,min=1"`
+}
+
+// Validate checks the fields, and that the URI can be parsed.
+func (o *Options) Validate() error {
+	if err := validation.I.Struct(o); err != nil {
+		return err
+	}
+	u, err := url.Parse(o.URI)
+	if err == nil && u.Scheme == "" {
+		err = errors.New("the URI has no driver, like qemu:///system")
+	}
+	if err != nil {
+		return &config.OptionError{Key: "uri", Err: err}
+	}
+	return nil
+}
+
+// PoolName returns the storage pool, DefaultPool if unset.
+func (o *Options) PoolName() string {
+	if o.Pool == "" {
+		return DefaultPool
+	}
+	return o.Pool
+}
+
+// Type returns the domain type, DefaultDomainType if unset.
+func (o *Options) Type() string {
+	if o.DomainType == "" {
+		return DefaultDomainType
+	}
+	return o.DomainType
+}
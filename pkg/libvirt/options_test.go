@@ -0,0 +1,100 @@
+//go:build unit
+
+package libvirt_test
+
+import (
+	"testing"
+
+	"github.com/squarefactory/cloud-burster/pkg/libvirt"
+	"github.com/stretchr/testify/suite"
+)
+
+var cleanOptions = libvirt.Options{
+	URI: "qemu+ssh://root@hypervisor/system",
+	Flavors: map[string]libvirt.Flavor{
+		"d2-2": {CPUs: 2, RAM: 4096},
+	},
+}
+
+type OptionsTestSuite struct {
+	suite.Suite
+}
+
+func (suite *OptionsTestSuite) TestValidate() {
+	tests := []struct {
+		input         *libvirt.Options
+		isError       bool
+		errorContains []string
+		title         string
+	}{
+		{
+			input: &cleanOptions,
+			title: "Positive test",
+		},
+		{
+			input:         &libvirt.Options{},
+			isError:       true,
+			errorContains: []string{"URI", "Flavors", "required"},
+			title:         "Required fields",
+		},
+		{
+			input: &libvirt.Options{
+				URI:     cleanOptions.URI,
+				Flavors: map[string]libvirt.Flavor{"d2-2": {CPUs: 2}},
+			},
+			isError:       true,
+			errorContains: []string{"RAM", "required"},
+			title:         "Incomplete flavor",
+		},
+		{
+			input: &libvirt.Options{
+				URI:        cleanOptions.URI,
+				DomainType: "xen",
+				Flavors:    cleanOptions.Flavors,
+			},
+			isError:       true,
+			errorContains: []string{"DomainType", "oneof"},
+			title:         "Unsupported domain type",
+		},
+		{
+			input: &libvirt.Options{
+				URI:     "hypervisor",
+				Flavors: cleanOptions.Flavors,
+			},
+			isError:       true,
+			errorContains: []string{"uri: the URI has no driver"},
+			title:         "URI without driver",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Act
+			err := tt.input.Validate()
+
+			// Assert
+			if tt.isError {
+				suite.Error(err)
+				for _, contain := range tt.errorContains {
+					suite.ErrorContains(err, contain)
+				}
+			} else {
+				suite.NoError(err)
+			}
+		})
+	}
+}
+
+func (suite *OptionsTestSuite) TestDefaults() {
+	// Act
+	pool := cleanOptions.PoolName()
+	domainType := cleanOptions.Type()
+
+	// Assert
+	suite.Equal(libvirt.DefaultPool, pool)
+	suite.Equal(libvirt.DefaultDomainType, domainType)
+}
+
+func TestOptionsTestSuite(t *testing.T) {
+	suite.Run(t, &OptionsTestSuite{})
+}
@@ -0,0 +1,167 @@
+package libvirt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/kdomanski/iso9660"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"gopkg.in/yaml.v3"
+)
+
+// SeedLabel is the volume label of the NoCloud seeds, looked up by cloud-init.
+const SeedLabel = "cidata"
+
+// Seed is the NoCloud seed of an instance.
+type Seed struct {
+	UserData      []byte
+	MetaData      []byte
+	NetworkConfig []byte
+}
+
+// NewSeed renders the seed of the host, whose interface has the MAC address mac.
+//
+// The interface gets the static IPs of the host in the subnets of the network, or DHCP
+// without IP.
+func NewSeed(
+	host *config.Host,
+	cloud *config.Cloud,
+	mac string,
+	instanceID string,
+) (*Seed, error) {
+	if cloud.Network == nil {
+		return nil, errors.New("the network of the cloud is required")
+	}
+
+	var customConfig []byte
+	if len(cloud.CustomConfig) > 0 {
+		var err error
+		customConfig, err = yaml.Marshal(cloud.CustomConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+	userData, err := GenerateCloudConfig(&CloudConfigOpts{
+		Hostname:          host.Name,
+		AuthorizedKeys:    cloud.AuthorizedKeys,
+		PostScripts:       cloud.PostScripts,
+		CustomCloudConfig: string(customConfig),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	metaData, err := yaml.Marshal(map[string]string{
+		"instance-id":    instanceID,
+		"local-hostname": host.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	networkConfig, err := networkConfig(host, cloud.Network, mac)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Seed{
+		UserData:      userData,
+		MetaData:      metaData,
+		NetworkConfig: networkConfig,
+	}, nil
+}
+
+// ISO returns the seed as an ISO 9660 image labeled SeedLabel.
+func (s *Seed) ISO() ([]byte, error) {
+	w, err := iso9660.NewWriter()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = w.Cleanup()
+	}()
+	for name, content := range map[string][]byte{
+		"user-data":      s.UserData,
+		"meta-data":      s.MetaData,
+		"network-config": s.NetworkConfig,
+	} {
+		if err := w.AddFile(bytes.NewReader(content), name); err != nil {
+			return nil, err
+		}
+	}
+	var out bytes.Buffer
+	if err := w.WriteTo(&out, SeedLabel); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+type ethernet struct {
+	Match struct {
+		MACAddress string `yaml:"macaddress"`
+	} `yaml:"match"`
+	SetName     string   `yaml:"set-name"`
+	DHCP4       bool     `yaml:"dhcp4,omitempty"`
+	Addresses   []string `yaml:"addresses,omitempty"`
+	Routes      []route  `yaml:"routes,omitempty"`
+	Nameservers struct {
+		Addresses []string `yaml:"addresses,omitempty"`
+		Search    []string `yaml:"search,omitempty"`
+	} `yaml:"nameservers,omitempty"`
+}
+
+type route struct {
+	To  string `yaml:"to"`
+	Via string `yaml:"via"`
+}
+
+// networkConfig returns the network configuration (version 2) of the interface of the host.
+func networkConfig(host *config.Host, network *config.Network, mac string) ([]byte, error) {
+	var eth ethernet
+	eth.Match.MACAddress = mac
+	eth.SetName = "eth0"
+	eth.Nameservers.Addresses = network.Nameservers()
+	if network.Search != "" {
+		eth.Nameservers.Search = strings.Fields(network.Search)
+	}
+
+	for _, f := range []struct {
+		ip      string
+		subnet  string
+		gateway string
+		to      string
+	}{
+		{host.IP, network.SubnetCIDR, network.Gateway, "0.0.0.0/0"},
+		{host.IPv6, network.SubnetCIDR6, network.Gateway6, "::/0"},
+	} {
+		if f.ip == "" {
+			continue
+		}
+		addr, err := netip.ParseAddr(f.ip)
+		if err != nil {
+			return nil, err
+		}
+		if f.subnet == "" {
+			return nil, fmt.Errorf("no subnet for %s", addr)
+		}
+		prefix, err := netip.ParsePrefix(f.subnet)
+		if err != nil {
+			return nil, err
+		}
+		eth.Addresses = append(eth.Addresses, netip.PrefixFrom(addr, prefix.Bits()).String())
+		if f.gateway != "" {
+			eth.Routes = append(eth.Routes, route{To: f.to, Via: f.gateway})
+		}
+	}
+	if host.IP == "" {
+		eth.DHCP4 = true
+	}
+
+	return yaml.Marshal(map[string]interface{}{
+		"version":   2,
+		"ethernets": map[string]ethernet{"eth0": eth},
+	})
+}
@@ -0,0 +1,179 @@
+//go:build unit
+
+package libvirt_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/kdomanski/iso9660"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/libvirt"
+	"github.com/stretchr/testify/suite"
+)
+
+var cleanCloud = config.Cloud{
+	Type: "libvirt",
+	AuthorizedKeys: []string{
+		"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIDUnXMBGq6bV6H+c7P5QjDn1soeB6vkodi6OswcZsMwH nguye@PC-DARKNESS4",
+	},
+	Network: &config.Network{
+		Name:        "overflow",
+		SubnetCIDR:  "172.28.0.0/20",
+		DNS:         "1.1.1.1",
+		Search:      "example.com",
+		Gateway:     "172.28.0.2",
+		SubnetCIDR6: "fd00::/64",
+		Gateway6:    "fd00::1",
+	},
+}
+
+type SeedTestSuite struct {
+	suite.Suite
+}
+
+func (suite *SeedTestSuite) TestNewSeed() {
+	tests := []struct {
+		host          config.Host
+		cloud         config.Cloud
+		expected      string
+		isError       bool
+		errorContains []string
+		title         string
+	}{
+		{
+			host: config.Host{
+				Name: "cn1",
+				IP:   "172.28.1.1",
+				IPv6: "fd00::101",
+			},
+			cloud: cleanCloud,
+			expected: `ethernets:
+    eth0:
+        match:
+            macaddress: "52:54:00:12:34:56"
+        set-name: eth0
+        addresses:
+            - 172.28.1.1/20
+            - fd00::101/64
+        routes:
+            - to: 0.0.0.0/0
+              via: 172.28.0.2
+            - to: ::/0
+              via: fd00::1
+        nameservers:
+            addresses:
+                - 1.1.1.1
+            search:
+                - example.com
+version: 2
+`,
+			title: "Static IPs",
+		},
+		{
+			host: config.Host{
+				Name: "cn1",
+			},
+			cloud: cleanCloud,
+			expected: `ethernets:
+    eth0:
+        match:
+            macaddress: "52:54:00:12:34:56"
+        set-name: eth0
+        dhcp4: true
+        nameservers:
+            addresses:
+                - 1.1.1.1
+            search:
+                - example.com
+version: 2
+`,
+			title: "DHCP without IP",
+		},
+		{
+			host: config.Host{
+				Name: "cn1",
+				IPv6: "fd00::101",
+			},
+			cloud: config.Cloud{
+				Network: &config.Network{
+					Name:       "overflow",
+					SubnetCIDR: "172.28.0.0/20",
+					DNS:        "1.1.1.1",
+					Gateway:    "172.28.0.2",
+				},
+			},
+			isError:       true,
+			errorContains: []string{"no subnet for fd00::101"},
+			title:         "IPv6 without subnet",
+		},
+		{
+			host: config.Host{
+				Name: "cn1",
+			},
+			isError:       true,
+			errorContains: []string{"the network of the cloud is required"},
+			title:         "Missing network",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Act
+			seed, err := libvirt.NewSeed(&tt.host, &tt.cloud, "52:54:00:12:34:56", "cn1-1")
+
+			// Assert
+			if tt.isError {
+				suite.Error(err)
+				for _, contain := range tt.errorContains {
+					suite.ErrorContains(err, contain)
+				}
+			} else {
+				suite.NoError(err)
+				suite.Equal(tt.expected, string(seed.NetworkConfig))
+				suite.Equal("instance-id: cn1-1\nlocal-hostname: cn1\n", string(seed.MetaData))
+				suite.Contains(string(seed.UserData), "hostname: cn1")
+			}
+		})
+	}
+}
+
+func (suite *SeedTestSuite) TestISO() {
+	// Arrange
+	seed := libvirt.Seed{
+		UserData:      []byte("#cloud-config\n"),
+		MetaData:      []byte("instance-id: cn1-1\n"),
+		NetworkConfig: []byte("version: 2\n"),
+	}
+
+	// Act
+	data, err := seed.ISO()
+
+	// Assert
+	suite.Require().NoError(err)
+	image, err := iso9660.OpenImage(bytes.NewReader(data))
+	suite.Require().NoError(err)
+	label, err := image.Label()
+	suite.NoError(err)
+	suite.Equal(libvirt.SeedLabel, label)
+	root, err := image.RootDir()
+	suite.Require().NoError(err)
+	children, err := root.GetChildren()
+	suite.Require().NoError(err)
+	files := make(map[string]string, len(children))
+	for _, child := range children {
+		content, err := io.ReadAll(child.Reader())
+		suite.NoError(err)
+		files[child.Name()] = string(content)
+	}
+	suite.Equal(map[string]string{
+		"user-data":      "#cloud-config\n",
+		"meta-data":      "instance-id: cn1-1\n",
+		"network-config": "version: 2\n",
+	}, files)
+}
+
+func TestSeedTestSuite(t *testing.T) {
+	suite.Run(t, &SeedTestSuite{})
+}
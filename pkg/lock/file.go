@@ -0,0 +1,41 @@
+package lock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/squarefactory/cloud-burster/utils/flock"
+)
+
+// DefaultDir is the default directory of the lock files.
+const DefaultDir = "/var/lib/cloud-burster/locks"
+
+// FileLocker is a Locker shared by the processes of a machine.
+//
+// Each key is locked with an advisory lock on "<dir>/<key>.lock".
+type FileLocker struct {
+	dir string
+}
+
+func NewFileLocker(dir string) *FileLocker {
+	return &FileLocker{
+		dir: dir,
+	}
+}
+
+func (l *FileLocker) TryLock(key string) (Releaser, error) {
+	if err := os.MkdirAll(l.dir, 0o700); err != nil {
+		return nil, err
+	}
+	name := strings.ReplaceAll(key, string(filepath.Separator), "_") + ".lock"
+	r, err := flock.TryAcquire(filepath.Join(l.dir, name))
+	if errors.Is(err, flock.ErrLocked) {
+		return nil, ErrLocked
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,61 @@
+// Package lock serializes the operations on a host, across goroutines and processes.
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrLocked is returned when the lock is held by another operation.
+var ErrLocked = errors.New("locked by another operation")
+
+// pollInterval is the delay between two attempts of Acquire.
+var pollInterval = 200 * time.Millisecond
+
+// Releaser releases a lock.
+type Releaser interface {
+	Release() error
+}
+
+// Locker is a lock backend.
+type Locker interface {
+	// TryLock acquires the lock of key or returns ErrLocked immediately.
+	TryLock(key string) (Releaser, error)
+}
+
+// Acquire retries TryLock until it succeeds, the timeout expires or the context is done.
+//
+// A zero timeout fails fast. A negative timeout waits until the context is done.
+func Acquire(ctx context.Context, l Locker, key string, timeout time.Duration) (Releaser, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		r, err := l.TryLock(key)
+		if !errors.Is(err, ErrLocked) {
+			return r, err
+		}
+		if timeout == 0 {
+			return nil, fmt.Errorf("%s: %w", key, ErrLocked)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%s: %w: %w", key, ErrLocked, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Open returns a file locker at dir, or a memory locker if dir is empty.
+func Open(dir string) Locker {
+	if dir == "" {
+		return NewMemoryLocker()
+	}
+	return NewFileLocker(dir)
+}
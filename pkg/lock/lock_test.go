@@ -0,0 +1,82 @@
+//go:build unit
+
+package lock_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/squarefactory/cloud-burster/pkg/lock"
+	"github.com/stretchr/testify/suite"
+)
+
+type LockTestSuite struct {
+	suite.Suite
+}
+
+func (suite *LockTestSuite) TestAcquire() {
+	tests := []struct {
+		input func() lock.Locker
+		title string
+	}{
+		{
+			input: func() lock.Locker {
+				return lock.NewMemoryLocker()
+			},
+			title: "Memory locker",
+		},
+		{
+			input: func() lock.Locker {
+				return lock.NewFileLocker(suite.T().TempDir())
+			},
+			title: "File locker",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Arrange
+			ctx := context.Background()
+			locker := tt.input()
+			held, err := lock.Acquire(ctx, locker, "cn-1", 0)
+			suite.Require().NoError(err)
+
+			// Act
+			_, err = lock.Acquire(ctx, locker, "cn-1", 0)
+
+			// Assert
+			suite.ErrorIs(err, lock.ErrLocked, "should fail fast")
+
+			// Act
+			other, err := lock.Acquire(ctx, locker, "cn-2", 0)
+
+			// Assert
+			suite.NoError(err, "other hosts should not be locked")
+			suite.NoError(other.Release())
+
+			// Act
+			start := time.Now()
+			_, err = lock.Acquire(ctx, locker, "cn-1", 300*time.Millisecond)
+
+			// Assert
+			suite.ErrorIs(err, lock.ErrLocked)
+			suite.GreaterOrEqual(time.Since(start), 300*time.Millisecond, "should wait")
+
+			// Act
+			go func() {
+				time.Sleep(100 * time.Millisecond)
+				_ = held.Release()
+			}()
+			r, err := lock.Acquire(ctx, locker, "cn-1", 5*time.Second)
+
+			// Assert
+			suite.NoError(err, "should acquire once released")
+			suite.NoError(r.Release())
+		})
+	}
+}
+
+func TestLockTestSuite(t *testing.T) {
+	suite.Run(t, &LockTestSuite{})
+}
@@ -0,0 +1,40 @@
+package lock
+
+import "sync"
+
+// MemoryLocker is a Locker shared by the goroutines of a process.
+type MemoryLocker struct {
+	mu   sync.Mutex
+	keys map[string]struct{}
+}
+
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{
+		keys: make(map[string]struct{}),
+	}
+}
+
+func (l *MemoryLocker) TryLock(key string) (Releaser, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.keys[key]; ok {
+		return nil, ErrLocked
+	}
+	l.keys[key] = struct{}{}
+	return &memoryLock{locker: l, key: key}, nil
+}
+
+type memoryLock struct {
+	locker *MemoryLocker
+	key    string
+	once   sync.Once
+}
+
+func (r *memoryLock) Release() error {
+	r.once.Do(func() {
+		r.locker.mu.Lock()
+		defer r.locker.mu.Unlock()
+		delete(r.locker.keys, r.key)
+	})
+	return nil
+}
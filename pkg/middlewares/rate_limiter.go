@@ -0,0 +1,34 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimiter waits for a token of the Limiter before sending each request.
+type RateLimiter struct {
+	http.RoundTripper
+	Limiter *rate.Limiter
+}
+
+func (rl *RateLimiter) RoundTrip(request *http.Request) (*http.Response, error) {
+	if err := rl.Limiter.Wait(request.Context()); err != nil {
+		return nil, err
+	}
+	return rl.RoundTripper.RoundTrip(request)
+}
+
+// Transport returns the logging transport, rate limited by limiter if not nil.
+func Transport(limiter *rate.Limiter) http.RoundTripper {
+	var rt http.RoundTripper = &RoundTripper{
+		RoundTripper: http.DefaultTransport,
+	}
+	if limiter != nil {
+		rt = &RateLimiter{
+			RoundTripper: rt,
+			Limiter:      limiter,
+		}
+	}
+	return rt
+}
@@ -15,7 +15,7 @@ import (
 type CloudConfigOpts struct {
 	AuthorizedKeys    []string
 	PostScripts       config.PostScriptsOpts
-	DNS               string
+	DNS               []string
 	Search            string
 	CustomCloudConfig string
 }
@@ -32,7 +32,7 @@ write_files:
   - path: /etc/systemd/resolved.conf
     content: |
       [Resolve]
-      DNS={{ .DNS }}
+      DNS={{ join " " .DNS }}
       DNSStubListener=no
 
   - path: /etc/NetworkManager/NetworkManager.conf
@@ -45,7 +45,9 @@ write_files:
 
   - path: /etc/resolv.conf
     content: |
-      nameserver {{ .DNS }}
+{{- range .DNS }}
+      nameserver {{ . }}
+{{- end }}
 {{- if .Search }}
       search {{ .Search }}
 {{ end }}
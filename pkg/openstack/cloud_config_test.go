@@ -20,7 +20,7 @@ func (suite *CloudConfigTestSuite) TestGenerateCloudConfig() {
 		AuthorizedKeys: []string{
 			"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIDUnXMBGq6bV6H+c7P5QjDn1soeB6vkodi6OswcZsMwH nguye@PC-DARKNESS4",
 		},
-		DNS:    "1.1.1.1",
+		DNS:    []string{"1.1.1.1"},
 		Search: "example.com",
 		PostScripts: config.PostScriptsOpts{
 			Git: config.GitOpts{
@@ -3,12 +3,16 @@ package openstack
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack"
+	"github.com/gophercloud/gophercloud/openstack/blockstorage/v3/volumes"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/bootfromvolume"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/limits"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/images"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
@@ -20,57 +24,141 @@ import (
 	"github.com/squarefactory/cloud-burster/logger"
 	"github.com/squarefactory/cloud-burster/pkg/config"
 	"github.com/squarefactory/cloud-burster/pkg/middlewares"
+	"github.com/squarefactory/cloud-burster/pkg/provider"
+	"github.com/squarefactory/cloud-burster/utils/rollback"
 	"github.com/squarefactory/cloud-burster/utils/try"
 	"go.uber.org/zap"
+	"golang.org/x/time/rate"
 	"gopkg.in/yaml.v3"
 )
 
+// MetadataHostname is the metadata key of the servers holding their hostname. It marks the
+// servers created by cloud-burster.
+const MetadataHostname = "cloud-burster:hostname"
+
+// DefaultBuildTimeout is the time given to a server to leave the BUILD status.
+const DefaultBuildTimeout = 10 * time.Minute
+
+// DefaultRetry is the default retry policy of the data source.
+var DefaultRetry = try.Policy{
+	MaxElapsed:   time.Minute,
+	InitialDelay: time.Second,
+	MaxDelay:     10 * time.Second,
+	Multiplier:   2,
+	Jitter:       0.2,
+	Retryable:    Retryable,
+}
+
+// Retryable returns false for the client errors of the API, except timeouts and rate limits.
+func Retryable(err error) bool {
+	var sce gophercloud.StatusCodeError
+	if !errors.As(err, &sce) {
+		return true
+	}
+	code := sce.GetStatusCode()
+	if code == http.StatusRequestTimeout || code == http.StatusTooManyRequests {
+		return true
+	}
+	return code < 400 || code >= 500
+}
+
 type DataSource struct {
+	// Retry is the retry policy of the operations which are eventually consistent.
+	Retry         try.Policy
+	buildTimeout  time.Duration
 	provider      *gophercloud.ProviderClient
 	computeClient *gophercloud.ServiceClient
 	networkClient *gophercloud.ServiceClient
+	// volumeClient is nil if the cloud has no block storage endpoint.
+	volumeClient *gophercloud.ServiceClient
 }
 
-func New(
-	endpoint string,
-	username string,
-	password string,
-	tenantID string,
-	tenantName string,
-	region string,
-	domainID string,
-) *DataSource {
+// New authenticates against the identity endpoint, and returns a data source bound to the
+// region of the options.
+func New(opts *Options, limiter *rate.Limiter) (*DataSource, error) {
 	provider, err := openstack.AuthenticatedClient(gophercloud.AuthOptions{
-		IdentityEndpoint: endpoint,
-		Username:         username,
-		Password:         password,
-		TenantID:         tenantID,
-		TenantName:       tenantName,
-		DomainID:         domainID,
+		IdentityEndpoint: opts.IdentityEndpoint,
+		Username:         opts.UserName,
+		Password:         opts.Password,
+		TenantID:         opts.TenantID,
+		TenantName:       opts.TenantName,
+		DomainID:         opts.DomainID,
+		AllowReauth:      true,
 	})
-	provider.HTTPClient.Transport = &middlewares.RoundTripper{
-		RoundTripper: http.DefaultTransport,
-	}
 	if err != nil {
-		logger.I.Panic("failed to authenticate client", zap.Error(err))
+		return nil, fmt.Errorf("failed to authenticate client: %w", err)
 	}
+	provider.HTTPClient.Transport = middlewares.Transport(limiter)
 	computeClient, err := openstack.NewComputeV2(provider, gophercloud.EndpointOpts{
-		Region: region,
+		Region: opts.Region,
 	})
 	if err != nil {
-		logger.I.Panic("couldn't instanciate computeClient", zap.Error(err))
+		return nil, fmt.Errorf("couldn't instanciate computeClient: %w", err)
 	}
 	networkClient, err := openstack.NewNetworkV2(provider, gophercloud.EndpointOpts{
-		Region: region,
+		Region: opts.Region,
 	})
 	if err != nil {
-		logger.I.Panic("couldn't instanciate networkClient", zap.Error(err))
+		return nil, fmt.Errorf("couldn't instanciate networkClient: %w", err)
+	}
+	volumeClient, err := openstack.NewBlockStorageV3(provider, gophercloud.EndpointOpts{
+		Region: opts.Region,
+	})
+	if err != nil {
+		logger.I.Warn("couldn't instanciate volumeClient, volumes won't be cleaned up", zap.Error(err))
+		volumeClient = nil
+	}
+	buildTimeout := opts.BuildTimeout
+	if buildTimeout == 0 {
+		buildTimeout = DefaultBuildTimeout
 	}
 	return &DataSource{
+		Retry:         DefaultRetry,
+		buildTimeout:  buildTimeout,
 		provider:      provider,
 		computeClient: computeClient,
 		networkClient: networkClient,
+		volumeClient:  volumeClient,
+	}, nil
+}
+
+// withContext returns a copy of the data source whose requests are bound to ctx.
+//
+// gophercloud only takes the context from the provider client, so the client is copied. The
+// token stays owned by the shared client: the copy reauthenticates through it, so that the
+// token is refreshed once for every copy, then takes the new token.
+func (s *DataSource) withContext(ctx context.Context) *DataSource {
+	provider := *s.provider
+	provider.UseTokenLock()
+	provider.CopyTokenFrom(s.provider)
+	provider.Context = ctx
+	if s.provider.ReauthFunc != nil {
+		provider.ReauthFunc = func() error {
+			if err := s.provider.Reauthenticate(provider.Token()); err != nil {
+				return err
+			}
+			provider.CopyTokenFrom(s.provider)
+			return nil
+		}
 	}
+	out := *s
+	out.provider = &provider
+	out.computeClient = withProvider(s.computeClient, &provider)
+	out.networkClient = withProvider(s.networkClient, &provider)
+	out.volumeClient = withProvider(s.volumeClient, &provider)
+	return &out
+}
+
+func withProvider(
+	client *gophercloud.ServiceClient,
+	provider *gophercloud.ProviderClient,
+) *gophercloud.ServiceClient {
+	if client == nil {
+		return nil
+	}
+	out := *client
+	out.ProviderClient = provider
+	return &out
 }
 
 // FindImageID retrieves the image UUID from name
@@ -201,19 +289,14 @@ func (s *DataSource) FindSubnetIDByNetwork(cidr string, networkID string) (strin
 	return result.ID, nil
 }
 
-// CreatePort connected to a network
-func (s *DataSource) CreatePort(ip string, networkID string, subnetID string) (string, error) {
+// CreatePort connected to a network, with an address in each subnet of fixedIPs
+func (s *DataSource) CreatePort(networkID string, fixedIPs []ports.IP) (string, error) {
 	adminStateUp := true
 	portCreateOpts := ports.CreateOpts{
 		NetworkID:      networkID,
 		AdminStateUp:   &adminStateUp,
 		SecurityGroups: &[]string{},
-		FixedIPs: []ports.IP{
-			{
-				IPAddress: ip,
-				SubnetID:  subnetID,
-			},
-		},
+		FixedIPs:       fixedIPs,
 	}
 	portSecurityEnabled := false
 	createOpts := portsecurity.PortCreateOptsExt{
@@ -272,6 +355,7 @@ func (s *DataSource) Create(
 	host *config.Host,
 	cloud *config.Cloud,
 ) error {
+	s = s.withContext(ctx)
 	logger.I.Debug(
 		"Create called",
 		zap.Any("host", host),
@@ -289,9 +373,19 @@ func (s *DataSource) Create(
 	if err != nil {
 		return err
 	}
-	subnetID, err := s.FindSubnetIDByNetwork(cloud.Network.SubnetCIDR, networkID)
-	if err != nil {
-		return err
+	fixedIPs := make([]ports.IP, 0, 2)
+	for _, fixed := range []struct{ ip, subnetCIDR string }{
+		{host.IP, cloud.Network.SubnetCIDR},
+		{host.IPv6, cloud.Network.SubnetCIDR6},
+	} {
+		if fixed.ip == "" {
+			continue
+		}
+		subnetID, err := s.FindSubnetIDByNetwork(fixed.subnetCIDR, networkID)
+		if err != nil {
+			return err
+		}
+		fixedIPs = append(fixedIPs, ports.IP{IPAddress: fixed.ip, SubnetID: subnetID})
 	}
 	var customConfig []byte
 	if len(cloud.CustomConfig) == 0 {
@@ -306,17 +400,29 @@ func (s *DataSource) Create(
 	userData, err := GenerateCloudConfig(&CloudConfigOpts{
 		AuthorizedKeys:    cloud.AuthorizedKeys,
 		PostScripts:       cloud.PostScripts,
-		DNS:               cloud.Network.DNS,
+		DNS:               cloud.Network.Nameservers(),
 		Search:            cloud.Network.Search,
 		CustomCloudConfig: string(customConfig),
 	})
 	if err != nil {
 		return err
 	}
-	portID, err := s.CreatePort(host.IP, networkID, subnetID)
+	tx := rollback.New("create " + host.Name)
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	portID, err := s.CreatePort(networkID, fixedIPs)
 	if err != nil {
 		return err
 	}
+	tx.Push("port "+portID, func(ctx context.Context) error {
+		return s.withContext(ctx).DeletePort(portID)
+	})
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	configDrive := true
 	server, err := bootfromvolume.Create(s.computeClient, bootfromvolume.CreateOptsExt{
 		CreateOptsBuilder: servers.CreateOpts{
@@ -324,6 +430,7 @@ func (s *DataSource) Create(
 			ImageRef:  image,
 			FlavorRef: flavor,
 			UserData:  userData,
+			Metadata:  map[string]string{MetadataHostname: host.Name},
 			Networks: []servers.Network{
 				{
 					Port: portID,
@@ -349,15 +456,93 @@ func (s *DataSource) Create(
 		},
 	}).Extract()
 	if err != nil {
-		if err := s.DeletePort(portID); err != nil {
-			logger.I.Error("failed to delete port", zap.Error(err))
-		}
 		return err
 	}
+	tx.Push("server "+server.ID, func(ctx context.Context) error {
+		return s.undoServer(ctx, server.ID)
+	})
+	if err := s.waitForBuild(ctx, server.ID); err != nil {
+		return err
+	}
+	tx.Commit()
 	logger.I.Info("spawned a server", zap.Any("server", server))
 	return nil
 }
 
+// waitForBuild waits for the server to leave the BUILD status, for at most the build timeout.
+//
+// The caller rolls back the server on error.
+func (s *DataSource) waitForBuild(parent context.Context, serverID string) error {
+	ctx, cancel := context.WithTimeout(parent, s.buildTimeout)
+	defer cancel()
+	s = s.withContext(ctx)
+	timedOut := fmt.Errorf("server %s still building after %s", serverID, s.buildTimeout)
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for {
+		server, err := servers.Get(s.computeClient, serverID).Extract()
+		if ctx.Err() != nil && parent.Err() == nil {
+			return timedOut
+		}
+		if err != nil {
+			return err
+		}
+		switch server.Status {
+		case "ACTIVE":
+			return nil
+		case "ERROR":
+			return fmt.Errorf("server build failed: %s", server.Fault.Message)
+		}
+		select {
+		case <-ctx.Done():
+			if err := parent.Err(); err != nil {
+				return err
+			}
+			return timedOut
+		case <-ticker.C:
+		}
+	}
+}
+
+// undoServer deletes a server and the volumes it left behind.
+func (s *DataSource) undoServer(ctx context.Context, serverID string) error {
+	s = s.withContext(ctx)
+	server, err := servers.Get(s.computeClient, serverID).Extract()
+	if _, ok := err.(gophercloud.ErrDefault404); ok {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := servers.ForceDelete(s.computeClient, serverID).ExtractErr(); err != nil {
+		return err
+	}
+	if len(server.AttachedVolumes) == 0 || s.volumeClient == nil {
+		return nil
+	}
+
+	// The volumes are released once the server is gone
+	if err := servers.WaitForStatus(s.computeClient, serverID, "DELETED", 120); err != nil {
+		if _, ok := err.(gophercloud.ErrDefault404); !ok {
+			return err
+		}
+	}
+	var errs []error
+	for _, volume := range server.AttachedVolumes {
+		err := volumes.Delete(s.volumeClient, volume.ID, volumes.DeleteOpts{}).ExtractErr()
+		if _, ok := err.(gophercloud.ErrDefault404); ok {
+			// Deleted on termination
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("volume %s: %w", volume.ID, err))
+			continue
+		}
+		logger.I.Info("deleted volume", zap.String("volume", volume.ID))
+	}
+	return errors.Join(errs...)
+}
+
 // FindServerID retrieves the instance UUID by name
 func (s *DataSource) FindServerID(name string) (string, error) {
 	logger.I.Debug("FindServerID called", zap.String("name", name))
@@ -393,18 +578,29 @@ func (s *DataSource) FindServerID(name string) (string, error) {
 }
 
 func (s *DataSource) Delete(ctx context.Context, name string) error {
+	s = s.withContext(ctx)
 	logger.I.Warn("Delete called", zap.String("name", name))
-	serverID, err := try.Do(func() (string, error) {
+	serverID, err := try.Do(ctx, s.Retry, func() (string, error) {
 		return s.FindServerID(name)
-	}, 3, 5*time.Second)
+	})
 	if err != nil {
 		return err
 	}
 
+	if err := s.deleteServer(ctx, serverID); err != nil {
+		return err
+	}
+
+	logger.I.Warn("deleted a server", zap.Any("server", serverID))
+	return nil
+}
+
+// deleteServer deletes the port attached to a server, then the server itself
+func (s *DataSource) deleteServer(ctx context.Context, serverID string) error {
 	// Find associated port and delete it
-	portID, err := try.Do(func() (string, error) {
+	portID, err := try.Do(ctx, s.Retry, func() (string, error) {
 		return s.FindPortByDeviceID(serverID)
-	}, 10, 5*time.Second)
+	})
 	if err != nil {
 		logger.I.Warn("couldn't delete port of associated server",
 			zap.Any("serverID", serverID),
@@ -418,11 +614,304 @@ func (s *DataSource) Delete(ctx context.Context, name string) error {
 	}
 
 	// Finally, delete the server
-	err = servers.ForceDelete(s.computeClient, serverID).ExtractErr()
+	return servers.ForceDelete(s.computeClient, serverID).ExtractErr()
+}
+
+// ListDangling returns the ports of the cloud network which are not bound to any device,
+// and whose fixed IP is the IP of a host of the cloud
+func (s *DataSource) ListDangling(
+	ctx context.Context,
+	cloud *config.Cloud,
+) ([]provider.Resource, error) {
+	s = s.withContext(ctx)
+	logger.I.Debug("ListDangling called")
+	if cloud.Network == nil {
+		return nil, nil
+	}
+	hosts, err := cloud.GenerateHosts()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	hostnames := make(map[string]string, len(hosts))
+	for _, host := range hosts {
+		for _, ip := range []string{host.IP, host.IPv6} {
+			if ip != "" {
+				hostnames[ip] = host.Name
+			}
+		}
+	}
+	networkID, err := s.FindNetworkID(cloud.Network.Name)
+	if err != nil {
+		return nil, err
+	}
+	pager := ports.List(s.networkClient, ports.ListOpts{
+		NetworkID: networkID,
+	})
 
-	logger.I.Warn("deleted a server", zap.Any("server", serverID))
-	return nil
+	var out []provider.Resource
+	err = pager.EachPage(func(p pagination.Page) (bool, error) {
+		list, err := ports.ExtractPorts(p)
+		if err != nil {
+			return false, err
+		}
+
+		for _, port := range list {
+			if port.DeviceID != "" || port.DeviceOwner != "" {
+				continue
+			}
+			for _, fixed := range port.FixedIPs {
+				if name, ok := hostnames[fixed.IPAddress]; ok {
+					out = append(out, provider.Resource{
+						Kind: provider.ResourcePort,
+						ID:   port.ID,
+						Name: name,
+					})
+					break
+				}
+			}
+		}
+
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Check resolves the images, flavors, network and subnets of the cloud, and compares the
+// instances, cores and RAM of all the hosts with the compute limits of the tenant
+func (s *DataSource) Check(ctx context.Context, cloud *config.Cloud) ([]provider.Finding, error) {
+	s = s.withContext(ctx)
+	logger.I.Debug("Check called")
+	demand, err := cloud.Demand()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []provider.Finding
+	for _, name := range config.Names(demand.Images) {
+		if _, err := s.FindImageID(name); err != nil {
+			out = append(out, provider.Finding{
+				Kind:    "image",
+				Name:    name,
+				Message: fmt.Sprintf("%s, used by %d host(s)", err, demand.Images[name]),
+			})
+		}
+	}
+	var cores, ram int64
+	for _, name := range config.Names(demand.Flavors) {
+		id, err := s.FindFlavorID(name)
+		if err == nil {
+			var flavor *flavors.Flavor
+			flavor, err = flavors.Get(s.computeClient, id).Extract()
+			if err == nil {
+				cores += int64(flavor.VCPUs * demand.Flavors[name])
+				ram += int64(flavor.RAM * demand.Flavors[name])
+			}
+		}
+		if err != nil {
+			out = append(out, provider.Finding{
+				Kind:    "flavor",
+				Name:    name,
+				Message: fmt.Sprintf("%s, used by %d host(s)", err, demand.Flavors[name]),
+			})
+		}
+	}
+
+	if cloud.Network == nil {
+		out = append(out, provider.Finding{Kind: "network", Message: "no network is configured"})
+	} else if networkID, err := s.FindNetworkID(cloud.Network.Name); err != nil {
+		out = append(out, provider.Finding{Kind: "network", Name: cloud.Network.Name, Message: err.Error()})
+	} else {
+		for _, cidr := range []string{cloud.Network.SubnetCIDR, cloud.Network.SubnetCIDR6} {
+			if cidr == "" {
+				continue
+			}
+			if _, err := s.FindSubnetIDByNetwork(cidr, networkID); err != nil {
+				out = append(out, provider.Finding{Kind: "subnet", Name: cidr, Message: err.Error()})
+			}
+		}
+	}
+
+	limit, err := limits.Get(s.computeClient, nil).Extract()
+	if err != nil {
+		return out, err
+	}
+	for _, quota := range []struct {
+		name            string
+		limit, required int64
+	}{
+		{"instances", int64(limit.Absolute.MaxTotalInstances), int64(demand.Hosts)},
+		{"cores", int64(limit.Absolute.MaxTotalCores), cores},
+		{"ram", int64(limit.Absolute.MaxTotalRAMSize), ram},
+	} {
+		if finding, ok := provider.CheckQuota(quota.name, quota.limit, quota.required); ok {
+			out = append(out, finding)
+		}
+	}
+	return out, nil
+}
+
+// DeleteResource deletes a server or a port by UUID
+func (s *DataSource) DeleteResource(ctx context.Context, resource provider.Resource) error {
+	s = s.withContext(ctx)
+	logger.I.Warn("DeleteResource called", zap.Any("resource", resource))
+	switch resource.Kind {
+	case provider.ResourceServer:
+		err := servers.Get(s.computeClient, resource.ID).Err
+		if _, ok := err.(gophercloud.ErrDefault404); ok {
+			return provider.ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return s.deleteServer(ctx, resource.ID)
+	case provider.ResourcePort:
+		return s.DeletePort(resource.ID)
+	}
+	return fmt.Errorf("unsupported resource kind: %s", resource.Kind)
+}
+
+// List returns every server of the tenant
+func (s *DataSource) List(ctx context.Context) ([]provider.Instance, error) {
+	s = s.withContext(ctx)
+	logger.I.Debug("List called")
+	pager := servers.List(s.computeClient, servers.ListOpts{})
+
+	var out []provider.Instance
+	err := pager.EachPage(func(p pagination.Page) (bool, error) {
+		list, err := servers.ExtractServers(p)
+		if err != nil {
+			return false, err
+		}
+
+		for _, server := range list {
+			out = append(out, toInstance(&server))
+		}
+
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Get returns the most recent server named name
+func (s *DataSource) Get(ctx context.Context, name string) (*provider.Instance, error) {
+	s = s.withContext(ctx)
+	logger.I.Debug("Get called", zap.String("name", name))
+	pager := servers.List(s.computeClient, servers.ListOpts{
+		Name: name,
+	})
+
+	var out []provider.Instance
+	err := pager.EachPage(func(p pagination.Page) (bool, error) {
+		list, err := servers.ExtractServers(p)
+		if err != nil {
+			return false, err
+		}
+
+		for _, server := range list {
+			out = append(out, toInstance(&server))
+		}
+
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	instance, err := provider.Newest(out, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetch the attached ports
+	pager = ports.List(s.networkClient, ports.ListOpts{
+		DeviceID: instance.ProviderID,
+	})
+	err = pager.EachPage(func(p pagination.Page) (bool, error) {
+		list, err := ports.ExtractPorts(p)
+		if err != nil {
+			return false, err
+		}
+
+		for _, port := range list {
+			instance.Ports = append(instance.Ports, port.ID)
+		}
+
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return instance, nil
+}
+
+func toInstance(server *servers.Server) provider.Instance {
+	var ips []string
+	networks := make([]string, 0, len(server.Addresses))
+	for network, addresses := range server.Addresses {
+		networks = append(networks, network)
+		list, ok := addresses.([]interface{})
+		if !ok {
+			continue
+		}
+		for _, address := range list {
+			if m, ok := address.(map[string]interface{}); ok {
+				if addr, ok := m["addr"].(string); ok {
+					ips = append(ips, addr)
+				}
+			}
+		}
+	}
+	sort.Strings(ips)
+	sort.Strings(networks)
+
+	flavor, _ := server.Flavor["original_name"].(string)
+	if flavor == "" {
+		flavor, _ = server.Flavor["id"].(string)
+	}
+	image, _ := server.Image["id"].(string)
+
+	var createdAt *time.Time
+	if !server.Created.IsZero() {
+		createdAt = &server.Created
+	}
+
+	var volumes []string
+	for _, volume := range server.AttachedVolumes {
+		volumes = append(volumes, volume.ID)
+	}
+
+	return provider.Instance{
+		Name:       server.Name,
+		ProviderID: server.ID,
+		State:      toState(server.Status),
+		IPs:        ips,
+		Networks:   networks,
+		Flavor:     flavor,
+		Image:      image,
+		CreatedAt:  createdAt,
+		Volumes:    volumes,
+		Managed:    server.Metadata[MetadataHostname] != "",
+	}
+}
+
+func toState(status string) provider.State {
+	switch status {
+	case "ACTIVE":
+		return provider.StateRunning
+	case "BUILD", "REBUILD", "REBOOT", "HARD_REBOOT", "MIGRATING", "RESIZE":
+		return provider.StatePending
+	case "SHUTOFF", "SUSPENDED", "PAUSED", "SHELVED", "SHELVED_OFFLOADED":
+		return provider.StateStopped
+	case "DELETED", "SOFT_DELETED":
+		return provider.StateTerminated
+	case "ERROR":
+		return provider.StateError
+	default:
+		return provider.StateUnknown
+	}
 }
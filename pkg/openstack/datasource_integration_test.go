@@ -7,6 +7,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
 	"github.com/squarefactory/cloud-burster/logger"
 	"github.com/squarefactory/cloud-burster/pkg/config"
 	"github.com/squarefactory/cloud-burster/pkg/openstack"
@@ -100,7 +101,7 @@ func (suite *DataSourceTestSuite) TestCreatePort() {
 	suite.NoError(err)
 	suite.NotEmpty(subnetID)
 
-	res, err := suite.impl.CreatePort(host.IP, networkID, subnetID)
+	res, err := suite.impl.CreatePort(networkID, []ports.IP{{IPAddress: host.IP, SubnetID: subnetID}})
 
 	// Assert
 	suite.NoError(err)
@@ -117,6 +118,15 @@ func (suite *DataSourceTestSuite) TestCreatePort() {
 	suite.NoError(err)
 }
 
+func (suite *DataSourceTestSuite) TestCheck() {
+	// Act
+	findings, err := suite.impl.Check(context.Background(), &cloud)
+
+	// Assert
+	suite.NoError(err)
+	suite.Empty(findings)
+}
+
 func (suite *DataSourceTestSuite) TestCreate() {
 	// Act
 	err := suite.impl.Create(context.Background(), &host, &cloud)
@@ -132,15 +142,17 @@ func (suite *DataSourceTestSuite) TestCreate() {
 }
 
 func (suite *DataSourceTestSuite) BeforeTest(suiteName, testName string) {
-	suite.impl = openstack.New(
-		suite.endpoint,
-		suite.user,
-		suite.password,
-		suite.tenantID,
-		suite.tenantName,
-		suite.region,
-		suite.domainID,
-	)
+	impl, err := openstack.New(&openstack.Options{
+		IdentityEndpoint: suite.endpoint,
+		UserName:         suite.user,
+		Password:         suite.password,
+		TenantID:         suite.tenantID,
+		TenantName:       suite.tenantName,
+		Region:           suite.region,
+		DomainID:         suite.domainID,
+	}, nil)
+	suite.Require().NoError(err)
+	suite.impl = impl
 }
 func TestDataSourceTestSuite(t *testing.T) {
 	user := os.Getenv("OS_USERNAME")
@@ -0,0 +1,113 @@
+//go:build unit
+
+package openstack_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/squarefactory/cloud-burster/pkg/openstack"
+	"github.com/stretchr/testify/suite"
+)
+
+// keystone issues a new token on each authentication, and only accepts the last one.
+type keystone struct {
+	mu     sync.Mutex
+	issued int
+	valid  string
+}
+
+func (k *keystone) expire() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.valid = ""
+}
+
+func (k *keystone) authentications() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.issued
+}
+
+func (k *keystone) handler(url func() string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v3/auth/tokens", func(w http.ResponseWriter, r *http.Request) {
+		k.mu.Lock()
+		k.issued++
+		k.valid = fmt.Sprintf("token-%d", k.issued)
+		w.Header().Set("X-Subject-Token", k.valid)
+		k.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"token": map[string]any{
+				"expires_at": "2100-01-01T00:00:00.000000Z",
+				"catalog": []map[string]any{
+					{
+						"type": "compute",
+						"endpoints": []map[string]any{
+							{"interface": "public", "region": "GRA9", "url": url() + "/compute/"},
+						},
+					},
+					{
+						"type": "network",
+						"endpoints": []map[string]any{
+							{"interface": "public", "region": "GRA9", "url": url() + "/network/"},
+						},
+					},
+				},
+			},
+		})
+	})
+	mux.HandleFunc("/compute/servers/detail", func(w http.ResponseWriter, r *http.Request) {
+		k.mu.Lock()
+		valid := k.valid
+		k.mu.Unlock()
+		if r.Header.Get("X-Auth-Token") != valid {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"servers": []}`))
+	})
+	return mux
+}
+
+type DataSourceUnitTestSuite struct {
+	suite.Suite
+}
+
+func (suite *DataSourceUnitTestSuite) TestReauthenticate() {
+	// Arrange
+	k := &keystone{}
+	var server *httptest.Server
+	server = httptest.NewServer(k.handler(func() string { return server.URL }))
+	defer server.Close()
+	ds, err := openstack.New(&openstack.Options{
+		IdentityEndpoint: server.URL + "/v3/",
+		UserName:         "user",
+		Password:         "password",
+		DomainID:         "default",
+		Region:           "GRA9",
+	}, nil)
+	suite.Require().NoError(err)
+	k.expire()
+
+	// Act
+	_, err = ds.List(context.Background())
+	suite.Require().NoError(err)
+	_, err = ds.List(context.Background())
+
+	// Assert
+	suite.NoError(err)
+	suite.Equal(2, k.authentications(), "the refreshed token should be shared")
+}
+
+func TestDataSourceUnitTestSuite(t *testing.T) {
+	suite.Run(t, &DataSourceUnitTestSuite{})
+}
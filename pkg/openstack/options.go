@@ -0,0 +1,45 @@
+package openstack
+
+import (
+	"time"
+
+	"github.com/squarefactory/cloud-burster/pkg/cloud"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	validation "github.com/squarefactory/cloud-burster/validate"
+)
+
+func init() {
+	cloud.Register("openstack", cloud.Provider{
+		Description: "OpenStack clouds, like OVHcloud, through Nova, Neutron and Cinder.",
+		Options:     func() config.ProviderOptions { return &Options{} },
+		New: func(conf *config.Cloud) (cloud.DataSource, error) {
+			ds, err := New(conf.Provider.(*Options), conf.RateLimit.Limiter())
+			if err != nil {
+				return nil, err
+			}
+			ds.Retry = conf.Retry.Apply(ds.Retry)
+			return ds, nil
+		},
+	})
+}
+
+// Options are the options of the provider block of the openstack clouds.
+//
+// If you have the openrc.sh file, IdentityEndpoint is OS_AUTH_URL, UserName is OS_USERNAME,
+// Password is OS_PASSWORD, Region is OS_REGION_NAME, TenantID is OS_PROJECT_ID, TenantName is
+// OS_PROJECT_NAME and DomainID is OS_PROJECT_DOMAIN_ID.
+type Options struct {
+	IdentityEndpoint string `yaml:"identityEndpoint" validate:"omitempty,url"`
+	UserName         string `yaml:"username"`
+	Password         string `yaml:"password"`
+	TenantID         string `yaml:"tenantID"`
+	TenantName       string `yaml:"tenantName"`
+	DomainID         string `yaml:"domainID"`
+	Region           string `yaml:"region"`
+	// BuildTimeout bounds the wait for a new server to become active. Defaults to 10 minutes.
+	BuildTimeout time.Duration `yaml:"buildTimeout,omitempty" validate:"omitempty,min=0"`
+}
+
+func (o *Options) Validate() error {
+	return validation.I.Struct(o)
+}
@@ -1,15 +1,15 @@
 //go:build unit
 
-package config_test
+package openstack_test
 
 import (
 	"testing"
 
-	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/openstack"
 	"github.com/stretchr/testify/suite"
 )
 
-var cleanOpenstack = config.Openstack{
+var cleanOptions = openstack.Options{
 	IdentityEndpoint: "https://auth.cloud.ovh.net/",
 	UserName:         "user",
 	Password:         "password",
@@ -19,23 +19,23 @@ var cleanOpenstack = config.Openstack{
 	Region:           "GRA9",
 }
 
-type OpenstackTestSuite struct {
+type OptionsTestSuite struct {
 	suite.Suite
 }
 
-func (suite *OpenstackTestSuite) TestValidate() {
+func (suite *OptionsTestSuite) TestValidate() {
 	tests := []struct {
-		input         *config.Openstack
+		input         *openstack.Options
 		isError       bool
 		errorContains []string
 		title         string
 	}{
 		{
-			input: &cleanOpenstack,
+			input: &cleanOptions,
 			title: "Positive test",
 		},
 		{
-			input: &config.Openstack{},
+			input: &openstack.Options{},
 			title: "Positive test: Empty fields",
 		},
 		{
@@ -44,14 +44,14 @@ func (suite *OpenstackTestSuite) TestValidate() {
 				"url",
 				"IdentityEndpoint",
 			},
-			input: &config.Openstack{
+			input: &openstack.Options{
 				IdentityEndpoint: "aaa",
-				UserName:         cleanOpenstack.UserName,
-				Password:         cleanOpenstack.Password,
-				TenantID:         cleanOpenstack.TenantID,
-				TenantName:       cleanOpenstack.TenantName,
-				DomainID:         cleanOpenstack.DomainID,
-				Region:           cleanOpenstack.Region,
+				UserName:         cleanOptions.UserName,
+				Password:         cleanOptions.Password,
+				TenantID:         cleanOptions.TenantID,
+				TenantName:       cleanOptions.TenantName,
+				DomainID:         cleanOptions.DomainID,
+				Region:           cleanOptions.Region,
 			},
 			title: "Valid URL",
 		},
@@ -75,6 +75,6 @@ func (suite *OpenstackTestSuite) TestValidate() {
 	}
 }
 
-func TestOpenstackTestSuite(t *testing.T) {
-	suite.Run(t, &OpenstackTestSuite{})
+func TestOptionsTestSuite(t *testing.T) {
+	suite.Run(t, &OptionsTestSuite{})
 }
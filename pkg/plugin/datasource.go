@@ -0,0 +1,376 @@
+// Package plugin delegates the instances of a cloud to an external executable.
+//
+// For each operation, the executable is called with the operation as its last argument, and
+// gets a Request as JSON on stdin. It writes Messages on stdout, one JSON object per line:
+// logs and progress are forwarded to the logger as they arrive, and a result ends the
+// operation. A non-zero exit code fails the operation.
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/squarefactory/cloud-burster/logger"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/provider"
+	"github.com/squarefactory/cloud-burster/utils/try"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// maxLineSize bounds the lines written by the executable, results included.
+	maxLineSize = 16 << 20
+	// stderrTail is the number of lines of stderr reported when the executable fails.
+	stderrTail = 5
+	// waitDelay is how long the executable has to exit once interrupted, before being killed.
+	waitDelay = 10 * time.Second
+)
+
+// DefaultRetry is the default retry policy of the operations failing with a retryable result.
+var DefaultRetry = try.Policy{
+	MaxElapsed:   5 * time.Minute,
+	InitialDelay: 5 * time.Second,
+	MaxDelay:     time.Minute,
+	Multiplier:   2,
+	Jitter:       0.2,
+	Retryable:    Retryable,
+}
+
+// Retryable returns true for the error results marked as retryable by the executable.
+func Retryable(err error) bool {
+	var retryable *retryableError
+	return errors.As(err, &retryable)
+}
+
+// retryableError is an error result marked as retryable.
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+type DataSource struct {
+	// Retry is the retry policy of the operations failing with a retryable result.
+	Retry   try.Policy
+	command string
+	args    []string
+	env     []string
+	timeout time.Duration
+	cloud   *config.Cloud
+	// limiter bounds the calls of the executable, nil for no limit.
+	limiter *rate.Limiter
+}
+
+// New returns a data source calling the command of the options for the instances of cloud.
+// Each call waits for a token of limiter if not nil.
+func New(opts *Options, cloud *config.Cloud, limiter *rate.Limiter) (*DataSource, error) {
+	env := make([]string, 0, len(opts.Env))
+	for k, v := range opts.Env {
+		env = append(env, k+"="+v)
+	}
+	sort.Strings(env)
+	return &DataSource{
+		Retry:   DefaultRetry,
+		command: opts.Command,
+		args:    opts.Args,
+		env:     env,
+		timeout: opts.Timeout,
+		cloud:   cloud,
+		limiter: limiter,
+	}, nil
+}
+
+// Create calls the create operation with the host and its rendered user-data.
+func (s *DataSource) Create(
+	ctx context.Context,
+	host *config.Host,
+	cloud *config.Cloud,
+) error {
+	logger.I.Debug(
+		"Create called",
+		zap.Any("host", host),
+		zap.Any("cloud", cloud),
+	)
+
+	var customConfig []byte
+	if len(cloud.CustomConfig) > 0 {
+		var err error
+		customConfig, err = yaml.Marshal(cloud.CustomConfig)
+		if err != nil {
+			return err
+		}
+	}
+	opts := &CloudConfigOpts{
+		Hostname:          host.Name,
+		AuthorizedKeys:    cloud.AuthorizedKeys,
+		PostScripts:       cloud.PostScripts,
+		CustomCloudConfig: string(customConfig),
+	}
+	if cloud.Network != nil {
+		opts.DNS = cloud.Network.Nameservers()
+		opts.Search = cloud.Network.Search
+	}
+	userData, err := GenerateCloudConfig(opts)
+	if err != nil {
+		return err
+	}
+
+	hostValue, err := keysOf(host)
+	if err != nil {
+		return err
+	}
+	cloudValue, err := keysOf(cloud)
+	if err != nil {
+		return err
+	}
+	_, err = s.run(ctx, &Request{
+		Version:   ProtocolVersion,
+		Operation: OperationCreate,
+		Name:      host.Name,
+		Host:      hostValue,
+		Cloud:     cloudValue,
+		UserData:  string(userData),
+	})
+	return err
+}
+
+// Delete calls the delete operation. It returns provider.ErrNotFound if the executable
+// reports that the instance does not exist.
+func (s *DataSource) Delete(ctx context.Context, name string) error {
+	logger.I.Debug("Delete called", zap.String("name", name))
+
+	cloudValue, err := keysOf(s.cloud)
+	if err != nil {
+		return err
+	}
+	_, err = s.run(ctx, &Request{
+		Version:   ProtocolVersion,
+		Operation: OperationDelete,
+		Name:      name,
+		Cloud:     cloudValue,
+	})
+	return err
+}
+
+// List calls the list operation.
+func (s *DataSource) List(ctx context.Context) ([]provider.Instance, error) {
+	cloudValue, err := keysOf(s.cloud)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.run(ctx, &Request{
+		Version:   ProtocolVersion,
+		Operation: OperationList,
+		Cloud:     cloudValue,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, fmt.Errorf("%s %s: no result", s.command, OperationList)
+	}
+	return result.Instances, nil
+}
+
+// Get returns the newest instance named name from List.
+func (s *DataSource) Get(ctx context.Context, name string) (*provider.Instance, error) {
+	instances, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return provider.Newest(instances, name)
+}
+
+// run calls the executable with the request, again while it fails with a retryable result.
+func (s *DataSource) run(ctx context.Context, req *Request) (*Message, error) {
+	return try.Do(ctx, s.Retry, func() (*Message, error) {
+		if s.limiter != nil {
+			if err := s.limiter.Wait(ctx); err != nil {
+				return nil, try.Permanent(err)
+			}
+		}
+		return s.call(ctx, req)
+	})
+}
+
+// call calls the executable once with the request, and returns its result, nil if it exited
+// without one.
+func (s *DataSource) call(ctx context.Context, req *Request) (*Message, error) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	input, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	args := append(s.args[:len(s.args):len(s.args)], string(req.Operation))
+	cmd := exec.CommandContext(ctx, s.command, args...)
+	cmd.Env = append(os.Environ(), s.env...)
+	cmd.Stdin = bytes.NewReader(input)
+	// Let the executable clean up
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.WaitDelay = waitDelay
+	// Pipes rather than StdoutPipe: Wait returns even if a child of the executable keeps
+	// the output open
+	stdout, stdoutW := io.Pipe()
+	stderr, stderrW := io.Pipe()
+	cmd.Stdout = stdoutW
+	cmd.Stderr = stderrW
+
+	log := logger.I.With(
+		zap.String("command", s.command),
+		zap.String("operation", string(req.Operation)),
+	)
+	if req.Name != "" {
+		log = log.With(zap.String("name", req.Name))
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	var (
+		wg      sync.WaitGroup
+		tail    []string
+		result  *Message
+		scanErr error
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_ = scanLines(stderr, func(line string) {
+			log.Info(line, zap.String("stream", "stderr"))
+			tail = append(tail, line)
+			if len(tail) > stderrTail {
+				tail = tail[1:]
+			}
+		})
+	}()
+	go func() {
+		defer wg.Done()
+		scanErr = scanLines(stdout, func(line string) {
+			var msg Message
+			if err := json.Unmarshal([]byte(line), &msg); err != nil || msg.Type == "" {
+				log.Info(line, zap.String("stream", "stdout"))
+				return
+			}
+			switch msg.Type {
+			case MessageResult:
+				result = &msg
+			case MessageProgress:
+				fields := []zap.Field{zap.String("message", msg.Message)}
+				if msg.Percent != nil {
+					fields = append(fields, zap.Int("percent", *msg.Percent))
+				}
+				log.Info("progress", fields...)
+			default:
+				logMessage(log, &msg)
+			}
+		})
+	}()
+	err = cmd.Wait()
+	_ = stdoutW.Close()
+	_ = stderrW.Close()
+	wg.Wait()
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, fmt.Errorf("%s %s: %w", s.command, req.Operation, ctxErr)
+	}
+	if err != nil {
+		if len(tail) > 0 {
+			return nil, fmt.Errorf("%s %s: %w: %s", s.command, req.Operation, err, strings.Join(tail, "\n"))
+		}
+		return nil, fmt.Errorf("%s %s: %w", s.command, req.Operation, err)
+	}
+	if scanErr != nil {
+		return nil, fmt.Errorf("%s %s: couldn't read the output: %w", s.command, req.Operation, scanErr)
+	}
+	if result != nil {
+		switch {
+		case result.NotFound && result.Error != "":
+			return nil, fmt.Errorf("%w: %s", provider.ErrNotFound, result.Error)
+		case result.NotFound:
+			return nil, provider.ErrNotFound
+		case result.Error != "" && result.Retryable:
+			return nil, &retryableError{err: fmt.Errorf("%s %s: %s", s.command, req.Operation, result.Error)}
+		case result.Error != "":
+			return nil, fmt.Errorf("%s %s: %s", s.command, req.Operation, result.Error)
+		}
+	}
+	return result, nil
+}
+
+// scanLines calls fn for each line of r, then drains r.
+func scanLines(r io.Reader, fn func(line string)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			fn(line)
+		}
+	}
+	err := scanner.Err()
+	// Do not block the executable on a full pipe
+	_, _ = io.Copy(io.Discard, r)
+	return err
+}
+
+// logMessage forwards a log message of the executable.
+func logMessage(log *zap.Logger, msg *Message) {
+	keys := make([]string, 0, len(msg.Fields))
+	for k := range msg.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fields := make([]zap.Field, 0, len(keys))
+	for _, k := range keys {
+		fields = append(fields, zap.Any(k, msg.Fields[k]))
+	}
+	switch msg.Level {
+	case "debug":
+		log.Debug(msg.Message, fields...)
+	case "warn", "warning":
+		log.Warn(msg.Message, fields...)
+	case "error":
+		log.Error(msg.Message, fields...)
+	default:
+		log.Info(msg.Message, fields...)
+	}
+}
+
+// keysOf returns v with the keys of the configuration file, by encoding it to YAML.
+func keysOf(v interface{}) (map[string]interface{}, error) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,142 @@
+//go:build unit
+
+package plugin_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/plugin"
+	"github.com/squarefactory/cloud-burster/pkg/provider"
+	"github.com/stretchr/testify/suite"
+)
+
+var cleanCloud = config.Cloud{
+	Type: "exec",
+	AuthorizedKeys: []string{
+		"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIDUnXMBGq6bV6H+c7P5QjDn1soeB6vkodi6OswcZsMwH nguye@PC-DARKNESS4",
+	},
+	Network: &config.Network{
+		Name:       "net",
+		SubnetCIDR: "172.28.0.0/20",
+		DNS:        "1.1.1.1",
+		Gateway:    "172.28.0.2",
+	},
+}
+
+type DataSourceTestSuite struct {
+	suite.Suite
+	impl *plugin.DataSource
+}
+
+func (suite *DataSourceTestSuite) BeforeTest(suiteName, testName string) {
+	impl, err := plugin.New(&plugin.Options{
+		Command: "testdata/provider.sh",
+		Env:     map[string]string{"STATE_DIR": suite.T().TempDir()},
+		Timeout: time.Second,
+	}, &cleanCloud, nil)
+	suite.Require().NoError(err)
+	impl.Retry.InitialDelay = time.Millisecond
+	suite.impl = impl
+}
+
+func (suite *DataSourceTestSuite) TestCreate() {
+	tests := []struct {
+		name          string
+		isError       bool
+		errorContains []string
+		title         string
+	}{
+		{
+			name:  "cn1",
+			title: "Positive test",
+		},
+		{
+			name:          "full",
+			isError:       true,
+			errorContains: []string{"create: no capacity"},
+			title:         "Error result",
+		},
+		{
+			name:  "busy",
+			title: "Retryable error result",
+		},
+		{
+			name:          "fail",
+			isError:       true,
+			errorContains: []string{"exit status 3", "boom"},
+			title:         "Non-zero exit code",
+		},
+		{
+			name:          "sleep",
+			isError:       true,
+			errorContains: []string{"context deadline exceeded"},
+			title:         "Timeout",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Arrange
+			host := config.Host{
+				Name:       tt.name,
+				DiskSize:   50,
+				FlavorName: "flavor",
+				ImageName:  "image",
+				IP:         "172.28.1.1",
+			}
+
+			// Act
+			err := suite.impl.Create(context.Background(), &host, &cleanCloud)
+
+			// Assert
+			if tt.isError {
+				suite.Error(err)
+				for _, contain := range tt.errorContains {
+					suite.ErrorContains(err, contain)
+				}
+			} else {
+				suite.NoError(err)
+			}
+		})
+	}
+}
+
+func (suite *DataSourceTestSuite) TestDelete() {
+	// Act
+	err := suite.impl.Delete(context.Background(), "cn1")
+	notFoundErr := suite.impl.Delete(context.Background(), "missing")
+
+	// Assert
+	suite.NoError(err)
+	suite.ErrorIs(notFoundErr, provider.ErrNotFound)
+}
+
+func (suite *DataSourceTestSuite) TestList() {
+	// Act
+	instances, err := suite.impl.List(context.Background())
+
+	// Assert
+	suite.NoError(err)
+	suite.Equal([]provider.Instance{
+		{Name: "cn1", ProviderID: "1", State: provider.StateRunning, IPs: []string{"172.28.1.1"}},
+		{Name: "cn2", ProviderID: "2", State: provider.StateStopped},
+	}, instances)
+}
+
+func (suite *DataSourceTestSuite) TestGet() {
+	// Act
+	instance, err := suite.impl.Get(context.Background(), "cn2")
+	_, notFoundErr := suite.impl.Get(context.Background(), "cn3")
+
+	// Assert
+	suite.NoError(err)
+	suite.Equal("2", instance.ProviderID)
+	suite.ErrorIs(notFoundErr, provider.ErrNotFound)
+}
+
+func TestDataSourceTestSuite(t *testing.T) {
+	suite.Run(t, &DataSourceTestSuite{})
+}
@@ -0,0 +1,48 @@
+package plugin
+
+import (
+	"os/exec"
+	"time"
+
+	"github.com/squarefactory/cloud-burster/pkg/cloud"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	validation "github.com/squarefactory/cloud-burster/validate"
+)
+
+func init() {
+	cloud.Register("exec", cloud.Provider{
+		Description: "An external executable, speaking JSON on stdin and stdout.",
+		Options:     func() config.ProviderOptions { return &Options{} },
+		New: func(conf *config.Cloud) (cloud.DataSource, error) {
+			ds, err := New(conf.Provider.(*Options), conf, conf.RateLimit.Limiter())
+			if err != nil {
+				return nil, err
+			}
+			ds.Retry = conf.Retry.Apply(ds.Retry)
+			return ds, nil
+		},
+	})
+}
+
+// Options are the options of the provider block of the exec clouds.
+type Options struct {
+	// Command is the path of the executable, or its name in the PATH.
+	Command string `yaml:"command" validate:"required"`
+	// Args are passed to the executable before the operation.
+	Args []string `yaml:"args,omitempty"`
+	// Env is added to the environment of the executable.
+	Env map[string]string `yaml:"env,omitempty"`
+	// Timeout bounds each call of the executable. 0 for no timeout.
+	Timeout time.Duration `yaml:"timeout,omitempty" validate:"omitempty,min=0"`
+}
+
+// Validate checks the fields, and that the command can be found.
+func (o *Options) Validate() error {
+	if err := validation.I.Struct(o); err != nil {
+		return err
+	}
+	if _, err := exec.LookPath(o.Command); err != nil {
+		return &config.OptionError{Key: "command", Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+//go:build unit
+
+package plugin_test
+
+import (
+	"testing"
+
+	"github.com/squarefactory/cloud-burster/pkg/plugin"
+	"github.com/stretchr/testify/suite"
+)
+
+type OptionsTestSuite struct {
+	suite.Suite
+}
+
+func (suite *OptionsTestSuite) TestValidate() {
+	tests := []struct {
+		input         *plugin.Options
+		isError       bool
+		errorContains []string
+		title         string
+	}{
+		{
+			input: &plugin.Options{Command: "testdata/provider.sh"},
+			title: "Positive test",
+		},
+		{
+			input:         &plugin.Options{},
+			isError:       true,
+			errorContains: []string{"Command", "required"},
+			title:         "Command required",
+		},
+		{
+			input:         &plugin.Options{Command: "testdata/missing.sh"},
+			isError:       true,
+			errorContains: []string{"command", "no such file"},
+			title:         "Command not found",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Act
+			err := tt.input.Validate()
+
+			// Assert
+			if tt.isError {
+				suite.Error(err)
+				for _, contain := range tt.errorContains {
+					suite.ErrorContains(err, contain)
+				}
+			} else {
+				suite.NoError(err)
+			}
+		})
+	}
+}
+
+func TestOptionsTestSuite(t *testing.T) {
+	suite.Run(t, &OptionsTestSuite{})
+}
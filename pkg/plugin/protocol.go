@@ -0,0 +1,66 @@
+package plugin
+
+import "github.com/squarefactory/cloud-burster/pkg/provider"
+
+// ProtocolVersion is the version of the protocol, sent in every request.
+const ProtocolVersion = "v1"
+
+// Operation is called on the executable, as its last argument.
+type Operation string
+
+const (
+	OperationCreate Operation = "create"
+	OperationDelete Operation = "delete"
+	OperationList   Operation = "list"
+)
+
+// Request is written as JSON to the stdin of the executable.
+//
+// Host and Cloud have the keys of the configuration file.
+type Request struct {
+	Version   string    `json:"version"`
+	Operation Operation `json:"operation"`
+	// Name is the hostname, for create and delete.
+	Name string `json:"name,omitempty"`
+	// Host is the host to create.
+	Host map[string]interface{} `json:"host,omitempty"`
+	// Cloud is the cloud of the data source, without its provider block.
+	Cloud map[string]interface{} `json:"cloud"`
+	// UserData is the rendered cloud-init configuration of the host to create.
+	UserData string `json:"userData,omitempty"`
+}
+
+// MessageType is the type of a message of the executable.
+type MessageType string
+
+const (
+	// MessageLog is a log line, with a Level, a Message and Fields.
+	MessageLog MessageType = "log"
+	// MessageProgress reports the progress of the operation, with a Message and a Percent.
+	MessageProgress MessageType = "progress"
+	// MessageResult ends the operation, with an Error, NotFound or the Instances.
+	MessageResult MessageType = "result"
+)
+
+// Message is written by the executable on stdout, one JSON object per line.
+//
+// Lines which are not messages are logged as is, like the lines written on stderr.
+type Message struct {
+	Type MessageType `json:"type"`
+
+	// Level is the level of a log: debug, info, warn or error. info by default.
+	Level   string                 `json:"level,omitempty"`
+	Message string                 `json:"message,omitempty"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+	Percent *int                   `json:"percent,omitempty"`
+
+	// Error is the reason of the failure of the operation.
+	Error string `json:"error,omitempty"`
+	// Retryable is set if the error is transient: the operation is called again, following
+	// the retry policy of the cloud.
+	Retryable bool `json:"retryable,omitempty"`
+	// NotFound is set if the instance to delete does not exist.
+	NotFound bool `json:"notFound,omitempty"`
+	// Instances are the instances returned by list.
+	Instances []provider.Instance `json:"instances,omitempty"`
+}
@@ -0,0 +1,56 @@
+#!/bin/sh
+# A provider for the tests of the exec protocol. The behavior depends on the hostname.
+set -e
+
+request=$(cat)
+operation=$1
+name=$(printf '%s' "$request" | sed -n 's/.*"operation":"[a-z]*","name":"\([^"]*\)".*/\1/p')
+
+case "$operation" in
+create)
+  echo '{"type":"log","level":"debug","message":"request","fields":{"name":"'"$name"'"}}'
+  echo '{"type":"progress","message":"booting","percent":50}'
+  echo 'not a message'
+  echo 'on stderr' >&2
+  case "$name" in
+  fail)
+    echo 'boom' >&2
+    exit 3
+    ;;
+  full)
+    echo '{"type":"result","error":"no capacity"}'
+    ;;
+  busy)
+    # Busy once, in the directory of STATE_DIR
+    if [ -e "$STATE_DIR/busy" ]; then
+      echo '{"type":"result"}'
+    else
+      touch "$STATE_DIR/busy"
+      echo '{"type":"result","error":"busy","retryable":true}'
+    fi
+    ;;
+  sleep)
+    exec sleep 5
+    ;;
+  *)
+    case "$request" in
+    *'"flavorName":"'*'"userData":"#cloud-config'*) echo '{"type":"result"}' ;;
+    *) echo '{"type":"result","error":"missing user-data or host"}' ;;
+    esac
+    ;;
+  esac
+  ;;
+delete)
+  case "$name" in
+  missing) echo '{"type":"result","notFound":true}' ;;
+  *) echo '{"type":"result"}' ;;
+  esac
+  ;;
+list)
+  echo '{"type":"result","instances":[{"name":"cn1","providerID":"1","state":"running","ips":["172.28.1.1"]},{"name":"cn2","providerID":"2","state":"stopped"}]}'
+  ;;
+*)
+  echo "unknown operation: $operation" >&2
+  exit 1
+  ;;
+esac
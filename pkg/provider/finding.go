@@ -0,0 +1,29 @@
+package provider
+
+import "fmt"
+
+// Finding is a problem found by checking a cloud against its provider.
+type Finding struct {
+	// Kind is the kind of the resource: image, flavor, network, subnet or quota.
+	Kind string `json:"kind"    yaml:"kind"`
+	// Name is the name of the resource in the configuration.
+	Name    string `json:"name"    yaml:"name"`
+	Message string `json:"message" yaml:"message"`
+}
+
+func (f Finding) String() string {
+	return f.Kind + " " + f.Name + ": " + f.Message
+}
+
+// CheckQuota returns a finding if the limit of a quota is below the required amount.
+// A negative limit is unlimited.
+func CheckQuota(name string, limit int64, required int64) (Finding, bool) {
+	if limit < 0 || limit >= required {
+		return Finding{}, false
+	}
+	return Finding{
+		Kind:    "quota",
+		Name:    name,
+		Message: fmt.Sprintf("the limit is %d, %d required by all the hosts", limit, required),
+	}, true
+}
@@ -0,0 +1,57 @@
+//go:build unit
+
+package provider_test
+
+import (
+	"testing"
+
+	"github.com/squarefactory/cloud-burster/pkg/provider"
+	"github.com/stretchr/testify/suite"
+)
+
+type FindingTestSuite struct {
+	suite.Suite
+}
+
+func (suite *FindingTestSuite) TestCheckQuota() {
+	tests := []struct {
+		limit    int64
+		required int64
+		expected bool
+		title    string
+	}{
+		{
+			limit:    10,
+			required: 10,
+			title:    "Enough",
+		},
+		{
+			limit:    10,
+			required: 11,
+			expected: true,
+			title:    "Too small",
+		},
+		{
+			limit:    -1,
+			required: 100,
+			title:    "Unlimited",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Act
+			finding, ok := provider.CheckQuota("instances", tt.limit, tt.required)
+
+			// Assert
+			suite.Equal(tt.expected, ok)
+			if tt.expected {
+				suite.Equal("quota instances: the limit is 10, 11 required by all the hosts", finding.String())
+			}
+		})
+	}
+}
+
+func TestFindingTestSuite(t *testing.T) {
+	suite.Run(t, &FindingTestSuite{})
+}
@@ -0,0 +1,64 @@
+package provider
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when no instance matches the requested name.
+var ErrNotFound = errors.New("instance not found")
+
+// State is the provider-neutral lifecycle state of an instance.
+type State string
+
+const (
+	StatePending  State = "pending"
+	StateRunning  State = "running"
+	StateStopped  State = "stopped"
+	StateDeleting State = "deleting"
+	StateError    State = "error"
+	StateUnknown  State = "unknown"
+	// StateTerminated is used for instances that are gone but still reported by the provider.
+	StateTerminated State = "terminated"
+	// StateAbsent is used for configured hosts without a live instance.
+	StateAbsent State = "absent"
+)
+
+// Instance is a provider-neutral record of a live instance.
+//
+// Networks lists the names of the networks the instance is attached to.
+// Ports and Volumes list the IDs of the sub-resources backing the instance, when known.
+// Managed is set if the instance carries the ownership marker of cloud-burster (a tag, a
+// label or metadata), so that it is known to be created by it.
+type Instance struct {
+	Name       string     `json:"name"                yaml:"name"`
+	ProviderID string     `json:"providerID"          yaml:"providerID"`
+	State      State      `json:"state"               yaml:"state"`
+	IPs        []string   `json:"ips,omitempty"       yaml:"ips,omitempty"`
+	Networks   []string   `json:"networks,omitempty"  yaml:"networks,omitempty"`
+	Flavor     string     `json:"flavor,omitempty"    yaml:"flavor,omitempty"`
+	Image      string     `json:"image,omitempty"     yaml:"image,omitempty"`
+	CreatedAt  *time.Time `json:"createdAt,omitempty" yaml:"createdAt,omitempty"`
+	Ports      []string   `json:"ports,omitempty"     yaml:"ports,omitempty"`
+	Volumes    []string   `json:"volumes,omitempty"   yaml:"volumes,omitempty"`
+	Managed    bool       `json:"managed,omitempty"   yaml:"managed,omitempty"`
+}
+
+// Newest returns the most recently created instance named name, or ErrNotFound.
+func Newest(instances []Instance, name string) (*Instance, error) {
+	var found *Instance
+	for i := range instances {
+		inst := &instances[i]
+		if inst.Name != name {
+			continue
+		}
+		if found == nil || (inst.CreatedAt != nil &&
+			(found.CreatedAt == nil || inst.CreatedAt.After(*found.CreatedAt))) {
+			found = inst
+		}
+	}
+	if found == nil {
+		return nil, ErrNotFound
+	}
+	return found, nil
+}
@@ -0,0 +1,67 @@
+//go:build unit
+
+package provider_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/squarefactory/cloud-burster/pkg/provider"
+	"github.com/stretchr/testify/suite"
+)
+
+type InstanceTestSuite struct {
+	suite.Suite
+}
+
+func (suite *InstanceTestSuite) TestNewest() {
+	older := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	instances := []provider.Instance{
+		{Name: "cn1", ProviderID: "a", CreatedAt: &older},
+		{Name: "cn1", ProviderID: "b", CreatedAt: &newer},
+		{Name: "cn2", ProviderID: "c"},
+	}
+
+	tests := []struct {
+		input    string
+		expected *provider.Instance
+		isError  bool
+		title    string
+	}{
+		{
+			input:    "cn1",
+			expected: &instances[1],
+			title:    "Positive test: keep the newest",
+		},
+		{
+			input:    "cn2",
+			expected: &instances[2],
+			title:    "Positive test: no creation time",
+		},
+		{
+			input:   "cn3",
+			isError: true,
+			title:   "Not found",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Act
+			actual, err := provider.Newest(instances, tt.input)
+
+			// Assert
+			if tt.isError {
+				suite.ErrorIs(err, provider.ErrNotFound)
+			} else {
+				suite.NoError(err)
+			}
+			suite.Equal(tt.expected, actual)
+		})
+	}
+}
+
+func TestInstanceTestSuite(t *testing.T) {
+	suite.Run(t, &InstanceTestSuite{})
+}
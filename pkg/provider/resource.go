@@ -0,0 +1,17 @@
+package provider
+
+// ResourceKind is the kind of a provider resource.
+type ResourceKind string
+
+const (
+	ResourceServer      ResourceKind = "server"
+	ResourcePort        ResourceKind = "port"
+	ResourceBlockDevice ResourceKind = "blockDevice"
+)
+
+// Resource identifies a provider resource which can be deleted on its own.
+type Resource struct {
+	Kind ResourceKind `json:"kind"           yaml:"kind"`
+	ID   string       `json:"id"             yaml:"id"`
+	Name string       `json:"name,omitempty" yaml:"name,omitempty"`
+}
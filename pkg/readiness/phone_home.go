@@ -0,0 +1,309 @@
+package readiness
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/squarefactory/cloud-burster/logger"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"go.uber.org/zap"
+)
+
+// ErrAddressInUse is returned when the phone-home address is bound by another process.
+//
+// The phone-home server lives in the process waiting for the hosts: concurrent processes
+// cannot share it.
+var ErrAddressInUse = errors.New("phone-home address in use")
+
+// PhoneHome receives the callbacks of the cloud-init phone_home module.
+//
+// The hostname is read from the "hostname" and "fqdn" form fields, or from the query. Each
+// callback is recorded with its remote IP, so that a host is only ready once it called back
+// from one of its own IPs: anyone on the network can send a hostname.
+//
+// The callbacks are dropped once older than Retention, and the oldest ones are dropped beyond
+// maxCallbacks, so that a long-running server doesn't accumulate them.
+type PhoneHome struct {
+	// Retention is how long a callback is kept. Defaults to DefaultTimeout.
+	Retention time.Duration
+
+	mu   sync.Mutex
+	seen map[callback]time.Time
+}
+
+// maxCallbacks bounds the callbacks kept by a PhoneHome.
+const maxCallbacks = 4096
+
+type callback struct {
+	name string
+	ip   string
+}
+
+func NewPhoneHome() *PhoneHome {
+	return &PhoneHome{
+		seen: make(map[callback]time.Time),
+	}
+}
+
+func (h *PhoneHome) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	var names []string
+	for _, key := range []string{"hostname", "fqdn"} {
+		if name := r.Form.Get(key); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		http.Error(w, "missing hostname", http.StatusBadRequest)
+		return
+	}
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ip := normalizeIP(remote)
+	logger.I.Info("host phoned home", zap.Strings("names", names), zap.String("remote", r.RemoteAddr))
+	h.mu.Lock()
+	h.prune(now)
+	for _, name := range names {
+		if len(h.seen) >= maxCallbacks {
+			h.dropOldest()
+		}
+		h.seen[callback{name: name, ip: ip}] = now
+	}
+	h.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Since returns true if the host phoned home from one of ips after t.
+func (h *PhoneHome) Since(host *config.Host, ips []string, t time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.prune(time.Now())
+	short, _, _ := strings.Cut(host.Name, ".")
+	for _, name := range []string{host.Name, short} {
+		for _, ip := range ips {
+			if at, ok := h.seen[callback{name: name, ip: normalizeIP(ip)}]; ok && !at.Before(t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// retain raises the retention to d, so that the callbacks outlive the probes waiting for them.
+func (h *PhoneHome) retain(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if d > h.retention() {
+		h.Retention = d
+	}
+}
+
+func (h *PhoneHome) retention() time.Duration {
+	if h.Retention > 0 {
+		return h.Retention
+	}
+	return DefaultTimeout
+}
+
+// prune drops the callbacks older than the retention. h.mu must be held.
+func (h *PhoneHome) prune(now time.Time) {
+	retention := h.retention()
+	for cb, at := range h.seen {
+		if now.Sub(at) > retention {
+			delete(h.seen, cb)
+		}
+	}
+}
+
+// dropOldest drops the oldest callback. h.mu must be held.
+func (h *PhoneHome) dropOldest() {
+	var oldest callback
+	var oldestAt time.Time
+	for cb, at := range h.seen {
+		if oldestAt.IsZero() || at.Before(oldestAt) {
+			oldest, oldestAt = cb, at
+		}
+	}
+	delete(h.seen, oldest)
+}
+
+// normalizeIP returns the canonical form of an IP, so that 2001:db8::1 and 2001:0db8::1 match.
+func normalizeIP(s string) string {
+	if ip := net.ParseIP(s); ip != nil {
+		return ip.String()
+	}
+	return s
+}
+
+// PhoneHomeProbe passes when the host phoned home from its IP after the creation of the probe.
+//
+// The callbacks are expected from the IP and IPv6 of the host. Without them, they are expected
+// from the IPs of the live instance, fetched with Getter.
+type PhoneHomeProbe struct {
+	PhoneHome *PhoneHome
+	Since     time.Time
+	Getter    Getter
+}
+
+func (p *PhoneHomeProbe) Check(ctx context.Context, host *config.Host) error {
+	var ips []string
+	for _, ip := range []string{host.IP, host.IPv6} {
+		if ip != "" {
+			ips = append(ips, ip)
+		}
+	}
+	if len(ips) == 0 && p.Getter != nil {
+		instance, err := p.Getter.Get(ctx, host.Name)
+		if err != nil {
+			return err
+		}
+		ips = instance.IPs
+	}
+	if !p.PhoneHome.Since(host, ips, p.Since) {
+		return errors.New("host didn't phone home yet")
+	}
+	return nil
+}
+
+func (p *PhoneHomeProbe) String() string {
+	return config.ProbePhoneHome
+}
+
+type listener struct {
+	server *http.Server
+	home   *PhoneHome
+	refs   int
+}
+
+// Prober builds the probes of a readiness configuration.
+//
+// The phone-home servers are shared between the hosts and stopped once no probe uses them.
+type Prober struct {
+	mu        sync.Mutex
+	listeners map[string]*listener
+}
+
+func NewProber() *Prober {
+	return &Prober{
+		listeners: make(map[string]*listener),
+	}
+}
+
+// Probes builds the probes of conf. release must be called once the probes are no longer used.
+//
+// Build the probes before creating the instance, so that no phone-home callback is missed.
+func (p *Prober) Probes(conf *config.Readiness, getter Getter) (probes []Probe, release func(), err error) {
+	var addresses []string
+	unlisten := func() {
+		for _, address := range addresses {
+			p.unlisten(address)
+		}
+	}
+	defer func() {
+		if err != nil {
+			unlisten()
+		}
+	}()
+
+	for _, pc := range conf.Probes {
+		port := pc.Port
+		if port == 0 {
+			port = defaultPort
+		}
+		switch pc.Type {
+		case config.ProbeState:
+			probes = append(probes, &StateProbe{Getter: getter})
+		case config.ProbeTCP:
+			probes = append(probes, &TCPProbe{Port: port})
+		case config.ProbeSSH:
+			command := pc.Command
+			if command == "" {
+				command = "true"
+			}
+			probe, err := NewSSHProbe(port, pc.User, pc.PrivateKey, command)
+			if err != nil {
+				return nil, nil, err
+			}
+			probes = append(probes, probe)
+		case config.ProbePhoneHome:
+			home, err := p.listen(pc.ListenAddress)
+			if err != nil {
+				return nil, nil, err
+			}
+			home.retain(conf.Timeout)
+			addresses = append(addresses, pc.ListenAddress)
+			probes = append(probes, &PhoneHomeProbe{PhoneHome: home, Since: time.Now(), Getter: getter})
+		default:
+			return nil, nil, fmt.Errorf("unknown probe type: %s", pc.Type)
+		}
+	}
+	return probes, unlisten, nil
+}
+
+func (p *Prober) listen(address string) (*PhoneHome, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if l, ok := p.listeners[address]; ok {
+		l.refs++
+		return l.home, nil
+	}
+	ln, err := net.Listen("tcp", address)
+	if errors.Is(err, syscall.EADDRINUSE) {
+		return nil, fmt.Errorf(
+			"%w: the phone-home address %s is used by another process, "+
+				"create the hosts in a single invocation or through serve",
+			ErrAddressInUse,
+			address,
+		)
+	}
+	if err != nil {
+		return nil, err
+	}
+	home := NewPhoneHome()
+	server := &http.Server{
+		Handler:           home,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.I.Error("phone-home server crashed", zap.Error(err), zap.String("address", address))
+		}
+	}()
+	p.listeners[address] = &listener{server: server, home: home, refs: 1}
+	return home, nil
+}
+
+func (p *Prober) unlisten(address string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	l, ok := p.listeners[address]
+	if !ok {
+		return
+	}
+	l.refs--
+	if l.refs > 0 {
+		return
+	}
+	delete(p.listeners, address)
+	if err := l.server.Close(); err != nil {
+		logger.I.Warn("couldn't close the phone-home server", zap.Error(err), zap.String("address", address))
+	}
+}
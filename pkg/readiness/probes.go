@@ -0,0 +1,130 @@
+package readiness
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/provider"
+	"golang.org/x/crypto/ssh"
+)
+
+const defaultPort = 22
+
+// dialTimeout bounds each connection attempt of the network probes.
+const dialTimeout = 10 * time.Second
+
+// StateProbe passes when the provider reports the instance as running.
+type StateProbe struct {
+	Getter Getter
+}
+
+func (p *StateProbe) Check(ctx context.Context, host *config.Host) error {
+	instance, err := p.Getter.Get(ctx, host.Name)
+	if err != nil {
+		return err
+	}
+	switch instance.State {
+	case provider.StateRunning:
+		return nil
+	case provider.StateError:
+		return errors.New("instance is in error")
+	}
+	return fmt.Errorf("instance is %s", instance.State)
+}
+
+func (p *StateProbe) String() string {
+	return config.ProbeState
+}
+
+// TCPProbe passes when a TCP port of the host accepts connections.
+type TCPProbe struct {
+	Port int
+}
+
+func (p *TCPProbe) Check(ctx context.Context, host *config.Host) error {
+	dialer := net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host.IP, strconv.Itoa(p.Port)))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func (p *TCPProbe) String() string {
+	return fmt.Sprintf("%s:%d", config.ProbeTCP, p.Port)
+}
+
+// SSHProbe passes when a command succeeds through SSH.
+type SSHProbe struct {
+	Port    int
+	User    string
+	Signer  ssh.Signer
+	Command string
+}
+
+// NewSSHProbe parses the base64-encoded private key.
+func NewSSHProbe(port int, user string, privateKey string, command string) (*SSHProbe, error) {
+	key, err := base64.StdEncoding.DecodeString(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return &SSHProbe{
+		Port:    port,
+		User:    user,
+		Signer:  signer,
+		Command: command,
+	}, nil
+}
+
+func (p *SSHProbe) Check(ctx context.Context, host *config.Host) error {
+	dialer := net.Dialer{Timeout: dialTimeout}
+	addr := net.JoinHostPort(host.IP, strconv.Itoa(p.Port))
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	// Stop the handshake and the command on cancellation
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+	defer stop()
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, &ssh.ClientConfig{
+		User: p.User,
+		Auth: []ssh.AuthMethod{ssh.PublicKeys(p.Signer)},
+		// The host keys of a new instance are unknown
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(), //nolint:gosec
+		Timeout:         dialTimeout,
+	})
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	client := ssh.NewClient(c, chans, reqs)
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	out, err := session.CombinedOutput(p.Command)
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, out)
+	}
+	return nil
+}
+
+func (p *SSHProbe) String() string {
+	return fmt.Sprintf("%s:%d", config.ProbeSSH, p.Port)
+}
@@ -0,0 +1,79 @@
+// Package readiness checks that a created host is usable.
+package readiness
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/squarefactory/cloud-burster/logger"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/provider"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultTimeout  = 10 * time.Minute
+	DefaultInterval = 5 * time.Second
+)
+
+// ErrTimeout is returned when the probes didn't pass in time.
+var ErrTimeout = errors.New("host not ready in time")
+
+// Getter fetches the live instance of a host.
+type Getter interface {
+	Get(ctx context.Context, name string) (*provider.Instance, error)
+}
+
+// Probe checks one aspect of the readiness of a host.
+type Probe interface {
+	// Check returns nil if the host passes the probe.
+	Check(ctx context.Context, host *config.Host) error
+	String() string
+}
+
+// Wait runs the probes in order until they all pass or the timeout expires.
+func Wait(
+	ctx context.Context,
+	host *config.Host,
+	probes []Probe,
+	timeout time.Duration,
+	interval time.Duration,
+) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for _, probe := range probes {
+		if err := poll(ctx, host, probe, interval); err != nil {
+			return err
+		}
+		logger.I.Info("probe passed", zap.String("host", host.Name), zap.Stringer("probe", probe))
+	}
+	return nil
+}
+
+func poll(ctx context.Context, host *config.Host, probe Probe, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err := probe.Check(ctx, host)
+		if err == nil {
+			return nil
+		}
+		logger.I.Debug(
+			"probe failed",
+			zap.String("host", host.Name),
+			zap.Stringer("probe", probe),
+			zap.Error(err),
+		)
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("%w: %s: %w", ErrTimeout, probe, err)
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
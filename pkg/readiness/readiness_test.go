@@ -0,0 +1,218 @@
+//go:build unit
+
+package readiness_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/squarefactory/cloud-burster/pkg/cloud/fake"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/provider"
+	"github.com/squarefactory/cloud-burster/pkg/readiness"
+	"github.com/stretchr/testify/suite"
+)
+
+type ReadinessTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ReadinessTestSuite) TestWait() {
+	// Arrange
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.Require().NoError(err)
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.Require().NoError(err)
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	host := &config.Host{Name: "cn-1", IP: "127.0.0.1"}
+
+	tests := []struct {
+		state   provider.State
+		probes  []readiness.Probe
+		isError bool
+		title   string
+	}{
+		{
+			state: provider.StateRunning,
+			probes: []readiness.Probe{
+				&readiness.TCPProbe{Port: port},
+			},
+			title: "Positive test",
+		},
+		{
+			state: provider.StatePending,
+			probes: []readiness.Probe{
+				&readiness.TCPProbe{Port: port},
+			},
+			isError: true,
+			title:   "Pending instance",
+		},
+		{
+			state: provider.StateRunning,
+			probes: []readiness.Probe{
+				&readiness.TCPProbe{Port: closedPort},
+			},
+			isError: true,
+			title:   "Closed port",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Arrange
+			ds := &fake.DataSource{
+				Instances: []provider.Instance{{Name: "cn-1", State: tt.state}},
+			}
+			probes := append([]readiness.Probe{&readiness.StateProbe{Getter: ds}}, tt.probes...)
+
+			// Act
+			err := readiness.Wait(context.Background(), host, probes, 300*time.Millisecond, 50*time.Millisecond)
+
+			// Assert
+			if tt.isError {
+				suite.ErrorIs(err, readiness.ErrTimeout)
+			} else {
+				suite.NoError(err)
+			}
+		})
+	}
+}
+
+func (suite *ReadinessTestSuite) TestPhoneHome() {
+	// Arrange
+	home := readiness.NewPhoneHome()
+	server := httptest.NewServer(home)
+	defer server.Close()
+	host := &config.Host{Name: "cn-1.example.com", IP: "127.0.0.1"}
+	foreign := &config.Host{Name: "cn-1.example.com", IP: "10.0.0.1"}
+	since := time.Now()
+	probe := &readiness.PhoneHomeProbe{PhoneHome: home, Since: since}
+
+	// Act
+	err := probe.Check(context.Background(), host)
+
+	// Assert
+	suite.Error(err)
+
+	// Act
+	form := url.Values{"hostname": {"cn-1"}, "instance_id": {"id"}}
+	resp, err := http.Post(server.URL, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	err = probe.Check(context.Background(), host)
+
+	// Assert
+	suite.Equal(http.StatusNoContent, resp.StatusCode)
+	suite.NoError(err)
+	suite.Error(probe.Check(context.Background(), foreign), "callbacks from other IPs should be ignored")
+	suite.False(home.Since(host, []string{host.IP}, time.Now().Add(time.Second)), "older callbacks should be ignored")
+}
+
+func (suite *ReadinessTestSuite) TestPhoneHomeWithoutHostIP() {
+	// Arrange
+	home := readiness.NewPhoneHome()
+	server := httptest.NewServer(home)
+	defer server.Close()
+	ds := &fake.DataSource{}
+	suite.Require().NoError(ds.Create(context.Background(), &config.Host{Name: "cn-1", IP: "127.0.0.1"}, &config.Cloud{}))
+	probe := &readiness.PhoneHomeProbe{PhoneHome: home, Since: time.Now(), Getter: ds}
+	form := url.Values{"hostname": {"cn-1"}}
+	resp, err := http.Post(server.URL, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	suite.Require().NoError(err)
+	resp.Body.Close()
+
+	// Act
+	err = probe.Check(context.Background(), &config.Host{Name: "cn-1"})
+
+	// Assert
+	suite.NoError(err)
+}
+
+func (suite *ReadinessTestSuite) TestPhoneHomeRetention() {
+	// Arrange
+	home := readiness.NewPhoneHome()
+	home.Retention = 10 * time.Millisecond
+	server := httptest.NewServer(home)
+	defer server.Close()
+	host := &config.Host{Name: "cn-1", IP: "127.0.0.1"}
+	form := url.Values{"hostname": {"cn-1"}}
+	resp, err := http.Post(server.URL, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	suite.Require().True(home.Since(host, []string{host.IP}, time.Time{}))
+
+	// Act
+	time.Sleep(20 * time.Millisecond)
+
+	// Assert
+	suite.False(home.Since(host, []string{host.IP}, time.Time{}), "expired callbacks should be dropped")
+}
+
+func (suite *ReadinessTestSuite) TestProbesAddressInUse() {
+	// Arrange
+	prober := readiness.NewProber()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.Require().NoError(err)
+	defer ln.Close()
+	conf := &config.Readiness{
+		Probes: []config.Probe{
+			{Type: config.ProbePhoneHome, ListenAddress: ln.Addr().String()},
+		},
+	}
+
+	// Act
+	_, _, err = prober.Probes(conf, &fake.DataSource{})
+
+	// Assert
+	suite.ErrorIs(err, readiness.ErrAddressInUse)
+}
+
+func (suite *ReadinessTestSuite) TestProbes() {
+	// Arrange
+	prober := readiness.NewProber()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.Require().NoError(err)
+	address := ln.Addr().String()
+	ln.Close()
+	conf := &config.Readiness{
+		Probes: []config.Probe{
+			{Type: config.ProbeState},
+			{Type: config.ProbeTCP},
+			{Type: config.ProbePhoneHome, ListenAddress: address},
+		},
+	}
+
+	// Act
+	probes, release, err := prober.Probes(conf, &fake.DataSource{})
+	suite.Require().NoError(err)
+	resp, err := http.Post(
+		"http://"+address+"?hostname=cn-1",
+		"application/x-www-form-urlencoded",
+		nil,
+	)
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	release()
+
+	// Assert
+	suite.Len(probes, 3)
+	suite.Equal("tcp:"+strconv.Itoa(22), probes[1].String())
+	suite.NoError(probes[2].Check(context.Background(), &config.Host{Name: "cn-1", IP: "127.0.0.1"}))
+	_, err = http.Post("http://"+address, "", nil)
+	suite.Error(err, "the phone-home server should be stopped")
+}
+
+func TestReadinessTestSuite(t *testing.T) {
+	suite.Run(t, &ReadinessTestSuite{})
+}
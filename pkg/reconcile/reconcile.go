@@ -0,0 +1,236 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/squarefactory/cloud-burster/logger"
+	"github.com/squarefactory/cloud-burster/pkg/cloud"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/lock"
+	"github.com/squarefactory/cloud-burster/pkg/provider"
+	"go.uber.org/zap"
+)
+
+const (
+	ReasonUnknown   = "not in configuration"
+	ReasonDuplicate = "duplicate of a newer instance"
+	ReasonDangling  = "not attached to any instance"
+)
+
+// Action is a deletion planned by the reconciler.
+//
+// Host is the configured host the resource belongs to, if any: its lock is held while the
+// action is applied.
+type Action struct {
+	Cloud    int               `json:"cloud"          yaml:"cloud"`
+	Resource provider.Resource `json:"resource"       yaml:"resource"`
+	Reason   string            `json:"reason"         yaml:"reason"`
+	Host     string            `json:"host,omitempty" yaml:"host,omitempty"`
+}
+
+func (a Action) String() string {
+	return fmt.Sprintf(
+		"cloud %d: delete %s %s (%s): %s",
+		a.Cloud,
+		a.Resource.Kind,
+		a.Resource.Name,
+		a.Resource.ID,
+		a.Reason,
+	)
+}
+
+// Plan compares the hosts of a cloud against the live instances and returns
+// the deletions needed to converge.
+//
+// Only the instances carrying the ownership marker of cloud-burster are considered, so that
+// the foreign servers of a shared account are left alone. Instances which are not in the
+// configuration must also be attached to the cloud network. The hosts of every cloud of the
+// same type are expected, as these clouds may share the account.
+func Plan(
+	ctx context.Context,
+	conf *config.Config,
+	idx int,
+	ds cloud.DataSource,
+) ([]Action, error) {
+	cl := &conf.Clouds[idx]
+	expected := make(map[string]struct{})
+	for i := range conf.Clouds {
+		if conf.Clouds[i].Type != cl.Type {
+			continue
+		}
+		hosts, err := conf.Clouds[i].GenerateHosts()
+		if err != nil {
+			return nil, err
+		}
+		for _, host := range hosts {
+			expected[host.Name] = struct{}{}
+		}
+	}
+
+	instances, err := ds.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	live := make([]provider.Instance, 0, len(instances))
+	for _, instance := range instances {
+		if instance.State == provider.StateTerminated || instance.State == provider.StateDeleting {
+			continue
+		}
+		if !instance.Managed || instance.Name == "" {
+			continue
+		}
+		live = append(live, instance)
+	}
+
+	var actions []Action
+	for _, instance := range live {
+		reason, host := "", ""
+		if _, ok := expected[instance.Name]; !ok {
+			if !onNetwork(&instance, cl) {
+				continue
+			}
+			reason = ReasonUnknown
+		} else {
+			newest, err := provider.Newest(live, instance.Name)
+			if err != nil {
+				return nil, err
+			}
+			if newest.ProviderID == instance.ProviderID {
+				continue
+			}
+			reason, host = ReasonDuplicate, instance.Name
+		}
+		actions = append(actions, Action{
+			Cloud: idx,
+			Resource: provider.Resource{
+				Kind: provider.ResourceServer,
+				ID:   instance.ProviderID,
+				Name: instance.Name,
+			},
+			Reason: reason,
+			Host:   host,
+		})
+	}
+
+	if r, ok := ds.(cloud.Reconciler); ok {
+		resources, err := r.ListDangling(ctx, cl)
+		if err != nil {
+			return nil, err
+		}
+		for _, resource := range resources {
+			actions = append(actions, Action{
+				Cloud:    idx,
+				Resource: resource,
+				Reason:   ReasonDangling,
+				Host:     resource.Name,
+			})
+		}
+	}
+
+	return actions, nil
+}
+
+// Apply executes the actions of a plan on the cloud cl.
+//
+// The lock of the host of an action is acquired with locker, waiting for timeout like the
+// other operations. Dangling resources are then listed again, and only deleted if they are
+// still dangling: an operation on the host may have attached them in the meantime.
+//
+// Apply continues on failure and returns every error encountered.
+func Apply(
+	ctx context.Context,
+	cl *config.Cloud,
+	ds cloud.DataSource,
+	actions []Action,
+	locker lock.Locker,
+	timeout time.Duration,
+) error {
+	var errs []error
+	for _, action := range actions {
+		if err := apply(ctx, cl, ds, action, locker, timeout); err != nil {
+			logger.I.Error(
+				"couldn't reconcile",
+				zap.Error(err),
+				zap.Stringer("action", action),
+			)
+			errs = append(errs, fmt.Errorf("%s: %w", action, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func apply(
+	ctx context.Context,
+	cl *config.Cloud,
+	ds cloud.DataSource,
+	action Action,
+	locker lock.Locker,
+	timeout time.Duration,
+) error {
+	if action.Host != "" {
+		r, err := lock.Acquire(ctx, locker, action.Host, timeout)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := r.Release(); err != nil {
+				logger.I.Error("couldn't release the lock", zap.Error(err), zap.String("host", action.Host))
+			}
+		}()
+	}
+	if action.Reason == ReasonDangling {
+		dangling, err := stillDangling(ctx, cl, ds, action.Resource)
+		if err != nil {
+			return err
+		}
+		if !dangling {
+			logger.I.Info("the resource is no longer dangling", zap.Stringer("action", action))
+			return nil
+		}
+	}
+	logger.I.Warn("reconciling", zap.Stringer("action", action))
+	return deleteResource(ctx, ds, action.Resource)
+}
+
+func stillDangling(
+	ctx context.Context,
+	cl *config.Cloud,
+	ds cloud.DataSource,
+	resource provider.Resource,
+) (bool, error) {
+	r, ok := ds.(cloud.Reconciler)
+	if !ok {
+		return false, nil
+	}
+	resources, err := r.ListDangling(ctx, cl)
+	if err != nil {
+		return false, err
+	}
+	for _, res := range resources {
+		if res.Kind == resource.Kind && res.ID == resource.ID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func deleteResource(ctx context.Context, ds cloud.DataSource, resource provider.Resource) error {
+	if r, ok := ds.(cloud.Reconciler); ok {
+		return r.DeleteResource(ctx, resource)
+	}
+	if resource.Kind == provider.ResourceServer {
+		return ds.Delete(ctx, resource.Name)
+	}
+	return fmt.Errorf("cannot delete %s without reconciliation support", resource.Kind)
+}
+
+func onNetwork(instance *provider.Instance, cl *config.Cloud) bool {
+	if cl.Network == nil {
+		return true
+	}
+	return slices.Contains(instance.Networks, cl.Network.Name)
+}
@@ -0,0 +1,254 @@
+//go:build unit
+
+package reconcile_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/squarefactory/cloud-burster/pkg/cloud/fake"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/lock"
+	"github.com/squarefactory/cloud-burster/pkg/provider"
+	"github.com/squarefactory/cloud-burster/pkg/reconcile"
+	"github.com/squarefactory/cloud-burster/pkg/shadow"
+	"github.com/stretchr/testify/suite"
+)
+
+var (
+	older = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer = time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+)
+
+var conf = config.Config{
+	Clouds: []config.Cloud{
+		{
+			Type: "openstack",
+			Hosts: []config.Host{
+				{Name: "other"},
+			},
+		},
+		{
+			Type: "openstack",
+			Network: &config.Network{
+				Name: "net",
+			},
+			Hosts: []config.Host{
+				{Name: "cn1"},
+				{Name: "cn2"},
+			},
+		},
+		{
+			Type: "shadow",
+			Hosts: []config.Host{
+				{Name: "cn3"},
+			},
+		},
+	},
+}
+
+// shadowAPI answers the list requests of the Shadow API.
+type shadowAPI map[string]string
+
+func (api shadowAPI) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, ok := api[req.URL.Path]
+	if !ok {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}, nil
+}
+
+type ReconcileTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ReconcileTestSuite) TestPlan() {
+	// Arrange
+	ds := &fake.DataSource{
+		Instances: []provider.Instance{
+			{Name: "cn1", ProviderID: "old", State: provider.StateRunning, CreatedAt: &older, Managed: true},
+			{Name: "cn1", ProviderID: "new", State: provider.StateRunning, CreatedAt: &newer, Managed: true},
+			{Name: "cn1", ProviderID: "foreign-newest", State: provider.StateRunning, CreatedAt: &newer},
+			{Name: "cn2", ProviderID: "cn2", State: provider.StateRunning, Managed: true},
+			{Name: "cn3", ProviderID: "orphan", State: provider.StateRunning, Networks: []string{"net"}, Managed: true},
+			{Name: "other", ProviderID: "other", State: provider.StateRunning, Networks: []string{"net"}, Managed: true},
+			{Name: "unmanaged", ProviderID: "unmanaged", State: provider.StateRunning, Networks: []string{"net"}},
+			{ProviderID: "unnamed", State: provider.StateRunning, Networks: []string{"net"}, Managed: true},
+			{Name: "foreign", ProviderID: "foreign", State: provider.StateRunning, Networks: []string{"other"}, Managed: true},
+			{Name: "cn4", ProviderID: "gone", State: provider.StateTerminated, Networks: []string{"net"}, Managed: true},
+		},
+		Dangling: []provider.Resource{
+			{Kind: provider.ResourcePort, ID: "port", Name: "cn2"},
+		},
+	}
+	expected := []reconcile.Action{
+		{
+			Cloud:    1,
+			Resource: provider.Resource{Kind: provider.ResourceServer, ID: "old", Name: "cn1"},
+			Reason:   reconcile.ReasonDuplicate,
+			Host:     "cn1",
+		},
+		{
+			Cloud:    1,
+			Resource: provider.Resource{Kind: provider.ResourceServer, ID: "orphan", Name: "cn3"},
+			Reason:   reconcile.ReasonUnknown,
+		},
+		{
+			Cloud:    1,
+			Resource: provider.Resource{Kind: provider.ResourcePort, ID: "port", Name: "cn2"},
+			Reason:   reconcile.ReasonDangling,
+			Host:     "cn2",
+		},
+	}
+
+	// Act
+	actual, err := reconcile.Plan(context.Background(), &conf, 1, ds)
+
+	// Assert
+	suite.NoError(err)
+	suite.Equal(expected, actual)
+}
+
+func (suite *ReconcileTestSuite) TestPlanWithoutNetwork() {
+	// Arrange
+	conf := config.Config{
+		Clouds: []config.Cloud{
+			{
+				Type: "shadow",
+				Hosts: []config.Host{
+					{Name: "cn1"},
+				},
+			},
+		},
+	}
+	ds := &fake.DataSource{
+		Instances: []provider.Instance{
+			{Name: "cn1", ProviderID: "cn1", State: provider.StateRunning, Managed: true},
+			{Name: "cn2", ProviderID: "orphan", State: provider.StateRunning, Managed: true},
+			{Name: "desktop", ProviderID: "desktop", State: provider.StateRunning},
+		},
+	}
+	expected := []reconcile.Action{
+		{
+			Resource: provider.Resource{Kind: provider.ResourceServer, ID: "orphan", Name: "cn2"},
+			Reason:   reconcile.ReasonUnknown,
+		},
+	}
+
+	// Act
+	actual, err := reconcile.Plan(context.Background(), &conf, 0, ds)
+
+	// Assert
+	suite.NoError(err)
+	suite.Equal(expected, actual)
+}
+
+func (suite *ReconcileTestSuite) TestPlanShadow() {
+	// Arrange
+	ds := shadow.New(&shadow.Options{}, nil)
+	ds.Transport = shadowAPI{
+		"/api/vm/list": `{"vms": [
+			{"uuid": "cn3", "image": "https://images.example.com/rocky.qcow2/cn3/", "status": 2, "status_str": "running", "block_devices": [{"uuid": "bd-cn3"}]},
+			{"uuid": "orphan", "image": "https://images.example.com/rocky.qcow2/cn9/", "status": 2, "status_str": "running", "block_devices": [{"uuid": "bd-orphan"}]},
+			{"uuid": "desktop", "image": "https://images.example.com/desktop.qcow2", "status": 2, "status_str": "running", "block_devices": [{"uuid": "bd-desktop"}]},
+			{"uuid": "failed", "image": "https://images.example.com/rocky.qcow2/cn3/", "status": 3, "status_str": "terminated", "block_devices": [{"uuid": "bd-failed"}]}
+		]}`,
+		"/api/block_device/list": `{"block_devices": [
+			{"uuid": "bd-cn3", "status": 2},
+			{"uuid": "bd-orphan", "status": 2},
+			{"uuid": "bd-desktop", "status": 2},
+			{"uuid": "bd-failed", "status": 2}
+		]}`,
+	}
+	expected := []reconcile.Action{
+		{
+			Cloud:    2,
+			Resource: provider.Resource{Kind: provider.ResourceServer, ID: "orphan", Name: "cn9"},
+			Reason:   reconcile.ReasonUnknown,
+		},
+		{
+			Cloud:    2,
+			Resource: provider.Resource{Kind: provider.ResourceBlockDevice, ID: "bd-failed", Name: "cn3"},
+			Reason:   reconcile.ReasonDangling,
+			Host:     "cn3",
+		},
+	}
+
+	// Act
+	actual, err := reconcile.Plan(context.Background(), &conf, 2, ds)
+
+	// Assert
+	suite.NoError(err)
+	suite.Equal(expected, actual)
+}
+
+func (suite *ReconcileTestSuite) TestApply() {
+	// Arrange
+	ds := &fake.DataSource{
+		Dangling: []provider.Resource{
+			{Kind: provider.ResourceBlockDevice, ID: "bd", Name: "cn2"},
+		},
+	}
+	actions := []reconcile.Action{
+		{
+			Resource: provider.Resource{Kind: provider.ResourceServer, ID: "orphan", Name: "cn3"},
+			Reason:   reconcile.ReasonUnknown,
+		},
+		{
+			Resource: provider.Resource{Kind: provider.ResourceBlockDevice, ID: "bd", Name: "cn2"},
+			Reason:   reconcile.ReasonDangling,
+			Host:     "cn2",
+		},
+		{
+			Resource: provider.Resource{Kind: provider.ResourcePort, ID: "attached", Name: "cn1"},
+			Reason:   reconcile.ReasonDangling,
+			Host:     "cn1",
+		},
+	}
+
+	// Act
+	err := reconcile.Apply(context.Background(), &conf.Clouds[1], ds, actions, lock.NewMemoryLocker(), 0)
+
+	// Assert
+	suite.NoError(err)
+	suite.Equal([]provider.Resource{actions[0].Resource, actions[1].Resource}, ds.Deleted)
+}
+
+func (suite *ReconcileTestSuite) TestApplyLocked() {
+	// Arrange
+	ds := &fake.DataSource{
+		Dangling: []provider.Resource{
+			{Kind: provider.ResourcePort, ID: "port", Name: "cn1"},
+		},
+	}
+	actions := []reconcile.Action{
+		{
+			Resource: provider.Resource{Kind: provider.ResourcePort, ID: "port", Name: "cn1"},
+			Reason:   reconcile.ReasonDangling,
+			Host:     "cn1",
+		},
+	}
+	locker := lock.NewMemoryLocker()
+	r, err := locker.TryLock("cn1")
+	suite.Require().NoError(err)
+	defer r.Release()
+
+	// Act
+	err = reconcile.Apply(context.Background(), &conf.Clouds[1], ds, actions, locker, 0)
+
+	// Assert
+	suite.ErrorIs(err, lock.ErrLocked)
+	suite.Empty(ds.Deleted)
+}
+
+func TestReconcileTestSuite(t *testing.T) {
+	suite.Run(t, &ReconcileTestSuite{})
+}
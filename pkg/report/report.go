@@ -0,0 +1,148 @@
+// Package report collects the result of an operation on each host.
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/squarefactory/cloud-burster/utils/pool"
+)
+
+// Exit codes of a report.
+const (
+	ExitSuccess        = 0
+	ExitFailure        = 1
+	ExitPartialFailure = 2
+)
+
+// Status of the operation on a host.
+type Status string
+
+const (
+	StatusSuccess Status = "success"
+	StatusFailure Status = "failure"
+)
+
+// Result is the outcome of the operation on a host.
+type Result struct {
+	Hostname   string        `json:"hostname"`
+	Cloud      string        `json:"cloud,omitempty"`
+	Action     string        `json:"action"`
+	Status     Status        `json:"status"`
+	Duration   time.Duration `json:"-"`
+	Error      string        `json:"error,omitempty"`
+	ProviderID string        `json:"providerId,omitempty"`
+}
+
+// MarshalJSON renders the duration in seconds.
+func (r Result) MarshalJSON() ([]byte, error) {
+	type result Result
+	return json.Marshal(struct {
+		result
+		Duration float64 `json:"durationSeconds"`
+	}{
+		result:   result(r),
+		Duration: r.Duration.Seconds(),
+	})
+}
+
+// Report holds the results, in the order of the hosts.
+type Report []Result
+
+// Collect runs fn on every host, with at most parallelism hosts at the same time.
+//
+// fn may fill the cloud and the provider ID of the result. The other fields are set by Collect.
+func Collect(
+	ctx context.Context,
+	action string,
+	hostnames []string,
+	parallelism int,
+	fn func(ctx context.Context, hostname string, result *Result) error,
+) Report {
+	r := make(Report, len(hostnames))
+	indexes := make([]int, len(hostnames))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	errs := pool.Each(ctx, parallelism, indexes, func(ctx context.Context, i int) error {
+		start := time.Now()
+		err := fn(ctx, hostnames[i], &r[i])
+		r[i].Duration = time.Since(start)
+		return err
+	})
+	for i, err := range errs {
+		r[i].Hostname = hostnames[i]
+		r[i].Action = action
+		r[i].Status = StatusSuccess
+		if err != nil {
+			r[i].Status = StatusFailure
+			r[i].Error = err.Error()
+		}
+	}
+	return r
+}
+
+// Failed returns the number of failed hosts.
+func (r Report) Failed() int {
+	failed := 0
+	for _, result := range r {
+		if result.Status == StatusFailure {
+			failed++
+		}
+	}
+	return failed
+}
+
+// ExitCode returns ExitSuccess if every host succeeded, ExitFailure if every host
+// failed, and ExitPartialFailure otherwise.
+func (r Report) ExitCode() int {
+	switch failed := r.Failed(); {
+	case failed == 0:
+		return ExitSuccess
+	case failed == len(r):
+		return ExitFailure
+	default:
+		return ExitPartialFailure
+	}
+}
+
+// ValidateFormat returns an error if format is neither table nor json.
+func ValidateFormat(format string) error {
+	switch format {
+	case "table", "json":
+		return nil
+	}
+	return fmt.Errorf("unknown output format: %s", format)
+}
+
+// Render writes the report as a table, or as JSON.
+func (r Report) Render(w io.Writer, format string) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "HOSTNAME\tCLOUD\tACTION\tSTATUS\tDURATION\tPROVIDER ID\tERROR")
+	for _, result := range r {
+		fmt.Fprintf(
+			tw,
+			"%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			result.Hostname,
+			result.Cloud,
+			result.Action,
+			result.Status,
+			result.Duration.Round(time.Millisecond),
+			result.ProviderID,
+			strings.ReplaceAll(result.Error, "\n", "; "),
+		)
+	}
+	fmt.Fprintf(tw, "\n%d succeeded, %d failed\n", len(r)-r.Failed(), r.Failed())
+	return tw.Flush()
+}
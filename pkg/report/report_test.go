@@ -0,0 +1,148 @@
+//go:build unit
+
+package report_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/squarefactory/cloud-burster/pkg/report"
+	"github.com/stretchr/testify/suite"
+)
+
+type ReportTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ReportTestSuite) TestCollect() {
+	// Arrange
+	hostnames := []string{"cn-1", "cn-2", "cn-3"}
+
+	// Act
+	r := report.Collect(
+		context.Background(),
+		"create",
+		hostnames,
+		2,
+		func(ctx context.Context, hostname string, result *report.Result) error {
+			result.Cloud = "0/openstack"
+			if hostname == "cn-2" {
+				return errors.New("boom")
+			}
+			result.ProviderID = "id-" + hostname
+			return nil
+		},
+	)
+
+	// Assert
+	suite.Len(r, 3)
+	for i, result := range r {
+		suite.Equal(hostnames[i], result.Hostname)
+		suite.Equal("create", result.Action)
+		suite.Equal("0/openstack", result.Cloud)
+	}
+	suite.Equal(report.StatusSuccess, r[0].Status)
+	suite.Equal("id-cn-1", r[0].ProviderID)
+	suite.Equal(report.StatusFailure, r[1].Status)
+	suite.Equal("boom", r[1].Error)
+	suite.Equal(1, r.Failed())
+}
+
+func (suite *ReportTestSuite) TestExitCode() {
+	tests := []struct {
+		input    report.Report
+		expected int
+		title    string
+	}{
+		{
+			input: report.Report{
+				{Status: report.StatusSuccess},
+				{Status: report.StatusSuccess},
+			},
+			expected: report.ExitSuccess,
+			title:    "Success",
+		},
+		{
+			input: report.Report{
+				{Status: report.StatusSuccess},
+				{Status: report.StatusFailure},
+			},
+			expected: report.ExitPartialFailure,
+			title:    "Partial failure",
+		},
+		{
+			input: report.Report{
+				{Status: report.StatusFailure},
+			},
+			expected: report.ExitFailure,
+			title:    "Failure",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Act
+			actual := tt.input.ExitCode()
+
+			// Assert
+			suite.Equal(tt.expected, actual)
+		})
+	}
+}
+
+func (suite *ReportTestSuite) TestRenderJSON() {
+	// Arrange
+	r := report.Report{
+		{
+			Hostname:   "cn-1",
+			Cloud:      "1/exoscale",
+			Action:     "delete",
+			Status:     report.StatusSuccess,
+			Duration:   1500 * time.Millisecond,
+			ProviderID: "uuid",
+		},
+	}
+	var buf bytes.Buffer
+
+	// Act
+	err := r.Render(&buf, "json")
+
+	// Assert
+	suite.NoError(err)
+	var actual []map[string]interface{}
+	suite.NoError(json.Unmarshal(buf.Bytes(), &actual))
+	suite.Equal([]map[string]interface{}{
+		{
+			"hostname":        "cn-1",
+			"cloud":           "1/exoscale",
+			"action":          "delete",
+			"status":          "success",
+			"durationSeconds": 1.5,
+			"providerId":      "uuid",
+		},
+	}, actual)
+}
+
+func (suite *ReportTestSuite) TestRenderTable() {
+	// Arrange
+	r := report.Report{
+		{Hostname: "cn-1", Action: "create", Status: report.StatusFailure, Error: "a\nb"},
+	}
+	var buf bytes.Buffer
+
+	// Act
+	err := r.Render(&buf, "table")
+
+	// Assert
+	suite.NoError(err)
+	suite.Contains(buf.String(), "a; b")
+	suite.Contains(buf.String(), "0 succeeded, 1 failed")
+}
+
+func TestReportTestSuite(t *testing.T) {
+	suite.Run(t, &ReportTestSuite{})
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// retention is how long finished operations are kept.
+const retention = time.Hour
+
+// Status is the status of an operation.
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+// Done returns true if the status is final.
+func (s Status) Done() bool {
+	return s == StatusSucceeded || s == StatusFailed
+}
+
+// Operation is an asynchronous action on a host.
+type Operation struct {
+	ID        string    `json:"id"`
+	Action    string    `json:"action"`
+	Hostname  string    `json:"hostname"`
+	Status    Status    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// operations stores the operations in memory and broadcasts their updates.
+type operations struct {
+	mu          sync.Mutex
+	ops         map[string]*Operation
+	subscribers map[string][]chan Operation
+}
+
+func newOperations() *operations {
+	return &operations{
+		ops:         make(map[string]*Operation),
+		subscribers: make(map[string][]chan Operation),
+	}
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func (o *operations) add(action string, hostname string) Operation {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := time.Now()
+	for id, op := range o.ops {
+		if op.Status.Done() && now.Sub(op.UpdatedAt) > retention {
+			delete(o.ops, id)
+		}
+	}
+
+	op := &Operation{
+		ID:        newID(),
+		Action:    action,
+		Hostname:  hostname,
+		Status:    StatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	o.ops[op.ID] = op
+	return *op
+}
+
+func (o *operations) get(id string) (Operation, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	op, ok := o.ops[id]
+	if !ok {
+		return Operation{}, false
+	}
+	return *op, true
+}
+
+func (o *operations) update(id string, status Status, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	op, ok := o.ops[id]
+	if !ok {
+		return
+	}
+	op.Status = status
+	op.UpdatedAt = time.Now()
+	if err != nil {
+		op.Error = err.Error()
+	}
+
+	for _, sub := range o.subscribers[id] {
+		select {
+		case sub <- *op:
+		default:
+		}
+		if status.Done() {
+			close(sub)
+		}
+	}
+	if status.Done() {
+		delete(o.subscribers, id)
+	}
+}
+
+// subscribe returns the current state of an operation and a channel receiving
+// its updates. The channel is closed once the operation is done.
+func (o *operations) subscribe(id string) (Operation, <-chan Operation, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	op, ok := o.ops[id]
+	if !ok {
+		return Operation{}, nil, false
+	}
+	sub := make(chan Operation, 8)
+	if op.Status.Done() {
+		close(sub)
+	} else {
+		o.subscribers[id] = append(o.subscribers[id], sub)
+	}
+	return *op, sub, true
+}
+
+// unsubscribe stops the updates of an operation for a channel returned by subscribe.
+func (o *operations) unsubscribe(id string, sub <-chan Operation) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	subs := o.subscribers[id]
+	for i, s := range subs {
+		if s == sub {
+			o.subscribers[id] = append(subs[:i], subs[i+1:]...)
+			close(s)
+			return
+		}
+	}
+}
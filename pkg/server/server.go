@@ -0,0 +1,311 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/squarefactory/cloud-burster/logger"
+	"github.com/squarefactory/cloud-burster/pkg/burster"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/provider"
+	"github.com/squarefactory/cloud-burster/utils/generators"
+	"go.uber.org/zap"
+)
+
+const (
+	ActionCreate = "create"
+	ActionDelete = "delete"
+)
+
+// BatchRequest is the body of POST /v1/batch.
+type BatchRequest struct {
+	Action string `json:"action"`
+	// Hostnames is a hostlist, like "cn-s-[1-5],cn-t-1".
+	Hostnames string `json:"hostnames"`
+}
+
+// Server exposes the burster through a REST API.
+//
+//	GET    /v1/hosts?hostnames={hostlist}  returns the configured hosts and their live instances
+//	POST   /v1/hosts/{hostname}            create the host, returns an operation
+//	DELETE /v1/hosts/{hostname}            delete the host, returns an operation
+//	GET    /v1/hosts/{hostname}            returns the live instance
+//	POST   /v1/batch                       create or delete a hostlist, returns operations
+//	GET    /v1/operations/{id}             returns an operation
+//	GET    /v1/operations/{id}/events      streams the operation updates (Server-Sent Events)
+type Server struct {
+	ctx     context.Context
+	burster *burster.Burster
+	token   string
+	ops     *operations
+	mux     *http.ServeMux
+	wg      sync.WaitGroup
+	// slots bounds the running operations, nil for no limit.
+	slots chan struct{}
+}
+
+// New creates a server. Operations run with ctx, independently of the requests.
+//
+// If token is not empty, requests must carry it as a bearer token. At most parallelism
+// operations run at the same time, across requests: the other ones stay pending.
+// A non-positive parallelism runs every operation at once.
+func New(ctx context.Context, b *burster.Burster, token string, parallelism int) *Server {
+	s := &Server{
+		ctx:     ctx,
+		burster: b,
+		token:   token,
+		ops:     newOperations(),
+		mux:     http.NewServeMux(),
+	}
+	if parallelism > 0 {
+		s.slots = make(chan struct{}, parallelism)
+	}
+	s.mux.HandleFunc("/v1/hosts", s.handleHosts)
+	s.mux.HandleFunc("/v1/hosts/", s.handleHost)
+	s.mux.HandleFunc("/v1/batch", s.handleBatch)
+	s.mux.HandleFunc("/v1/operations/", s.handleOperation)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.token != "" && subtle.ConstantTimeCompare(
+		[]byte(r.Header.Get("Authorization")),
+		[]byte("Bearer "+s.token),
+	) != 1 {
+		writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+		return
+	}
+	logger.I.Debug("request", zap.String("method", r.Method), zap.String("path", r.URL.Path))
+	s.mux.ServeHTTP(w, r)
+}
+
+// Wait blocks until every running operation is done.
+func (s *Server) Wait() {
+	s.wg.Wait()
+}
+
+// handleHosts lists the hosts. The hostnames query parameter filters them with a hostlist.
+//
+// The hosts of a cloud that cannot be listed are returned in the unknown state.
+func (s *Server) handleHosts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	hostnames, err := generators.Expand(r.URL.Query().Get("hostnames"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	rows, err := s.burster.List(r.Context(), hostnames)
+	if rows == nil && err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	if rows == nil {
+		rows = []burster.Row{}
+	}
+	writeJSON(w, http.StatusOK, rows)
+}
+
+func (s *Server) handleHost(w http.ResponseWriter, r *http.Request) {
+	hostname := strings.TrimPrefix(r.URL.Path, "/v1/hosts/")
+	if hostname == "" || strings.Contains(hostname, "/") {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		instance, err := s.burster.Get(r.Context(), hostname)
+		if errors.Is(err, burster.ErrHostNotFound) || errors.Is(err, provider.ErrNotFound) {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		if errors.Is(err, config.ErrAmbiguousHost) {
+			writeError(w, http.StatusConflict, err)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, instance)
+	case http.MethodPost:
+		s.submit(w, ActionCreate, []string{hostname}, false)
+	case http.MethodDelete:
+		s.submit(w, ActionDelete, []string{hostname}, false)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+func (s *Server) handleBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	var req BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Action != ActionCreate && req.Action != ActionDelete {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unknown action: %s", req.Action))
+		return
+	}
+	hostnames, err := generators.Expand(req.Hostnames)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(hostnames) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("no hostnames"))
+		return
+	}
+	s.submit(w, req.Action, hostnames, true)
+}
+
+// submit starts one operation per hostname.
+//
+// Every hostname is resolved first, so that nothing starts if one is unknown.
+func (s *Server) submit(w http.ResponseWriter, action string, hostnames []string, batch bool) {
+	for _, hostname := range hostnames {
+		if _, _, err := s.burster.Resolve(hostname); err != nil {
+			status := http.StatusNotFound
+			if errors.Is(err, config.ErrAmbiguousHost) {
+				status = http.StatusConflict
+			}
+			writeError(w, status, err)
+			return
+		}
+	}
+
+	ops := make([]Operation, 0, len(hostnames))
+	for _, hostname := range hostnames {
+		op := s.ops.add(action, hostname)
+		ops = append(ops, op)
+
+		s.wg.Add(1)
+		go func(op Operation) {
+			defer s.wg.Done()
+			if err := s.run(op); err != nil {
+				s.ops.update(op.ID, StatusFailed, err)
+				return
+			}
+			s.ops.update(op.ID, StatusSucceeded, nil)
+		}(op)
+	}
+
+	if batch {
+		writeJSON(w, http.StatusAccepted, ops)
+	} else {
+		writeJSON(w, http.StatusAccepted, ops[0])
+	}
+}
+
+// run waits for a free slot, then runs the operation.
+func (s *Server) run(op Operation) error {
+	if s.slots != nil {
+		select {
+		case s.slots <- struct{}{}:
+			defer func() { <-s.slots }()
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		}
+	}
+	s.ops.update(op.ID, StatusRunning, nil)
+	switch op.Action {
+	case ActionCreate:
+		return s.burster.Create(s.ctx, op.Hostname)
+	case ActionDelete:
+		return s.burster.Delete(s.ctx, op.Hostname)
+	}
+	return fmt.Errorf("unknown action: %s", op.Action)
+}
+
+func (s *Server) handleOperation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	id, events, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/operations/"), "/")
+	switch events {
+	case "":
+		op, ok := s.ops.get(id)
+		if !ok {
+			writeError(w, http.StatusNotFound, errors.New("operation not found"))
+			return
+		}
+		writeJSON(w, http.StatusOK, op)
+	case "events":
+		s.streamOperation(w, r, id)
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+// streamOperation sends the operation updates as Server-Sent Events until it is done.
+func (s *Server) streamOperation(w http.ResponseWriter, r *http.Request, id string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming unsupported"))
+		return
+	}
+	op, sub, ok := s.ops.subscribe(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("operation not found"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(op Operation) {
+		data, _ := json.Marshal(op)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", op.Status, data)
+		flusher.Flush()
+	}
+	send(op)
+	last := op
+
+	for {
+		select {
+		case op, ok := <-sub:
+			if !ok {
+				// The last update may have been dropped, send the final state
+				if final, found := s.ops.get(id); found && final != last {
+					send(final)
+				}
+				return
+			}
+			send(op)
+			last = op
+		case <-r.Context().Done():
+			s.ops.unsubscribe(id, sub)
+			return
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.I.Error("couldn't encode response", zap.Error(err))
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, struct {
+		Error string `json:"error"`
+	}{Error: err.Error()})
+}
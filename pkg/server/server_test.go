@@ -0,0 +1,269 @@
+//go:build unit
+
+package server_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/squarefactory/cloud-burster/pkg/burster"
+	"github.com/squarefactory/cloud-burster/pkg/cloud"
+	"github.com/squarefactory/cloud-burster/pkg/cloud/fake"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/provider"
+	"github.com/squarefactory/cloud-burster/pkg/server"
+	"github.com/stretchr/testify/suite"
+)
+
+const token = "secret"
+
+var conf = config.Config{
+	Clouds: []config.Cloud{
+		{
+			Type: "openstack",
+			GroupsHost: []config.GroupHost{
+				{
+					NamePattern: "cn-[1-3]",
+					IPCidr:      "10.0.1.0/24",
+				},
+			},
+		},
+	},
+}
+
+// parallelism is the number of operations run at the same time by the server.
+const parallelism = 2
+
+// slowDataSource records the number of creations running at the same time.
+type slowDataSource struct {
+	*fake.DataSource
+	running    atomic.Int32
+	maxRunning atomic.Int32
+}
+
+func (s *slowDataSource) Create(ctx context.Context, host *config.Host, cloud *config.Cloud) error {
+	running := s.running.Add(1)
+	defer s.running.Add(-1)
+	for {
+		highest := s.maxRunning.Load()
+		if running <= highest || s.maxRunning.CompareAndSwap(highest, running) {
+			break
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	return s.DataSource.Create(ctx, host, cloud)
+}
+
+type ServerTestSuite struct {
+	suite.Suite
+	ds  *slowDataSource
+	srv *server.Server
+	ts  *httptest.Server
+}
+
+func (suite *ServerTestSuite) BeforeTest(suiteName, testName string) {
+	suite.ds = &slowDataSource{
+		DataSource: &fake.DataSource{
+			CreateErr: map[string]error{"cn-3": errors.New("boom")},
+		},
+	}
+	b, err := burster.New(&conf, func(*config.Cloud) (cloud.DataSource, error) {
+		return suite.ds, nil
+	})
+	suite.Require().NoError(err)
+	suite.srv = server.New(context.Background(), b, token, parallelism)
+	suite.ts = httptest.NewServer(suite.srv)
+}
+
+func (suite *ServerTestSuite) AfterTest(suiteName, testName string) {
+	suite.ts.Close()
+}
+
+func (suite *ServerTestSuite) do(method string, path string, body string) *http.Response {
+	req, err := http.NewRequest(method, suite.ts.URL+path, strings.NewReader(body))
+	suite.Require().NoError(err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	suite.Require().NoError(err)
+	return resp
+}
+
+func (suite *ServerTestSuite) waitOperation(id string) server.Operation {
+	var op server.Operation
+	suite.Eventually(func() bool {
+		resp := suite.do(http.MethodGet, "/v1/operations/"+id, "")
+		defer resp.Body.Close()
+		suite.NoError(json.NewDecoder(resp.Body).Decode(&op))
+		return op.Status.Done()
+	}, 5*time.Second, 10*time.Millisecond)
+	return op
+}
+
+func (suite *ServerTestSuite) TestUnauthorized() {
+	for _, authorization := range []string{"", "Bearer wrong", "Bearer " + token + "x"} {
+		suite.Run(authorization, func() {
+			// Arrange
+			req, err := http.NewRequest(http.MethodGet, suite.ts.URL+"/v1/hosts/cn-1", nil)
+			suite.Require().NoError(err)
+			req.Header.Set("Authorization", authorization)
+
+			// Act
+			resp, err := http.DefaultClient.Do(req)
+
+			// Assert
+			suite.NoError(err)
+			defer resp.Body.Close()
+			suite.Equal(http.StatusUnauthorized, resp.StatusCode)
+		})
+	}
+}
+
+func (suite *ServerTestSuite) TestCreateGetDelete() {
+	// Act
+	resp := suite.do(http.MethodPost, "/v1/hosts/cn-1", "")
+	defer resp.Body.Close()
+
+	// Assert
+	suite.Equal(http.StatusAccepted, resp.StatusCode)
+	var op server.Operation
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&op))
+	suite.Equal(server.ActionCreate, op.Action)
+	suite.Equal(server.StatusSucceeded, suite.waitOperation(op.ID).Status)
+
+	// Act
+	resp = suite.do(http.MethodGet, "/v1/hosts/cn-1", "")
+	defer resp.Body.Close()
+
+	// Assert
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	var instance provider.Instance
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&instance))
+	suite.Equal("cn-1", instance.Name)
+
+	// Act
+	resp = suite.do(http.MethodDelete, "/v1/hosts/cn-1", "")
+	defer resp.Body.Close()
+
+	// Assert
+	suite.Equal(http.StatusAccepted, resp.StatusCode)
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&op))
+	suite.Equal(server.StatusSucceeded, suite.waitOperation(op.ID).Status)
+	resp = suite.do(http.MethodGet, "/v1/hosts/cn-1", "")
+	defer resp.Body.Close()
+	suite.Equal(http.StatusNotFound, resp.StatusCode)
+}
+
+func (suite *ServerTestSuite) TestListHosts() {
+	// Arrange
+	resp := suite.do(http.MethodPost, "/v1/hosts/cn-1", "")
+	defer resp.Body.Close()
+	var op server.Operation
+	suite.Require().NoError(json.NewDecoder(resp.Body).Decode(&op))
+	suite.waitOperation(op.ID)
+
+	tests := []struct {
+		query    string
+		expected map[string]provider.State
+		title    string
+	}{
+		{
+			expected: map[string]provider.State{
+				"cn-1": provider.StateRunning,
+				"cn-2": provider.StateAbsent,
+				"cn-3": provider.StateAbsent,
+			},
+			title: "Every host",
+		},
+		{
+			query: "?hostnames=cn-[1-2]",
+			expected: map[string]provider.State{
+				"cn-1": provider.StateRunning,
+				"cn-2": provider.StateAbsent,
+			},
+			title: "Filtered by a hostlist",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Act
+			resp := suite.do(http.MethodGet, "/v1/hosts"+tt.query, "")
+			defer resp.Body.Close()
+
+			// Assert
+			suite.Equal(http.StatusOK, resp.StatusCode)
+			var rows []burster.Row
+			suite.NoError(json.NewDecoder(resp.Body).Decode(&rows))
+			states := make(map[string]provider.State, len(rows))
+			for _, row := range rows {
+				states[row.Hostname] = row.State
+			}
+			suite.Equal(tt.expected, states)
+		})
+	}
+}
+
+func (suite *ServerTestSuite) TestUnknownHost() {
+	// Act
+	resp := suite.do(http.MethodPost, "/v1/hosts/aaa", "")
+	defer resp.Body.Close()
+
+	// Assert
+	suite.Equal(http.StatusNotFound, resp.StatusCode)
+}
+
+func (suite *ServerTestSuite) TestBatch() {
+	// Act
+	resp := suite.do(http.MethodPost, "/v1/batch", `{"action":"create","hostnames":"cn-[1-3]"}`)
+	defer resp.Body.Close()
+
+	// Assert
+	suite.Equal(http.StatusAccepted, resp.StatusCode)
+	var ops []server.Operation
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&ops))
+	suite.Len(ops, 3)
+	statuses := make(map[string]server.Operation)
+	for _, op := range ops {
+		statuses[op.Hostname] = suite.waitOperation(op.ID)
+	}
+	suite.Equal(server.StatusSucceeded, statuses["cn-1"].Status)
+	suite.Equal(server.StatusSucceeded, statuses["cn-2"].Status)
+	suite.Equal(server.StatusFailed, statuses["cn-3"].Status)
+	suite.Equal("boom", statuses["cn-3"].Error)
+	suite.Equal(int32(parallelism), suite.ds.maxRunning.Load())
+}
+
+func (suite *ServerTestSuite) TestEvents() {
+	// Arrange
+	resp := suite.do(http.MethodPost, "/v1/hosts/cn-3", "")
+	defer resp.Body.Close()
+	var op server.Operation
+	suite.NoError(json.NewDecoder(resp.Body).Decode(&op))
+
+	// Act
+	resp = suite.do(http.MethodGet, "/v1/operations/"+op.ID+"/events", "")
+	defer resp.Body.Close()
+
+	// Assert
+	suite.Equal("text/event-stream", resp.Header.Get("Content-Type"))
+	var last server.Operation
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			suite.NoError(json.Unmarshal([]byte(data), &last))
+		}
+	}
+	suite.Equal(server.StatusFailed, last.Status)
+}
+
+func TestServerTestSuite(t *testing.T) {
+	suite.Run(t, &ServerTestSuite{})
+}
@@ -17,14 +17,19 @@ import (
 	"github.com/squarefactory/cloud-burster/logger"
 	"github.com/squarefactory/cloud-burster/pkg/config"
 	"github.com/squarefactory/cloud-burster/pkg/middlewares"
+	"github.com/squarefactory/cloud-burster/pkg/provider"
 	"github.com/squarefactory/cloud-burster/utils"
+	"github.com/squarefactory/cloud-burster/utils/rollback"
 	"github.com/squarefactory/cloud-burster/utils/try"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/time/rate"
 )
 
 type DataSource struct {
 	http.Client
+	// Retry is the retry policy of the operations waiting for the VM.
+	Retry    try.Policy
 	username string
 	password string
 	zone     string
@@ -39,23 +44,52 @@ const (
 	killNode             = "https://api.shdw-ws.fr/api/vm/kill"
 	releaseStorage       = "https://api.shdw-ws.fr/api/block_device/release"
 	BlockDeviceAllocated = 2
+	VMTerminated         = 3
 )
 
-func New(
-	username string,
-	password string,
-	zone string,
-	sshKey string,
-) *DataSource {
-	s := &DataSource{
-		username: username,
-		password: password,
-		zone:     zone,
-		sshKey:   sshKey,
+// DefaultRetry is the default retry policy of the data source.
+var DefaultRetry = try.Policy{
+	MaxElapsed:   10 * time.Minute,
+	InitialDelay: 2 * time.Second,
+	MaxDelay:     30 * time.Second,
+	Multiplier:   2,
+	Jitter:       0.2,
+	Retryable:    Retryable,
+}
+
+// APIError is returned when the API answers with a non-ok code.
+type APIError struct {
+	StatusCode int
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("shadow API returned non-ok code: %d", e.StatusCode)
+}
+
+// Retryable returns false for the client errors of the API, except timeouts and rate limits,
+// and for the SSH authentication failures.
+func Retryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		code := apiErr.StatusCode
+		if code == http.StatusRequestTimeout || code == http.StatusTooManyRequests {
+			return true
+		}
+		return code < 400 || code >= 500
 	}
-	s.Client.Transport = &middlewares.RoundTripper{
-		RoundTripper: http.DefaultTransport,
+	return !strings.Contains(err.Error(), "ssh: unable to authenticate")
+}
+
+// New returns a data source managing the VMs of the zone of the options.
+func New(opts *Options, limiter *rate.Limiter) *DataSource {
+	s := &DataSource{
+		Retry:    DefaultRetry,
+		username: opts.Username,
+		password: opts.Password,
+		zone:     opts.Zone,
+		sshKey:   opts.SSHKey,
 	}
+	s.Client.Transport = middlewares.Transport(limiter)
 	return s
 }
 
@@ -71,6 +105,11 @@ func (s *DataSource) Create(
 		zap.Any("cloud", cloud),
 	)
 
+	tx := rollback.New("create " + host.Name)
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
 	// Create block device
 	logger.I.Info("creating a block device")
 	StorageUUID, err := s.CreateBlockDevice(ctx, host)
@@ -78,9 +117,12 @@ func (s *DataSource) Create(
 		logger.I.Error("failed to create block device", zap.Error(err))
 		return err
 	}
+	tx.Push("block device "+StorageUUID, func(ctx context.Context) error {
+		return s.DeleteBlockDevice(ctx, DeleteBlockDeviceRequest{UUID: StorageUUID})
+	})
 
 	// Wait for block device to be allocated
-	_, err = try.Do(func() (string, error) {
+	_, err = try.Do(ctx, s.Retry, func() (string, error) {
 
 		Filter := struct {
 			UUID string `json:"uuid"`
@@ -108,7 +150,7 @@ func (s *DataSource) Create(
 				zap.Int("status code", resp.StatusCode),
 				zap.String("body", string(body)),
 			)
-			return "", fmt.Errorf("shadow API returned non-ok code: %d", resp.StatusCode)
+			return "", &APIError{StatusCode: resp.StatusCode}
 		}
 
 		var response BlockDeviceListResponse
@@ -116,12 +158,15 @@ func (s *DataSource) Create(
 			return "", err
 		}
 
+		if len(response.BlockDevices) == 0 {
+			return "", errors.New("block device not found yet")
+		}
 		if response.BlockDevices[0].Status != BlockDeviceAllocated {
 			return "", errors.New("block device has not been allocated yet")
 		}
 
 		return "", nil
-	}, 10, 2*time.Second)
+	})
 
 	if err != nil {
 		logger.I.Error("failed to find block device status", zap.Error(err))
@@ -136,9 +181,19 @@ func (s *DataSource) Create(
 		logger.I.Error("failed to create vm", zap.Error(err))
 		return err
 	}
+	tx.Push("vm "+NodeUUID, func(ctx context.Context) error {
+		if err := s.KillVM(ctx, NodeUUID); err != nil {
+			return err
+		}
+		// The block device is released once the VM is gone
+		if err := try.Sleep(ctx, 10*time.Second); err != nil {
+			return err
+		}
+		return nil
+	})
 
 	// Fetch public IP for provisioning
-	VM, err := try.Do(func() (VM, error) {
+	VM, err := try.Do(ctx, s.Retry, func() (VM, error) {
 
 		Filter := struct {
 			UUID string `json:"uuid"`
@@ -169,7 +224,7 @@ func (s *DataSource) Create(
 				zap.Int("status code", resp.StatusCode),
 				zap.String("body", string(body)),
 			)
-			return VM{}, fmt.Errorf("shadow API returned non-ok code: %d", resp.StatusCode)
+			return VM{}, &APIError{StatusCode: resp.StatusCode}
 		}
 
 		var response VMListResponse
@@ -177,6 +232,9 @@ func (s *DataSource) Create(
 			return VM{}, err
 		}
 
+		if len(response.VMs) == 0 {
+			return VM{}, errors.New("instance not found yet")
+		}
 		if response.VMs[0].VMPublicIPv4 == nil || response.VMs[0].VMPublicSSHPort == nil ||
 			*response.VMs[0].VMPublicIPv4 == "" ||
 			*response.VMs[0].VMPublicSSHPort == 0 {
@@ -184,7 +242,7 @@ func (s *DataSource) Create(
 		}
 
 		return response.VMs[0], nil
-	}, 60, 10*time.Second)
+	})
 
 	if err != nil {
 		logger.I.Error("failed to find public IP", zap.Error(err))
@@ -211,6 +269,7 @@ func (s *DataSource) Create(
 		logger.I.Error("failed to execute postcript", zap.Error(err))
 		return err
 	}
+	tx.Commit()
 
 	logger.I.Info("spawned a server", zap.Any("vm", VM))
 	return nil
@@ -253,7 +312,7 @@ func (s *DataSource) CreateBlockDevice(ctx context.Context, host *config.Host) (
 			zap.Int("status code", resp.StatusCode),
 			zap.String("body", string(body)),
 		)
-		return "", fmt.Errorf("shadow API returned non-ok code: %d", resp.StatusCode)
+		return "", &APIError{StatusCode: resp.StatusCode}
 	}
 
 	var response struct {
@@ -273,6 +332,8 @@ func (s *DataSource) CreateBlockDevice(ctx context.Context, host *config.Host) (
 }
 
 // CreateVM spawns a VM attached to a storage volume and returns its UUID
+//
+// The storage volume is left to the caller on error.
 func (s *DataSource) CreateVM(
 	ctx context.Context,
 	host *config.Host,
@@ -337,10 +398,7 @@ func (s *DataSource) CreateVM(
 			zap.Int("status code", resp.StatusCode),
 			zap.String("body", string(body)),
 		)
-		if err := s.DeleteBlockDevice(ctx, DeleteBlockDeviceRequest{blockDeviceUUID}); err != nil {
-			logger.I.Error(err.Error())
-		}
-		return "", fmt.Errorf("shadow API returned non-ok code: %d", resp.StatusCode)
+		return "", &APIError{StatusCode: resp.StatusCode}
 	}
 
 	var response struct {
@@ -351,16 +409,14 @@ func (s *DataSource) CreateVM(
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		if err := s.DeleteBlockDevice(ctx, DeleteBlockDeviceRequest{blockDeviceUUID}); err != nil {
-			logger.I.Error(err.Error())
-		}
 		return "", err
 	}
 
 	return response.VM.UUID, nil
 }
 
-func (s *DataSource) FindVM(ctx context.Context, name string) (VM, error) {
+// ListVMs returns every VM of the account
+func (s *DataSource) ListVMs(ctx context.Context) ([]VM, error) {
 	requestBody := struct {
 		Filters struct {
 			UUID *string `json:"uuid,omitempty"`
@@ -369,12 +425,12 @@ func (s *DataSource) FindVM(ctx context.Context, name string) (VM, error) {
 
 	jsonBody, err := json.Marshal(requestBody)
 	if err != nil {
-		return VM{}, err
+		return nil, err
 	}
 
 	resp, err := s.InterrogateAPI(ctx, listNode, jsonBody)
 	if err != nil {
-		return VM{}, err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
@@ -385,22 +441,30 @@ func (s *DataSource) FindVM(ctx context.Context, name string) (VM, error) {
 			zap.Int("status code", resp.StatusCode),
 			zap.String("body", string(body)),
 		)
-		return VM{}, fmt.Errorf("shadow API returned non-ok code: %d", resp.StatusCode)
+		return nil, &APIError{StatusCode: resp.StatusCode}
 	}
 
-	// list to get block devices uuid
 	var response VMListResponse
-
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	return response.VMs, nil
+}
+
+func (s *DataSource) FindVM(ctx context.Context, name string) (VM, error) {
+	// list to get block devices uuid
+	vms, err := s.ListVMs(ctx)
+	if err != nil {
 		return VM{}, err
 	}
 
-	if len(response.VMs) <= 0 {
+	if len(vms) <= 0 {
 		return VM{}, fmt.Errorf("VM not found: %v", name)
 	}
 	var vm VM
 	var insertTime time.Time
-	for _, v := range response.VMs {
+	for _, v := range vms {
 		vInsertTime, err := time.Parse(time.RFC3339, v.InsertedOn)
 		if err != nil {
 			logger.I.Error("failed to parse insert time", zap.Error(err))
@@ -423,6 +487,107 @@ func (s *DataSource) FindVM(ctx context.Context, name string) (VM, error) {
 	return vm, nil
 }
 
+// List returns every VM of the account.
+//
+// The VMs cannot be tagged: those whose image URL has the shape written by CreateVM are
+// marked as managed.
+func (s *DataSource) List(ctx context.Context) ([]provider.Instance, error) {
+	logger.I.Debug("List called")
+	vms, err := s.ListVMs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]provider.Instance, 0, len(vms))
+	for _, vm := range vms {
+		out = append(out, toInstance(&vm))
+	}
+	return out, nil
+}
+
+// Get returns the most recent VM named name
+func (s *DataSource) Get(ctx context.Context, name string) (*provider.Instance, error) {
+	logger.I.Debug("Get called", zap.String("name", name))
+	list, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return provider.Newest(list, name)
+}
+
+// nameFromImage extracts the host name injected in the image URL by CreateVM.
+func nameFromImage(image string) string {
+	u, err := url.Parse(image)
+	if err != nil {
+		return ""
+	}
+	p := strings.TrimSuffix(u.Path, "/")
+	if p == "" {
+		return ""
+	}
+	return path.Base(p)
+}
+
+// managedImage returns true if the image URL has the shape written by CreateVM,
+// <scheme>://<host>/<image>/<hostname>/, which marks the VMs created by cloud-burster.
+func managedImage(image string) bool {
+	u, err := url.Parse(image)
+	if err != nil || !strings.HasSuffix(u.Path, "/") {
+		return false
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	return len(segments) == 2 && segments[0] != "" && segments[1] != ""
+}
+
+func toInstance(vm *VM) provider.Instance {
+	var ips []string
+	if vm.VMPublicIPv4 != nil && *vm.VMPublicIPv4 != "" {
+		ips = append(ips, string(*vm.VMPublicIPv4))
+	}
+
+	var createdAt *time.Time
+	if t, err := time.Parse(time.RFC3339, vm.InsertedOn); err == nil {
+		createdAt = &t
+	}
+
+	var volumes []string
+	for _, blockDevice := range vm.BlockDevices {
+		volumes = append(volumes, blockDevice.UUID)
+	}
+
+	return provider.Instance{
+		Name:       nameFromImage(vm.Image),
+		ProviderID: vm.UUID,
+		State:      toState(vm),
+		IPs:        ips,
+		Flavor:     string(vm.VMSku),
+		Image:      vm.Image,
+		CreatedAt:  createdAt,
+		Volumes:    volumes,
+		Managed:    managedImage(vm.Image),
+	}
+}
+
+func toState(vm *VM) provider.State {
+	if vm.Status == VMTerminated {
+		return provider.StateTerminated
+	}
+	status := strings.ToLower(string(vm.StatusStr))
+	switch {
+	case strings.Contains(status, "run"), strings.Contains(status, "start"):
+		return provider.StateRunning
+	case strings.Contains(status, "request"),
+		strings.Contains(status, "pend"),
+		strings.Contains(status, "affect"):
+		return provider.StatePending
+	case strings.Contains(status, "kill"), strings.Contains(status, "stop"):
+		return provider.StateDeleting
+	case strings.Contains(status, "error"), strings.Contains(status, "fail"):
+		return provider.StateError
+	default:
+		return provider.StateUnknown
+	}
+}
+
 // Delete a server
 func (s *DataSource) Delete(ctx context.Context, name string) error {
 	logger.I.Warn("Delete called", zap.String("name", name))
@@ -432,11 +597,39 @@ func (s *DataSource) Delete(ctx context.Context, name string) error {
 		return err
 	}
 
-	// kill VM
+	if err := s.deleteVM(ctx, vm); err != nil {
+		return err
+	}
+
+	logger.I.Warn("Deleted a server", zap.Any("name", name))
+	return nil
+}
+
+// deleteVM kills a VM and releases its block devices
+func (s *DataSource) deleteVM(ctx context.Context, vm VM) error {
+	if err := s.KillVM(ctx, vm.UUID); err != nil {
+		logger.I.Error("failed to kill node", zap.Error(err))
+	}
+
+	// release storage
+	if err := try.Sleep(ctx, 10*time.Second); err != nil {
+		return err
+	}
+
+	for _, blockDevice := range vm.BlockDevices {
+		if err := s.DeleteBlockDevice(ctx, DeleteBlockDeviceRequest(blockDevice)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// KillVM kills a VM by UUID
+func (s *DataSource) KillVM(ctx context.Context, uuid string) error {
 	VM := struct {
 		UUID string `json:"uuid"`
 	}{
-		UUID: vm.UUID,
+		UUID: uuid,
 	}
 
 	requestBodyNode := struct {
@@ -454,37 +647,143 @@ func (s *DataSource) Delete(ctx context.Context, name string) error {
 
 	resp, err := s.InterrogateAPI(ctx, killNode, jsonBody)
 	if err != nil {
-		logger.I.Error("failed to kill node", zap.Error(err))
-	} else {
-		defer resp.Body.Close()
+		return err
+	}
+	defer resp.Body.Close()
 
-		if resp.StatusCode < 200 || resp.StatusCode >= 400 {
-			body, _ := io.ReadAll(resp.Body)
-			logger.I.Error(
-				"shadow API returned non-ok code",
-				zap.Int("status code", resp.StatusCode),
-				zap.String("body", string(body)),
-			)
-			logger.I.Error("shadow API returned non-ok code", zap.Int("status", resp.StatusCode))
-		}
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		logger.I.Error(
+			"shadow API returned non-ok code",
+			zap.Int("status code", resp.StatusCode),
+			zap.String("body", string(body)),
+		)
+		return &APIError{StatusCode: resp.StatusCode}
 	}
 
-	// release storage
-	time.Sleep(10 * time.Second)
+	return nil
+}
 
-	Block := struct {
-		UUID string `json:"uuid"`
-	}{
-		UUID: vm.BlockDevices[0].UUID,
+// ListBlockDevices returns every block device of the account
+func (s *DataSource) ListBlockDevices(ctx context.Context) ([]BlockDeviceList, error) {
+	requestBody := struct {
+		Filters struct {
+			UUID *string `json:"uuid,omitempty"`
+		} `json:"filters"`
+	}{}
+
+	jsonBody, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := s.DeleteBlockDevice(ctx, Block); err != nil {
-		return err
+	resp, err := s.InterrogateAPI(ctx, listStorage, jsonBody)
+	if err != nil {
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	logger.I.Warn("Deleted a server", zap.Any("name", name))
-	return nil
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		logger.I.Error(
+			"shadow API returned non-ok code",
+			zap.Int("status code", resp.StatusCode),
+			zap.String("body", string(body)),
+		)
+		return nil, &APIError{StatusCode: resp.StatusCode}
+	}
 
+	var response BlockDeviceListResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	return response.BlockDevices, nil
+}
+
+// ListDangling returns the allocated block devices which are not attached to a live VM.
+//
+// Block devices carry no name: only those of the terminated VMs of the hosts of the cloud
+// are returned, the other ones may belong to someone else.
+func (s *DataSource) ListDangling(
+	ctx context.Context,
+	cloud *config.Cloud,
+) ([]provider.Resource, error) {
+	logger.I.Debug("ListDangling called")
+	hosts, err := cloud.GenerateHosts()
+	if err != nil {
+		return nil, err
+	}
+	configured := make(map[string]struct{}, len(hosts))
+	for _, host := range hosts {
+		configured[host.Name] = struct{}{}
+	}
+	vms, err := s.ListVMs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	attached := make(map[string]struct{})
+	hostnames := make(map[string]string)
+	for _, vm := range vms {
+		if vm.Status == VMTerminated {
+			name := nameFromImage(vm.Image)
+			if _, ok := configured[name]; !ok {
+				continue
+			}
+			for _, blockDevice := range vm.BlockDevices {
+				hostnames[blockDevice.UUID] = name
+			}
+			continue
+		}
+		for _, blockDevice := range vm.BlockDevices {
+			attached[blockDevice.UUID] = struct{}{}
+		}
+	}
+
+	blockDevices, err := s.ListBlockDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []provider.Resource
+	for _, blockDevice := range blockDevices {
+		if blockDevice.Status != BlockDeviceAllocated || blockDevice.ReleasedOn != nil {
+			continue
+		}
+		if _, ok := attached[blockDevice.UUID]; ok {
+			continue
+		}
+		name, ok := hostnames[blockDevice.UUID]
+		if !ok {
+			continue
+		}
+		out = append(out, provider.Resource{
+			Kind: provider.ResourceBlockDevice,
+			ID:   blockDevice.UUID,
+			Name: name,
+		})
+	}
+	return out, nil
+}
+
+// DeleteResource kills a VM or releases a block device by UUID
+func (s *DataSource) DeleteResource(ctx context.Context, resource provider.Resource) error {
+	logger.I.Warn("DeleteResource called", zap.Any("resource", resource))
+	switch resource.Kind {
+	case provider.ResourceServer:
+		vms, err := s.ListVMs(ctx)
+		if err != nil {
+			return err
+		}
+		for _, vm := range vms {
+			if vm.UUID == resource.ID {
+				return s.deleteVM(ctx, vm)
+			}
+		}
+		return provider.ErrNotFound
+	case provider.ResourceBlockDevice:
+		return s.DeleteBlockDevice(ctx, DeleteBlockDeviceRequest{UUID: resource.ID})
+	}
+	return fmt.Errorf("unsupported resource kind: %s", resource.Kind)
 }
 
 type DeleteBlockDeviceRequest struct {
@@ -518,7 +817,7 @@ func (s *DataSource) DeleteBlockDevice(ctx context.Context, block DeleteBlockDev
 			zap.Int("status code", resp.StatusCode),
 			zap.String("body", string(body)),
 		)
-		return fmt.Errorf("shadow API returned non-ok code: %d", resp.StatusCode)
+		return &APIError{StatusCode: resp.StatusCode}
 	}
 
 	return nil
@@ -554,14 +853,14 @@ func (s *DataSource) ExecutePostcript(
 	d := net.Dialer{Timeout: config.Timeout}
 	addr := fmt.Sprintf("%s:%d", *instance.VMPublicIPv4, *instance.VMPublicSSHPort)
 
-	out, err := try.Do(func() ([]byte, error) {
+	out, err := try.Do(ctx, s.Retry, func() ([]byte, error) {
 		// Healthcheck VM
 		health, err := s.FindVM(ctx, host.Name)
 		if err != nil {
 			return nil, err
 		}
-		if health.Status == 3 {
-			return nil, errors.New("VM is terminated")
+		if health.Status == VMTerminated {
+			return nil, try.Permanent(errors.New("VM is terminated"))
 		}
 		c, err := d.DialContext(ctx, "tcp", addr)
 		if err != nil {
@@ -590,7 +889,7 @@ func (s *DataSource) ExecutePostcript(
 		}
 
 		return out, nil
-	}, 20, 20*time.Second)
+	})
 
 	if err != nil {
 		logger.I.Error("failed to execute postcripts", zap.Error(err))
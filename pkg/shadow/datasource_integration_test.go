@@ -69,12 +69,12 @@ func (suite *DataSourceTestSuite) TestDelete() {
 }
 
 func (suite *DataSourceTestSuite) BeforeTest(suiteName, testName string) {
-	suite.impl = shadow.New(
-		suite.username,
-		suite.password,
-		suite.zone,
-		suite.sshKey,
-	)
+	suite.impl = shadow.New(&shadow.Options{
+		Username: suite.username,
+		Password: suite.password,
+		Zone:     suite.zone,
+		SSHKey:   suite.sshKey,
+	}, nil)
 }
 func TestDataSourceTestSuite(t *testing.T) {
 	username := os.Getenv("BHS_USER")
@@ -0,0 +1,57 @@
+package shadow
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/squarefactory/cloud-burster/pkg/cloud"
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	validation "github.com/squarefactory/cloud-burster/validate"
+	"golang.org/x/crypto/ssh"
+)
+
+func init() {
+	cloud.Register("shadow", cloud.Provider{
+		Description: "Shadow VMs with block devices, configured over SSH.",
+		Options:     func() config.ProviderOptions { return &Options{} },
+		New: func(conf *config.Cloud) (cloud.DataSource, error) {
+			ds := New(conf.Provider.(*Options), conf.RateLimit.Limiter())
+			ds.Retry = conf.Retry.Apply(ds.Retry)
+			return ds, nil
+		},
+	})
+}
+
+// Options are the options of the provider block of the shadow clouds.
+type Options struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Zone     string `yaml:"zone"`
+	// SSHKey is the base64-encoded private key used to connect to the instances.
+	SSHKey string `yaml:"sshKey"`
+}
+
+// Validate checks the fields, and that SSHKey is a base64-encoded private key.
+func (o *Options) Validate() error {
+	if err := validation.I.Struct(o); err != nil {
+		return err
+	}
+	if o.SSHKey == "" {
+		return &config.OptionError{Key: "sshKey", Err: errors.New("the private key is empty")}
+	}
+	pk, err := base64.StdEncoding.DecodeString(o.SSHKey)
+	if err != nil {
+		return &config.OptionError{
+			Key: "sshKey",
+			Err: fmt.Errorf("the private key is not valid base64: %w", err),
+		}
+	}
+	if _, err := ssh.ParsePrivateKey(pk); err != nil {
+		return &config.OptionError{
+			Key: "sshKey",
+			Err: fmt.Errorf("couldn't parse the private key: %w", err),
+		}
+	}
+	return nil
+}
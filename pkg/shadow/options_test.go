@@ -0,0 +1,92 @@
+//go:build unit
+
+package shadow_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+
+	"github.com/squarefactory/cloud-burster/pkg/shadow"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/ssh"
+)
+
+var cleanOptions = shadow.Options{
+	Username: "username",
+	Password: "password",
+	Zone:     "zone",
+	SSHKey:   generateSSHKey(),
+}
+
+// generateSSHKey returns a new base64-encoded OpenSSH private key.
+func generateSSHKey() string {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		panic(err)
+	}
+	return base64.StdEncoding.EncodeToString(pem.EncodeToMemory(block))
+}
+
+type OptionsTestSuite struct {
+	suite.Suite
+}
+
+func (suite *OptionsTestSuite) TestValidate() {
+	tests := []struct {
+		input         *shadow.Options
+		isError       bool
+		errorContains []string
+		title         string
+	}{
+		{
+			input: &cleanOptions,
+			title: "Positive test",
+		},
+		{
+			isError:       true,
+			errorContains: []string{"sshKey", "the private key is empty"},
+			input:         &shadow.Options{},
+			title:         "Empty key",
+		},
+		{
+			isError:       true,
+			errorContains: []string{"sshKey", "not valid base64"},
+			input:         &shadow.Options{SSHKey: "not base64"},
+			title:         "Key not in base64",
+		},
+		{
+			isError:       true,
+			errorContains: []string{"sshKey", "couldn't parse the private key"},
+			input:         &shadow.Options{SSHKey: "a2V5"},
+			title:         "Not a private key",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Act
+			err := tt.input.Validate()
+
+			// Assert
+			if tt.isError {
+				suite.Error(err)
+				for _, contain := range tt.errorContains {
+					suite.ErrorContains(err, contain)
+				}
+			} else {
+				suite.NoError(err)
+			}
+		})
+	}
+}
+
+func TestOptionsTestSuite(t *testing.T) {
+	suite.Run(t, &OptionsTestSuite{})
+}
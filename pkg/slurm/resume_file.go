@@ -0,0 +1,37 @@
+package slurm
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// ResumeJob is a job entry of the SLURM_RESUME_FILE.
+type ResumeJob struct {
+	ExtraFeatures string `json:"extra"`
+	Features      string `json:"features"`
+	JobID         int    `json:"job_id"`
+	NodesAlloc    string `json:"nodes_alloc"`
+	NodesResume   string `json:"nodes_resume"`
+	Oversubscribe string `json:"oversubscribe"`
+	Partition     string `json:"partition"`
+	Reservation   string `json:"reservation"`
+}
+
+// ResumeFile is the JSON file passed by slurmctld to the ResumeProgram through SLURM_RESUME_FILE.
+type ResumeFile struct {
+	AllNodesResume string      `json:"all_nodes_resume"`
+	Jobs           []ResumeJob `json:"jobs"`
+}
+
+// ParseResumeFile reads a SLURM_RESUME_FILE.
+func ParseResumeFile(filePath string) (*ResumeFile, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	file := &ResumeFile{}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
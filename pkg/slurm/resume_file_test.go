@@ -0,0 +1,61 @@
+//go:build unit
+
+package slurm_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/squarefactory/cloud-burster/pkg/slurm"
+	"github.com/stretchr/testify/suite"
+)
+
+type ResumeFileTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ResumeFileTestSuite) TestParseResumeFile() {
+	// Arrange
+	path := filepath.Join(suite.T().TempDir(), "resume.json")
+	err := os.WriteFile(path, []byte(`{
+  "all_nodes_resume": "cn[1-3]",
+  "jobs": [
+    {
+      "extra": "",
+      "features": "c1",
+      "job_id": 140814,
+      "nodes_alloc": "cn[1-4]",
+      "nodes_resume": "cn[1-3]",
+      "oversubscribe": "OK",
+      "partition": "main",
+      "reservation": ""
+    }
+  ]
+}`), 0o600)
+	suite.NoError(err)
+	expected := &slurm.ResumeFile{
+		AllNodesResume: "cn[1-3]",
+		Jobs: []slurm.ResumeJob{
+			{
+				Features:      "c1",
+				JobID:         140814,
+				NodesAlloc:    "cn[1-4]",
+				NodesResume:   "cn[1-3]",
+				Oversubscribe: "OK",
+				Partition:     "main",
+			},
+		},
+	}
+
+	// Act
+	actual, err := slurm.ParseResumeFile(path)
+
+	// Assert
+	suite.NoError(err)
+	suite.Equal(expected, actual)
+}
+
+func TestResumeFileTestSuite(t *testing.T) {
+	suite.Run(t, &ResumeFileTestSuite{})
+}
@@ -0,0 +1,63 @@
+package slurm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/squarefactory/cloud-burster/logger"
+	"github.com/squarefactory/cloud-burster/utils/pool"
+	"go.uber.org/zap"
+)
+
+// setDownTimeout bounds the call to scontrol marking a node down.
+const setDownTimeout = 30 * time.Second
+
+// Run applies fn on every node, with at most parallelism nodes at the same time.
+//
+// Each node is handled on its own: a failing node is marked down with the
+// error as reason, without blocking the others. Run returns every error.
+func Run(
+	ctx context.Context,
+	scontrol *Scontrol,
+	action string,
+	nodes []string,
+	parallelism int,
+	fn func(ctx context.Context, node string) error,
+) error {
+	// The pool must not skip the nodes once interrupted: they are marked down too.
+	errs := pool.Each(
+		context.WithoutCancel(ctx),
+		parallelism,
+		nodes,
+		func(_ context.Context, node string) error {
+			err := fn(ctx, node)
+			if err == nil {
+				return nil
+			}
+			logger.I.Error(
+				"slurm hook failed",
+				zap.Error(err),
+				zap.String("action", action),
+				zap.String("node", node),
+			)
+			reason := strings.ReplaceAll(
+				fmt.Sprintf("cloud-burster %s failed: %s", action, err),
+				"\n",
+				" ",
+			)
+			err = fmt.Errorf("%s %s: %w", action, node, err)
+			// Mark the node down even if the operation was interrupted
+			downCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), setDownTimeout)
+			defer cancel()
+			if dErr := scontrol.SetDown(downCtx, node, reason); dErr != nil {
+				logger.I.Error("couldn't mark the node down", zap.Error(dErr), zap.String("node", node))
+				err = errors.Join(err, dErr)
+			}
+			return err
+		},
+	)
+	return errors.Join(errs...)
+}
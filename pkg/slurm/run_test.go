@@ -0,0 +1,103 @@
+//go:build unit
+
+package slurm_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/squarefactory/cloud-burster/pkg/slurm"
+	"github.com/stretchr/testify/suite"
+)
+
+type RunTestSuite struct {
+	suite.Suite
+	scontrol *slurm.Scontrol
+	calls    string
+}
+
+func (suite *RunTestSuite) BeforeTest(suiteName, testName string) {
+	dir := suite.T().TempDir()
+	suite.calls = filepath.Join(dir, "calls")
+	stub := filepath.Join(dir, "scontrol")
+	err := os.WriteFile(stub, []byte(`#!/bin/sh
+echo "$@" >> `+suite.calls+`
+`), 0o700)
+	suite.NoError(err)
+	suite.scontrol = &slurm.Scontrol{Path: stub}
+}
+
+func (suite *RunTestSuite) TestRun() {
+	// Act
+	err := slurm.Run(
+		context.Background(),
+		suite.scontrol,
+		"resume",
+		[]string{"cn1", "cn2"},
+		0,
+		func(ctx context.Context, node string) error {
+			if node == "cn2" {
+				return errors.New("boom")
+			}
+			return nil
+		},
+	)
+
+	// Assert
+	suite.Error(err)
+	suite.ErrorContains(err, "resume cn2: boom")
+	calls, err := os.ReadFile(suite.calls)
+	suite.NoError(err)
+	suite.Equal(
+		"update nodename=cn2 state=down reason=cloud-burster resume failed: boom\n",
+		string(calls),
+	)
+}
+
+func (suite *RunTestSuite) TestRunSuccess() {
+	// Act
+	err := slurm.Run(
+		context.Background(),
+		suite.scontrol,
+		"suspend",
+		[]string{"cn1", "cn2"},
+		0,
+		func(ctx context.Context, node string) error {
+			return nil
+		},
+	)
+
+	// Assert
+	suite.NoError(err)
+	_, err = os.Stat(suite.calls)
+	suite.True(os.IsNotExist(err))
+}
+
+func (suite *RunTestSuite) TestRunScontrolFailure() {
+	// Arrange
+	suite.scontrol.Path = filepath.Join(suite.T().TempDir(), "missing")
+
+	// Act
+	err := slurm.Run(
+		context.Background(),
+		suite.scontrol,
+		"resume",
+		[]string{"cn1"},
+		1,
+		func(ctx context.Context, node string) error {
+			return errors.New("boom")
+		},
+	)
+
+	// Assert
+	suite.Error(err)
+	suite.ErrorContains(err, "resume cn1: boom")
+	suite.ErrorContains(err, "scontrol update nodename=cn1")
+}
+
+func TestRunTestSuite(t *testing.T) {
+	suite.Run(t, &RunTestSuite{})
+}
@@ -0,0 +1,37 @@
+package slurm
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/squarefactory/cloud-burster/logger"
+	"go.uber.org/zap"
+)
+
+// Scontrol runs scontrol commands.
+type Scontrol struct {
+	// Path is the scontrol executable. It can be replaced by a stub.
+	Path string
+}
+
+// SetDown marks a node down with a reason.
+func (s *Scontrol) SetDown(ctx context.Context, node string, reason string) error {
+	return s.update(ctx, node, "state=down", "reason="+reason)
+}
+
+func (s *Scontrol) update(ctx context.Context, node string, args ...string) error {
+	args = append([]string{"update", "nodename=" + node}, args...)
+	logger.I.Info("calling scontrol", zap.String("path", s.Path), zap.Strings("args", args))
+	out, err := exec.CommandContext(ctx, s.Path, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf(
+			"scontrol %s failed: %w: %s",
+			strings.Join(args, " "),
+			err,
+			strings.TrimSpace(string(out)),
+		)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/squarefactory/cloud-burster/utils/flock"
+)
+
+// FileStore is a Store persisted in a JSON file.
+//
+// Concurrent processes are serialized with an advisory lock on "<path>.lock".
+type FileStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{
+		path: path,
+	}
+}
+
+func (s *FileStore) Get(hostname string) (*Record, error) {
+	var record *Record
+	err := s.view(func(records map[string]Record) error {
+		r, ok := records[hostname]
+		if !ok {
+			return ErrNotFound
+		}
+		record = &r
+		return nil
+	})
+	return record, err
+}
+
+func (s *FileStore) Put(record *Record) error {
+	return s.update(func(records map[string]Record) error {
+		records[record.Hostname] = *record
+		return nil
+	})
+}
+
+func (s *FileStore) Delete(hostname string) error {
+	return s.update(func(records map[string]Record) error {
+		if _, ok := records[hostname]; !ok {
+			return ErrNotFound
+		}
+		delete(records, hostname)
+		return nil
+	})
+}
+
+func (s *FileStore) List() ([]Record, error) {
+	var out []Record
+	err := s.view(func(records map[string]Record) error {
+		out = sortRecords(records)
+		return nil
+	})
+	return out, err
+}
+
+func (s *FileStore) view(fn func(records map[string]Record) error) error {
+	return s.locked(func() error {
+		records, err := s.read()
+		if err != nil {
+			return err
+		}
+		return fn(records)
+	})
+}
+
+func (s *FileStore) update(fn func(records map[string]Record) error) error {
+	return s.locked(func() error {
+		records, err := s.read()
+		if err != nil {
+			return err
+		}
+		if err := fn(records); err != nil {
+			return err
+		}
+		return s.write(records)
+	})
+}
+
+func (s *FileStore) locked(fn func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	lock, err := flock.Acquire(s.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = lock.Release()
+	}()
+	return fn()
+}
+
+func (s *FileStore) read() (map[string]Record, error) {
+	records := make(map[string]Record)
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return records, nil
+	}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// write replaces the file atomically.
+func (s *FileStore) write(records map[string]Record) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,62 @@
+package state
+
+import (
+	"sort"
+	"sync"
+)
+
+// MemoryStore is a Store which is not persisted.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records: make(map[string]Record),
+	}
+}
+
+func (s *MemoryStore) Get(hostname string) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.records[hostname]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &record, nil
+}
+
+func (s *MemoryStore) Put(record *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[record.Hostname] = *record
+	return nil
+}
+
+func (s *MemoryStore) Delete(hostname string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.records[hostname]; !ok {
+		return ErrNotFound
+	}
+	delete(s.records, hostname)
+	return nil
+}
+
+func (s *MemoryStore) List() ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortRecords(s.records), nil
+}
+
+func sortRecords(records map[string]Record) []Record {
+	out := make([]Record, 0, len(records))
+	for _, record := range records {
+		out = append(out, record)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Hostname < out[j].Hostname
+	})
+	return out
+}
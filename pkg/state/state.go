@@ -0,0 +1,62 @@
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/squarefactory/cloud-burster/pkg/config"
+)
+
+// DefaultPath is the default location of the file store.
+const DefaultPath = "/var/lib/cloud-burster/state.json"
+
+// ErrNotFound is returned when no record exists for a hostname.
+var ErrNotFound = errors.New("record not found")
+
+// Record binds a hostname to the provider resources backing it.
+type Record struct {
+	Hostname   string    `json:"hostname"          yaml:"hostname"`
+	Cloud      int       `json:"cloud"             yaml:"cloud"`
+	CloudType  string    `json:"cloudType"         yaml:"cloudType"`
+	ProviderID string    `json:"providerID"        yaml:"providerID"`
+	Ports      []string  `json:"ports,omitempty"   yaml:"ports,omitempty"`
+	Volumes    []string  `json:"volumes,omitempty" yaml:"volumes,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"         yaml:"createdAt"`
+	// ConfigHash is the hash of the host and cloud configuration used to create the instance.
+	ConfigHash string `json:"configHash" yaml:"configHash"`
+}
+
+// Store persists the records.
+type Store interface {
+	Get(hostname string) (*Record, error)
+	Put(record *Record) error
+	Delete(hostname string) error
+	List() ([]Record, error)
+}
+
+// Hash returns a digest of the configuration of a host.
+func Hash(host *config.Host, cloud *config.Cloud) (string, error) {
+	data, err := json.Marshal(struct {
+		Host  *config.Host
+		Cloud *config.Cloud
+	}{
+		Host:  host,
+		Cloud: cloud,
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Open returns the file store at path, or a memory store if path is empty.
+func Open(path string) Store {
+	if path == "" {
+		return NewMemoryStore()
+	}
+	return NewFileStore(path)
+}
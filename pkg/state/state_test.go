@@ -0,0 +1,125 @@
+//go:build unit
+
+package state_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/squarefactory/cloud-burster/pkg/config"
+	"github.com/squarefactory/cloud-burster/pkg/state"
+	"github.com/stretchr/testify/suite"
+)
+
+type StoreTestSuite struct {
+	suite.Suite
+}
+
+func (suite *StoreTestSuite) TestStore() {
+	createdAt := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		input func() state.Store
+		title string
+	}{
+		{
+			input: func() state.Store {
+				return state.NewMemoryStore()
+			},
+			title: "Memory store",
+		},
+		{
+			input: func() state.Store {
+				return state.NewFileStore(filepath.Join(suite.T().TempDir(), "sub", "state.json"))
+			},
+			title: "File store",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Arrange
+			store := tt.input()
+			records := []state.Record{
+				{
+					Hostname:   "cn-2",
+					Cloud:      1,
+					CloudType:  "shadow",
+					ProviderID: "uuid-2",
+					Volumes:    []string{"bd-2"},
+					CreatedAt:  createdAt,
+					ConfigHash: "hash",
+				},
+				{
+					Hostname:   "cn-1",
+					CloudType:  "openstack",
+					ProviderID: "uuid-1",
+					Ports:      []string{"port-1"},
+					CreatedAt:  createdAt,
+				},
+			}
+
+			// Act
+			for i := range records {
+				suite.NoError(store.Put(&records[i]))
+			}
+			record, err := store.Get("cn-2")
+
+			// Assert
+			suite.NoError(err)
+			suite.Equal(&records[0], record)
+
+			// Act
+			list, err := store.List()
+
+			// Assert
+			suite.NoError(err)
+			suite.Equal([]state.Record{records[1], records[0]}, list)
+
+			// Act
+			err = store.Delete("cn-2")
+			suite.NoError(err)
+			_, err = store.Get("cn-2")
+
+			// Assert
+			suite.ErrorIs(err, state.ErrNotFound)
+			suite.ErrorIs(store.Delete("cn-2"), state.ErrNotFound)
+		})
+	}
+}
+
+func (suite *StoreTestSuite) TestFileStorePersists() {
+	// Arrange
+	path := filepath.Join(suite.T().TempDir(), "state.json")
+	record := &state.Record{Hostname: "cn-1", ProviderID: "uuid-1"}
+	suite.NoError(state.NewFileStore(path).Put(record))
+
+	// Act
+	actual, err := state.NewFileStore(path).Get("cn-1")
+
+	// Assert
+	suite.NoError(err)
+	suite.Equal(record, actual)
+}
+
+func (suite *StoreTestSuite) TestHash() {
+	// Arrange
+	host := &config.Host{Name: "cn-1", FlavorName: "d2-2"}
+	cl := &config.Cloud{Type: "openstack"}
+
+	// Act
+	a, err := state.Hash(host, cl)
+	suite.NoError(err)
+	b, err := state.Hash(&config.Host{Name: "cn-1", FlavorName: "d2-4"}, cl)
+	suite.NoError(err)
+	c, err := state.Hash(host, cl)
+	suite.NoError(err)
+
+	// Assert
+	suite.NotEqual(a, b)
+	suite.Equal(a, c)
+}
+
+func TestStoreTestSuite(t *testing.T) {
+	suite.Run(t, &StoreTestSuite{})
+}
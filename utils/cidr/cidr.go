@@ -0,0 +1,183 @@
+// Package cidr parses and allocates IP addresses.
+package cidr
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net/netip"
+	"strings"
+)
+
+// ErrExhausted is returned when a pool has not enough addresses.
+var ErrExhausted = errors.New("not enough IP addresses")
+
+// Range is an inclusive range of IP addresses.
+type Range struct {
+	First netip.Addr
+	Last  netip.Addr
+}
+
+// ParseRange parses an address, a range of addresses "first-last", or a CIDR.
+func ParseRange(s string) (Range, error) {
+	return parse(s, Prefix)
+}
+
+// ParseHostRange is ParseRange, without the network and broadcast addresses of an IPv4 CIDR.
+func ParseHostRange(s string) (Range, error) {
+	return parse(s, Hosts)
+}
+
+func parse(s string, fromPrefix func(netip.Prefix) Range) (Range, error) {
+	s = strings.TrimSpace(s)
+	if first, last, ok := strings.Cut(s, "-"); ok {
+		r := Range{}
+		var err error
+		if r.First, err = netip.ParseAddr(strings.TrimSpace(first)); err != nil {
+			return Range{}, err
+		}
+		if r.Last, err = netip.ParseAddr(strings.TrimSpace(last)); err != nil {
+			return Range{}, err
+		}
+		if r.First.BitLen() != r.Last.BitLen() || r.Last.Less(r.First) {
+			return Range{}, fmt.Errorf("invalid IP range: %s", s)
+		}
+		return r, nil
+	}
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return Range{}, err
+		}
+		return fromPrefix(prefix), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return Range{}, err
+	}
+	return Range{First: addr, Last: addr}, nil
+}
+
+// Hosts returns the range of the host addresses of a prefix.
+//
+// The network and broadcast addresses of an IPv4 prefix are excluded, except for /31 and /32.
+// The subnet-router anycast address of an IPv6 prefix is excluded, except for /127 and /128.
+func Hosts(prefix netip.Prefix) Range {
+	r := Prefix(prefix)
+	switch {
+	case r.First.Is4() && prefix.Bits() < 31:
+		r.First, r.Last = r.First.Next(), r.Last.Prev()
+	case r.First.Is6() && prefix.Bits() < 127:
+		r.First = r.First.Next()
+	}
+	return r
+}
+
+// Prefix returns the range of every address of a prefix.
+func Prefix(prefix netip.Prefix) Range {
+	prefix = prefix.Masked()
+	first := prefix.Addr()
+	b := first.AsSlice()
+	for i := prefix.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 1 << (7 - i%8)
+	}
+	last, _ := netip.AddrFromSlice(b)
+	return Range{First: first, Last: last}
+}
+
+// Contains reports whether addr is in the range.
+func (r Range) Contains(addr netip.Addr) bool {
+	return r.First.Compare(addr) <= 0 && addr.Compare(r.Last) <= 0
+}
+
+// ParseRanges parses every range with ParseRange.
+func ParseRanges(ss []string) ([]Range, error) {
+	return parseAll(ss, ParseRange)
+}
+
+// ParsePool parses every range with ParseHostRange.
+func ParsePool(ss []string) ([]Range, error) {
+	return parseAll(ss, ParseHostRange)
+}
+
+func parseAll(ss []string, parse func(string) (Range, error)) ([]Range, error) {
+	out := make([]Range, 0, len(ss))
+	for _, s := range ss {
+		r, err := parse(s)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// Allocate returns n addresses of the pool, in order.
+//
+// The first offset addresses are skipped, then the addresses in exclude are.
+// ErrExhausted is returned if the pool is too small.
+func Allocate(pool []Range, exclude []Range, offset int, n int) ([]netip.Addr, error) {
+	if offset < 0 {
+		return nil, fmt.Errorf("negative offset: %d", offset)
+	}
+	out := make([]netip.Addr, 0, n)
+	if n == 0 {
+		return out, nil
+	}
+	for _, r := range pool {
+		first := r.First
+		if offset > 0 {
+			// Skip the offset arithmetically: an IPv6 range is too large to walk
+			size := r.size()
+			if size.Cmp(big.NewInt(int64(offset))) <= 0 {
+				offset -= int(size.Int64())
+				continue
+			}
+			first = add(r.First, offset)
+			offset = 0
+		}
+		for addr := first; addr.IsValid() && !r.Last.Less(addr); addr = addr.Next() {
+			if e, ok := excluded(exclude, addr); ok {
+				// Skip the whole excluded range
+				if e.Last.Less(r.Last) {
+					addr = e.Last
+					continue
+				}
+				break
+			}
+			out = append(out, addr)
+			if len(out) == n {
+				return out, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: %d requested, %d available", ErrExhausted, n, len(out))
+}
+
+// size returns the number of addresses in r.
+func (r Range) size() *big.Int {
+	size := new(big.Int).Sub(toInt(r.Last), toInt(r.First))
+	return size.Add(size, big.NewInt(1))
+}
+
+// add returns addr advanced by n addresses. n must stay in the address family.
+func add(addr netip.Addr, n int) netip.Addr {
+	sum := toInt(addr)
+	sum.Add(sum, big.NewInt(int64(n)))
+	b := make([]byte, addr.BitLen()/8)
+	out, _ := netip.AddrFromSlice(sum.FillBytes(b))
+	return out
+}
+
+func toInt(addr netip.Addr) *big.Int {
+	return new(big.Int).SetBytes(addr.AsSlice())
+}
+
+func excluded(exclude []Range, addr netip.Addr) (Range, bool) {
+	for _, r := range exclude {
+		if r.Contains(addr) {
+			return r, true
+		}
+	}
+	return Range{}, false
+}
@@ -0,0 +1,197 @@
+package cidr_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/squarefactory/cloud-burster/utils/cidr"
+	"github.com/stretchr/testify/suite"
+)
+
+type CIDRTestSuite struct {
+	suite.Suite
+}
+
+func (suite *CIDRTestSuite) TestParseRange() {
+	tests := []struct {
+		input         string
+		expectedFirst string
+		expectedLast  string
+		isError       bool
+		title         string
+	}{
+		{
+			input:         "10.0.0.5",
+			expectedFirst: "10.0.0.5",
+			expectedLast:  "10.0.0.5",
+			title:         "Address",
+		},
+		{
+			input:         "10.0.0.5-10.0.1.2",
+			expectedFirst: "10.0.0.5",
+			expectedLast:  "10.0.1.2",
+			title:         "Range",
+		},
+		{
+			input:         "10.0.0.0/24",
+			expectedFirst: "10.0.0.0",
+			expectedLast:  "10.0.0.255",
+			title:         "CIDR",
+		},
+		{
+			input:         "10.0.0.7/32",
+			expectedFirst: "10.0.0.7",
+			expectedLast:  "10.0.0.7",
+			title:         "Single address CIDR",
+		},
+		{
+			input:   "10.0.0.9-10.0.0.1",
+			isError: true,
+			title:   "Reversed range",
+		},
+		{
+			input:   "10.0.0.0/33",
+			isError: true,
+			title:   "Invalid CIDR",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Act
+			actual, err := cidr.ParseRange(tt.input)
+
+			// Assert
+			if tt.isError {
+				suite.Error(err)
+			} else {
+				suite.NoError(err)
+				suite.Equal(netip.MustParseAddr(tt.expectedFirst), actual.First)
+				suite.Equal(netip.MustParseAddr(tt.expectedLast), actual.Last)
+			}
+		})
+	}
+}
+
+func (suite *CIDRTestSuite) TestHosts() {
+	tests := []struct {
+		input         string
+		expectedFirst string
+		expectedLast  string
+		title         string
+	}{
+		{
+			input:         "10.0.0.0/24",
+			expectedFirst: "10.0.0.1",
+			expectedLast:  "10.0.0.254",
+			title:         "Without network and broadcast",
+		},
+		{
+			input:         "2001:db8::/64",
+			expectedFirst: "2001:db8::1",
+			expectedLast:  "2001:db8::ffff:ffff:ffff:ffff",
+			title:         "IPv6 without subnet-router anycast",
+		},
+		{
+			input:         "10.0.0.6/31",
+			expectedFirst: "10.0.0.6",
+			expectedLast:  "10.0.0.7",
+			title:         "Point to point",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Act
+			actual := cidr.Hosts(netip.MustParsePrefix(tt.input))
+
+			// Assert
+			suite.Equal(netip.MustParseAddr(tt.expectedFirst), actual.First)
+			suite.Equal(netip.MustParseAddr(tt.expectedLast), actual.Last)
+		})
+	}
+}
+
+func (suite *CIDRTestSuite) TestAllocate() {
+	tests := []struct {
+		pool     []string
+		exclude  []string
+		offset   int
+		n        int
+		expected []string
+		isError  bool
+		title    string
+	}{
+		{
+			pool:     []string{"10.0.0.0/29"},
+			offset:   2,
+			n:        3,
+			expected: []string{"10.0.0.3", "10.0.0.4", "10.0.0.5"},
+			title:    "Offset",
+		},
+		{
+			pool:     []string{"10.0.0.1-10.0.0.3", "10.0.1.1"},
+			exclude:  []string{"10.0.0.2"},
+			n:        3,
+			expected: []string{"10.0.0.1", "10.0.0.3", "10.0.1.1"},
+			title:    "Ranges and exclusions",
+		},
+		{
+			pool:     []string{"10.0.0.0/16"},
+			exclude:  []string{"10.0.0.0/24"},
+			n:        1,
+			expected: []string{"10.0.1.0"},
+			title:    "Excluded CIDR",
+		},
+		{
+			pool:     []string{"10.0.0.1-10.0.0.2", "10.0.1.0/29"},
+			offset:   3,
+			n:        2,
+			expected: []string{"10.0.1.2", "10.0.1.3"},
+			title:    "Offset across ranges",
+		},
+		{
+			pool:     []string{"2001:db8::/64"},
+			offset:   1 << 40,
+			n:        1,
+			expected: []string{"2001:db8::100:0:1"},
+			title:    "Large IPv6 offset",
+		},
+		{
+			pool:    []string{"10.0.0.0/30"},
+			offset:  10,
+			n:       1,
+			isError: true,
+			title:   "Offset out of the pool",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Arrange
+			pool, err := cidr.ParsePool(tt.pool)
+			suite.Require().NoError(err)
+			exclude, err := cidr.ParseRanges(tt.exclude)
+			suite.Require().NoError(err)
+
+			// Act
+			actual, err := cidr.Allocate(pool, exclude, tt.offset, tt.n)
+
+			// Assert
+			if tt.isError {
+				suite.ErrorIs(err, cidr.ErrExhausted)
+			} else {
+				suite.NoError(err)
+				expected := make([]netip.Addr, 0, len(tt.expected))
+				for _, s := range tt.expected {
+					expected = append(expected, netip.MustParseAddr(s))
+				}
+				suite.Equal(expected, actual)
+			}
+		})
+	}
+}
+
+func TestCIDRTestSuite(t *testing.T) {
+	suite.Run(t, &CIDRTestSuite{})
+}
@@ -1,34 +0,0 @@
-package cidr
-
-import (
-	"encoding/binary"
-	"log"
-	"net"
-)
-
-func Hosts(cidr string) []string {
-	// convert string to IPNet struct
-	_, ipv4Net, err := net.ParseCIDR(cidr)
-	if err != nil {
-		log.Fatal(err)
-	}
-	// convert IPNet struct mask and address to uint32
-	mask := binary.BigEndian.Uint32(ipv4Net.Mask)
-	// find the start IP address
-	start := binary.BigEndian.Uint32(ipv4Net.IP)
-	// find the final IP address
-	finish := (start & mask) | (mask ^ 0xffffffff)
-	// make a slice to return host addresses
-	var hosts []string
-	// loop through addresses as uint32.
-	// I used "start + 1" and "finish - 1" to discard the network and broadcast addresses.
-	for i := start + 1; i <= finish-1; i++ {
-		// convert back to net.IPs
-		// Create IP address of type net.IP. IPv4 is 4 bytes, IPv6 is 16 bytes.
-		ip := make(net.IP, 4)
-		binary.BigEndian.PutUint32(ip, i)
-		hosts = append(hosts, ip.String())
-	}
-	// return a slice of strings containing IP addresses
-	return hosts
-}
@@ -0,0 +1,45 @@
+package flock
+
+import (
+	"errors"
+	"os"
+)
+
+// ErrLocked is returned by TryAcquire when the lock is held by someone else.
+var ErrLocked = errors.New("file is locked")
+
+// Lock is an exclusive advisory lock on a file.
+type Lock struct {
+	f *os.File
+}
+
+// Acquire blocks until the lock on path is acquired. The file is created if missing.
+func Acquire(path string) (*Lock, error) {
+	return acquire(path, true)
+}
+
+// TryAcquire acquires the lock on path or returns ErrLocked immediately.
+func TryAcquire(path string) (*Lock, error) {
+	return acquire(path, false)
+}
+
+func acquire(path string, block bool) (*Lock, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lock(f, block); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &Lock{f: f}, nil
+}
+
+// Release releases the lock.
+func (l *Lock) Release() error {
+	if err := unlock(l.f); err != nil {
+		_ = l.f.Close()
+		return err
+	}
+	return l.f.Close()
+}
@@ -1,12 +1,20 @@
 package generators
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
 
+// MaxHosts bounds the number of hostnames generated by a single expression.
+const MaxHosts = 1 << 20
+
+// ErrInvalidHostlist is returned for malformed hostlist expressions.
+var ErrInvalidHostlist = errors.New("invalid hostlist")
+
 var matchCommaOutsideOfBrackets = regexp.MustCompile(`(?:\[+[^\[\]]*\]+|[^,])+`)
 
 // SplitCommaOutsideOfBrackets generates strings
@@ -18,83 +26,268 @@ func SplitCommaOutsideOfBrackets(pattern string) []string {
 	return res
 }
 
-// ExpandBrackets generates strings based on brackets ranges or digits.
+// Expand generates the hostnames of a Slurm hostlist expression.
 //
-// cn[1,2-4] generates cn1, cn2, cn4 and cn3.
-func ExpandBrackets(pattern string) []string {
+// cn[01-03],gpu[1-2] generates cn01, cn02, cn03, gpu1 and gpu2. See ExpandBrackets.
+func Expand(hostlist string) ([]string, error) {
 	var out []string
+	for _, pattern := range SplitCommaOutsideOfBrackets(hostlist) {
+		hostnames, err := ExpandBrackets(pattern)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, hostnames...)
+		if len(out) > MaxHosts {
+			return nil, fmt.Errorf("%w: %s: more than %d hosts", ErrInvalidHostlist, hostlist, MaxHosts)
+		}
+	}
+	return out, nil
+}
+
+// ExpandBrackets generates strings based on brackets ranges or digits.
+//
+// cn[1,2-4] generates cn1, cn2, cn3 and cn4. Each bracket group multiplies the names:
+// cn[1-2]-[1-2] generates cn1-1, cn1-2, cn2-1 and cn2-2.
+//
+// See ParseRangeList for the syntax inside the brackets.
+func ExpandBrackets(pattern string) ([]string, error) {
 	if pattern == "" {
-		return []string{}
+		return []string{}, nil
 	}
 
-	beginIdx := -1
-	for idx, rune := range pattern {
-		// Search for '['
-		if rune == '[' {
-			beginIdx = idx
-		}
+	beginIdx := strings.IndexAny(pattern, "[]")
+	if beginIdx == -1 {
+		// This means there is no brackets
+		return []string{pattern}, nil
+	}
+	if pattern[beginIdx] == ']' {
+		return nil, fmt.Errorf("%w: %s: unbalanced brackets", ErrInvalidHostlist, pattern)
+	}
+	endIdx := strings.IndexAny(pattern[beginIdx+1:], "[]")
+	if endIdx == -1 || pattern[beginIdx+1+endIdx] == '[' {
+		return nil, fmt.Errorf("%w: %s: unbalanced brackets", ErrInvalidHostlist, pattern)
+	}
+	endIdx += beginIdx + 1
 
-		// Search for ']'
-		if rune == ']' && beginIdx != -1 && beginIdx <= idx {
-			digits := ParseRangeList(pattern[beginIdx+1 : idx])
+	items, err := ParseRangeList(pattern[beginIdx+1 : endIdx])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", pattern, err)
+	}
+	suffixes, err := ExpandBrackets(pattern[endIdx+1:])
+	if err != nil {
+		return nil, err
+	}
+	if len(suffixes) == 0 {
+		suffixes = []string{""}
+	}
+	if len(items)*len(suffixes) > MaxHosts {
+		return nil, fmt.Errorf("%w: %s: more than %d hosts", ErrInvalidHostlist, pattern, MaxHosts)
+	}
 
-			// Add the generated name
-			for _, digit := range digits {
-				out = append(out, fmt.Sprintf("%s%d%s", pattern[:beginIdx], digit, pattern[idx+1:]))
-			}
+	out := make([]string, 0, len(items)*len(suffixes))
+	for _, item := range items {
+		for _, suffix := range suffixes {
+			out = append(out, pattern[:beginIdx]+item+suffix)
+		}
+	}
+	return out, nil
+}
 
-			break
+// ParseRangeList converts a string containing comma separated digits and ranges into an array of digits.
+//
+// For example, "1,2-4" is [1,2,3,4]. The zero padding of the lower bound is kept: "08-10" is [08,09,10].
+// A range can have a step: "1-9:4" is [1,5,9]. The values after a '^' are excluded: "1-5^2-3" is [1,4,5].
+func ParseRangeList(ranges string) ([]string, error) {
+	include, exclude, hasExclude := strings.Cut(ranges, "^")
+	out, err := parseRanges(include)
+	if err != nil {
+		return nil, err
+	}
+	if !hasExclude {
+		return out, nil
+	}
+	excluded, err := parseRanges(exclude)
+	if err != nil {
+		return nil, err
+	}
+	skip := make(map[int]bool, len(excluded))
+	for _, s := range excluded {
+		n, _ := strconv.Atoi(s)
+		skip[n] = true
+	}
+	kept := out[:0]
+	for _, s := range out {
+		if n, _ := strconv.Atoi(s); !skip[n] {
+			kept = append(kept, s)
 		}
 	}
+	return kept, nil
+}
 
-	// This means there is no brackets
-	if beginIdx == -1 {
-		return []string{pattern}
+func parseRanges(ranges string) ([]string, error) {
+	var out []string
+	for _, digitOrRange := range strings.Split(ranges, ",") {
+		values, err := parseRange(digitOrRange)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, values...)
+		if len(out) > MaxHosts {
+			return nil, fmt.Errorf("%w: [%s]: more than %d hosts", ErrInvalidHostlist, ranges, MaxHosts)
+		}
 	}
+	return out, nil
+}
 
-	var merge []string
-	for _, pattern := range out {
-		names := ExpandBrackets(pattern)
-		if len(names) == 0 {
-			// Pattern is at the smallest factor
-			merge = append(merge, pattern)
-		} else {
-			merge = append(merge, ExpandBrackets(pattern)...)
+func parseRange(s string) ([]string, error) {
+	bounds, stepStr, hasStep := strings.Cut(s, ":")
+	lo, hi, isRange := strings.Cut(bounds, "-")
+	if !isRange {
+		hi = lo
+	}
+	begin, errBegin := parseDigits(lo)
+	end, errEnd := parseDigits(hi)
+	if errBegin != nil || errEnd != nil {
+		return nil, fmt.Errorf("%w: bad range %q", ErrInvalidHostlist, s)
+	}
+	if begin > end {
+		return nil, fmt.Errorf("%w: bad range %q: %d > %d", ErrInvalidHostlist, s, begin, end)
+	}
+	step := 1
+	if hasStep {
+		var err error
+		if step, err = parseDigits(stepStr); err != nil || step == 0 {
+			return nil, fmt.Errorf("%w: bad step %q", ErrInvalidHostlist, s)
 		}
 	}
+	// Counting the values, rather than adding the step up to the end, cannot overflow
+	count := (end-begin)/step + 1
+	if count > MaxHosts {
+		return nil, fmt.Errorf("%w: bad range %q: more than %d hosts", ErrInvalidHostlist, s, MaxHosts)
+	}
 
-	return merge
+	width := 0
+	if len(lo) > 1 && lo[0] == '0' {
+		width = len(lo)
+	}
+	out := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		out = append(out, fmt.Sprintf("%0*d", width, begin+i*step))
+	}
+	return out, nil
 }
 
-// ParseRangeList converts a string containing comma separated digits and ranges into an array of digits.
+// parseDigits parses a non-negative decimal number, without sign.
+func parseDigits(s string) (int, error) {
+	if s == "" || strings.TrimLeft(s, "0123456789") != "" {
+		return 0, fmt.Errorf("not a number: %q", s)
+	}
+	return strconv.Atoi(s)
+}
+
+type compressGroup struct {
+	prefix string
+	suffix string
+	// numbers are the distinct representations of the numbers, like 1 or 01
+	numbers map[string]int
+}
+
+// Compress returns a hostlist expression generating the hostnames, the inverse of Expand.
 //
-// For example, "1,2-4" is [1,2,3,4].
-func ParseRangeList(ranges string) []int {
-	var digits []int
-	digitsOrRanges := strings.Split(ranges, ",")
-
-	for _, digitOrRange := range digitsOrRanges {
-		// Check for digit
-		digit, err := strconv.Atoi(digitOrRange)
-		if err == nil {
-			digits = append(digits, digit)
+// The hostnames are grouped by their last number: cn01, cn02, cn03 and gpu1 give cn[01-03],gpu1.
+// Duplicates are dropped, and the numbers of a group are sorted.
+func Compress(hostnames []string) string {
+	// groups are kept in order of first appearance
+	var groups []*compressGroup
+	type groupKey struct {
+		prefix, suffix string
+		numbered       bool
+	}
+	byKey := make(map[groupKey]*compressGroup)
+	for _, hostname := range hostnames {
+		if hostname == "" {
 			continue
 		}
+		prefix, digits, suffix, ok := splitLastNumber(hostname)
+		n, err := strconv.Atoi(digits)
+		if !ok || err != nil {
+			// Hostnames without number are groups without numbers
+			ok = false
+			prefix, suffix = hostname, ""
+		}
+		key := groupKey{prefix: prefix, suffix: suffix, numbered: ok}
+		g, exists := byKey[key]
+		if !exists {
+			g = &compressGroup{prefix: prefix, suffix: suffix, numbers: make(map[string]int)}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		if ok {
+			g.numbers[digits] = n
+		}
+	}
 
-		// Check for range
-		r := strings.Split(digitOrRange, "-")
-		if len(r) != 2 {
-			// Is not a range
-			continue
+	out := make([]string, 0, len(groups))
+	for _, g := range groups {
+		out = append(out, g.String())
+	}
+	return strings.Join(out, ",")
+}
+
+func (g *compressGroup) String() string {
+	switch len(g.numbers) {
+	case 0:
+		return g.prefix + g.suffix
+	case 1:
+		for digits := range g.numbers {
+			return g.prefix + digits + g.suffix
 		}
-		begin, errBegin := strconv.Atoi(r[0])
-		end, errEnd := strconv.Atoi(r[1])
-		if errBegin == nil && errEnd == nil {
-			for digit := begin; digit <= end; digit++ {
-				digits = append(digits, digit)
-			}
-			continue
+	}
+
+	numbers := make([]string, 0, len(g.numbers))
+	for digits := range g.numbers {
+		numbers = append(numbers, digits)
+	}
+	sort.Slice(numbers, func(i, j int) bool {
+		if g.numbers[numbers[i]] != g.numbers[numbers[j]] {
+			return g.numbers[numbers[i]] < g.numbers[numbers[j]]
 		}
+		return len(numbers[i]) < len(numbers[j])
+	})
+
+	var ranges []string
+	for i := 0; i < len(numbers); {
+		first := numbers[i]
+		width := 0
+		if len(first) > 1 && first[0] == '0' {
+			width = len(first)
+		}
+		// Extend the range while the next number follows with the same format
+		j := i
+		for j+1 < len(numbers) &&
+			g.numbers[numbers[j+1]] == g.numbers[numbers[j]]+1 &&
+			fmt.Sprintf("%0*d", width, g.numbers[numbers[j+1]]) == numbers[j+1] {
+			j++
+		}
+		if j == i {
+			ranges = append(ranges, first)
+		} else {
+			ranges = append(ranges, first+"-"+numbers[j])
+		}
+		i = j + 1
+	}
+	return g.prefix + "[" + strings.Join(ranges, ",") + "]" + g.suffix
+}
+
+// splitLastNumber splits a hostname around its last number.
+func splitLastNumber(hostname string) (prefix, digits, suffix string, ok bool) {
+	end := strings.LastIndexAny(hostname, "0123456789")
+	if end == -1 {
+		return "", "", "", false
+	}
+	begin := end
+	for begin > 0 && hostname[begin-1] >= '0' && hostname[begin-1] <= '9' {
+		begin--
 	}
-	return digits
+	return hostname[:begin], hostname[begin : end+1], hostname[end+1:], true
 }
@@ -1,6 +1,8 @@
 package generators_test
 
 import (
+	"math"
+	"strconv"
 	"testing"
 
 	"github.com/squarefactory/cloud-burster/utils/generators"
@@ -55,6 +57,7 @@ func (suite *GeneratorsTestSuite) TestExpandBrackets() {
 	tests := []struct {
 		input    string
 		expected []string
+		isError  bool
 		title    string
 	}{
 		{
@@ -79,12 +82,52 @@ func (suite *GeneratorsTestSuite) TestExpandBrackets() {
 			title: "No brackets",
 		},
 		{
-			input: "cn-s-[1-2]]",
+			input: "cn[08-10]-gpu",
 			expected: []string{
-				"cn-s-1]",
-				"cn-s-2]",
+				"cn08-gpu",
+				"cn09-gpu",
+				"cn10-gpu",
 			},
-			title: "Weird format",
+			title: "Zero padding",
+		},
+		{
+			input: "cn[001-010:3]",
+			expected: []string{
+				"cn001",
+				"cn004",
+				"cn007",
+				"cn010",
+			},
+			title: "Step",
+		},
+		{
+			input: "cn[01-06^02,04-05]",
+			expected: []string{
+				"cn01",
+				"cn03",
+				"cn06",
+			},
+			title: "Exclusion",
+		},
+		{
+			input:   "cn-s-[1-2]]",
+			isError: true,
+			title:   "Unbalanced brackets",
+		},
+		{
+			input:   "cn-s-[1-2",
+			isError: true,
+			title:   "Unclosed brackets",
+		},
+		{
+			input:   "cn-[[1-2]]",
+			isError: true,
+			title:   "Nested brackets",
+		},
+		{
+			input:   "cn-[]",
+			isError: true,
+			title:   "Empty brackets",
 		},
 		{
 			input:    "",
@@ -96,46 +139,182 @@ func (suite *GeneratorsTestSuite) TestExpandBrackets() {
 	for _, tt := range tests {
 		suite.Run(tt.title, func() {
 			// Act
-			actual := generators.ExpandBrackets(tt.input)
+			actual, err := generators.ExpandBrackets(tt.input)
 
 			// Assert
-			suite.Equal(tt.expected, actual)
+			if tt.isError {
+				suite.ErrorIs(err, generators.ErrInvalidHostlist)
+			} else {
+				suite.NoError(err)
+				suite.Equal(tt.expected, actual)
+			}
 		})
 	}
 }
 
+func (suite *GeneratorsTestSuite) TestExpand() {
+	// Act
+	actual, err := generators.Expand("cn[1-2],gpu[01-02]-[1,3]x,login")
+
+	// Assert
+	suite.NoError(err)
+	suite.Equal([]string{
+		"cn1",
+		"cn2",
+		"gpu01-1x",
+		"gpu01-3x",
+		"gpu02-1x",
+		"gpu02-3x",
+		"login",
+	}, actual)
+}
+
+func (suite *GeneratorsTestSuite) TestExpandTooLarge() {
+	// Act
+	_, err := generators.Expand("cn[0-999999999]")
+
+	// Assert
+	suite.ErrorIs(err, generators.ErrInvalidHostlist)
+}
+
 func (suite *GeneratorsTestSuite) TestParseRangeList() {
+	maxInt, beforeMaxInt := strconv.Itoa(math.MaxInt), strconv.Itoa(math.MaxInt-1)
 	tests := []struct {
 		input    string
-		expected []int
+		expected []string
+		isError  bool
 		title    string
 	}{
 		{
 			input:    "1,2,6-9",
-			expected: []int{1, 2, 6, 7, 8, 9},
+			expected: []string{"1", "2", "6", "7", "8", "9"},
+			title:    "Positive test",
+		},
+		{
+			input:    "7-9,010-011",
+			expected: []string{"7", "8", "9", "010", "011"},
+			title:    "Padding per range",
+		},
+		{
+			input:    "1-9:4",
+			expected: []string{"1", "5", "9"},
+			title:    "Step",
+		},
+		{
+			input:    "1-5^2-3",
+			expected: []string{"1", "4", "5"},
+			title:    "Exclusion",
+		},
+		{
+			input:   "1,2,6-9a",
+			isError: true,
+			title:   "Bad range input",
+		},
+		{
+			input:   "1,2,6-9-10",
+			isError: true,
+			title:   "Bad range input 2",
+		},
+		{
+			input:   "1a,2,6-9",
+			isError: true,
+			title:   "Bad digit input",
+		},
+		{
+			input:   "9-6",
+			isError: true,
+			title:   "Reversed range",
+		},
+		{
+			input:   "1-9:0",
+			isError: true,
+			title:   "Null step",
+		},
+		{
+			input:   "1,,2",
+			isError: true,
+			title:   "Empty item",
+		},
+		{
+			input:    maxInt,
+			expected: []string{maxInt},
+			title:    "Largest number",
+		},
+		{
+			input:    beforeMaxInt + "-" + maxInt,
+			expected: []string{beforeMaxInt, maxInt},
+			title:    "Range up to the largest number",
+		},
+		{
+			input:    "1-9:" + maxInt,
+			expected: []string{"1"},
+			title:    "Largest step",
+		},
+		{
+			input:   "99999999999999999999",
+			isError: true,
+			title:   "Number out of range",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Act
+			actual, err := generators.ParseRangeList(tt.input)
+
+			// Assert
+			if tt.isError {
+				suite.ErrorIs(err, generators.ErrInvalidHostlist)
+			} else {
+				suite.NoError(err)
+				suite.Equal(tt.expected, actual)
+			}
+		})
+	}
+}
+
+func (suite *GeneratorsTestSuite) TestCompress() {
+	tests := []struct {
+		input    []string
+		expected string
+		title    string
+	}{
+		{
+			input:    []string{"cn3", "cn1", "cn2", "cn5", "gpu1", "login"},
+			expected: "cn[1-3,5],gpu1,login",
 			title:    "Positive test",
 		},
 		{
-			input:    "1,2,6-9a",
-			expected: []int{1, 2},
-			title:    "Bad range input",
+			input:    []string{"cn08", "cn09", "cn10", "cn11", "cn100"},
+			expected: "cn[08-11,100]",
+			title:    "Zero padding",
 		},
 		{
-			input:    "1,2,6-9-10",
-			expected: []int{1, 2},
-			title:    "Bad range input 2",
+			input:    []string{"cn01", "cn1", "cn02", "cn2"},
+			expected: "cn[1,01,2,02]",
+			title:    "Mixed padding",
 		},
 		{
-			input:    "1a,2,6-9",
-			expected: []int{2, 6, 7, 8, 9},
-			title:    "Bad digit input",
+			input:    []string{"rack1-cn1", "rack1-cn2", "rack2-cn1", "rack1-cn2"},
+			expected: "rack1-cn[1-2],rack2-cn1",
+			title:    "Last number and duplicates",
+		},
+		{
+			input:    []string{"cn1-gpu", "cn2-gpu", "cn", "cn1"},
+			expected: "cn[1-2]-gpu,cn,cn1",
+			title:    "Suffix",
+		},
+		{
+			input:    []string{},
+			expected: "",
+			title:    "Empty test",
 		},
 	}
 
 	for _, tt := range tests {
 		suite.Run(tt.title, func() {
 			// Act
-			actual := generators.ParseRangeList(tt.input)
+			actual := generators.Compress(tt.input)
 
 			// Assert
 			suite.Equal(tt.expected, actual)
@@ -143,6 +322,18 @@ func (suite *GeneratorsTestSuite) TestParseRangeList() {
 	}
 }
 
+func (suite *GeneratorsTestSuite) TestCompressExpand() {
+	// Arrange
+	hostnames := []string{"cn001", "cn002", "cn009", "cn010", "cn-a1", "cn-a2", "x9", "x10", "x011"}
+
+	// Act
+	actual, err := generators.Expand(generators.Compress(hostnames))
+
+	// Assert
+	suite.NoError(err)
+	suite.ElementsMatch(hostnames, actual)
+}
+
 func TestGeneratorsTestSuite(t *testing.T) {
 	suite.Run(t, &GeneratorsTestSuite{})
 }
@@ -0,0 +1,52 @@
+// Package pool runs a function over a list of items with bounded concurrency.
+package pool
+
+import (
+	"context"
+	"sync"
+)
+
+// Each calls fn for every item, with at most n calls running at the same time.
+// A non-positive n runs every item at once.
+//
+// The returned slice holds the error of each item, at the same index. Items
+// not started before ctx is canceled get the error of the context.
+func Each[T any](
+	ctx context.Context,
+	n int,
+	items []T,
+	fn func(ctx context.Context, item T) error,
+) []error {
+	errs := make([]error, len(items))
+	if n <= 0 || n > len(items) {
+		n = len(items)
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := ctx.Err(); err != nil {
+					errs[i] = err
+					continue
+				}
+				errs[i] = fn(ctx, items[i])
+			}
+		}()
+	}
+
+	for i := range items {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	return errs
+}
@@ -0,0 +1,95 @@
+package pool_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/squarefactory/cloud-burster/utils/pool"
+	"github.com/stretchr/testify/suite"
+)
+
+type PoolTestSuite struct {
+	suite.Suite
+}
+
+func (suite *PoolTestSuite) TestEach() {
+	tests := []struct {
+		n        int
+		expected int32
+		title    string
+	}{
+		{
+			n:        3,
+			expected: 3,
+			title:    "Bounded",
+		},
+		{
+			n:        0,
+			expected: 10,
+			title:    "Unbounded",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Arrange
+			items := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+			var running, peak atomic.Int32
+			errBoom := errors.New("boom")
+
+			// Act
+			errs := pool.Each(context.Background(), tt.n, items, func(ctx context.Context, i int) error {
+				current := running.Add(1)
+				defer running.Add(-1)
+				for {
+					p := peak.Load()
+					if current <= p || peak.CompareAndSwap(p, current) {
+						break
+					}
+				}
+				time.Sleep(20 * time.Millisecond)
+				if i == 4 {
+					return errBoom
+				}
+				return nil
+			})
+
+			// Assert
+			suite.Equal(tt.expected, peak.Load())
+			suite.Len(errs, len(items))
+			for i, err := range errs {
+				if i == 4 {
+					suite.ErrorIs(err, errBoom)
+				} else {
+					suite.NoError(err)
+				}
+			}
+		})
+	}
+}
+
+func (suite *PoolTestSuite) TestEachCanceled() {
+	// Arrange
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls atomic.Int32
+
+	// Act
+	errs := pool.Each(ctx, 1, []int{0, 1, 2}, func(ctx context.Context, i int) error {
+		calls.Add(1)
+		cancel()
+		return nil
+	})
+
+	// Assert
+	suite.Equal(int32(1), calls.Load())
+	suite.NoError(errs[0])
+	suite.ErrorIs(errs[1], context.Canceled)
+	suite.ErrorIs(errs[2], context.Canceled)
+}
+
+func TestPoolTestSuite(t *testing.T) {
+	suite.Run(t, &PoolTestSuite{})
+}
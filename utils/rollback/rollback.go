@@ -0,0 +1,95 @@
+// Package rollback unwinds the sub-resources of a failed creation.
+package rollback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/squarefactory/cloud-burster/logger"
+	"go.uber.org/zap"
+)
+
+// Timeout bounds the undo actions of a rollback.
+var Timeout = 5 * time.Minute
+
+type step struct {
+	resource string
+	undo     func(ctx context.Context) error
+}
+
+// Stack records the undo action of each created sub-resource.
+//
+// Usage:
+//
+//	tx := rollback.New("create " + host.Name)
+//	defer tx.Rollback(ctx)
+//	id, err := createPort()
+//	if err != nil {
+//		return err
+//	}
+//	tx.Push("port "+id, func(ctx context.Context) error { return deletePort(id) })
+//	...
+//	tx.Commit()
+type Stack struct {
+	name string
+
+	mu        sync.Mutex
+	steps     []step
+	committed bool
+}
+
+func New(name string) *Stack {
+	return &Stack{
+		name: name,
+	}
+}
+
+// Push registers the undo action of a sub-resource.
+func (s *Stack) Push(resource string, undo func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.steps = append(s.steps, step{resource: resource, undo: undo})
+}
+
+// Commit keeps the sub-resources: Rollback becomes a no-op.
+func (s *Stack) Commit() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.committed = true
+}
+
+// Rollback runs the undo actions in reverse order, unless the stack is committed.
+//
+// The undo actions still run if ctx is canceled, within Timeout.
+// Every action runs, even if a previous one failed.
+func (s *Stack) Rollback(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.committed || len(s.steps) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), Timeout)
+	defer cancel()
+
+	logger.I.Warn("rolling back", zap.String("operation", s.name), zap.Int("resources", len(s.steps)))
+	var errs []error
+	for i := len(s.steps) - 1; i >= 0; i-- {
+		step := s.steps[i]
+		if err := step.undo(ctx); err != nil {
+			logger.I.Error(
+				"couldn't remove resource",
+				zap.String("operation", s.name),
+				zap.String("resource", step.resource),
+				zap.Error(err),
+			)
+			errs = append(errs, fmt.Errorf("%s: %w", step.resource, err))
+			continue
+		}
+		logger.I.Info("removed resource", zap.String("operation", s.name), zap.String("resource", step.resource))
+	}
+	s.steps = nil
+	return errors.Join(errs...)
+}
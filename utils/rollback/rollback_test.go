@@ -0,0 +1,84 @@
+package rollback_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/squarefactory/cloud-burster/utils/rollback"
+	"github.com/stretchr/testify/suite"
+)
+
+type RollbackTestSuite struct {
+	suite.Suite
+}
+
+func (suite *RollbackTestSuite) TestRollback() {
+	tests := []struct {
+		commit        bool
+		cancel        bool
+		expected      []string
+		errorContains string
+		title         string
+	}{
+		{
+			expected:      []string{"server", "port"},
+			errorContains: "server: boom",
+			title:         "Unwind in reverse order",
+		},
+		{
+			cancel:        true,
+			expected:      []string{"server", "port"},
+			errorContains: "server: boom",
+			title:         "Unwind after cancellation",
+		},
+		{
+			commit:   true,
+			expected: nil,
+			title:    "Committed",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Arrange
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var undone []string
+			tx := rollback.New("create cn-1")
+			tx.Push("port", func(ctx context.Context) error {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				undone = append(undone, "port")
+				return nil
+			})
+			tx.Push("server", func(ctx context.Context) error {
+				undone = append(undone, "server")
+				return errors.New("boom")
+			})
+			if tt.commit {
+				tx.Commit()
+			}
+			if tt.cancel {
+				cancel()
+			}
+
+			// Act
+			err := tx.Rollback(ctx)
+
+			// Assert
+			suite.Equal(tt.expected, undone)
+			if tt.errorContains != "" {
+				suite.ErrorContains(err, tt.errorContains)
+			} else {
+				suite.NoError(err)
+			}
+			suite.NoError(tx.Rollback(ctx), "should only unwind once")
+		})
+	}
+}
+
+func TestRollbackTestSuite(t *testing.T) {
+	suite.Run(t, &RollbackTestSuite{})
+}
@@ -1,24 +1,140 @@
 package try
 
 import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
 	"time"
 
 	"github.com/squarefactory/cloud-burster/logger"
 	"go.uber.org/zap"
 )
 
+// Policy describes how an operation is retried.
+//
+// The delay before the n-th retry is InitialDelay * Multiplier^(n-1), capped to MaxDelay,
+// and randomized by ±Jitter.
+type Policy struct {
+	// MaxAttempts is the maximum number of calls. Zero means unlimited.
+	MaxAttempts int
+	// MaxElapsed stops the retries once exceeded. Zero means unlimited.
+	MaxElapsed   time.Duration
+	InitialDelay time.Duration
+	// MaxDelay caps the delay. Zero means no cap.
+	MaxDelay time.Duration
+	// Multiplier grows the delay. Values lower than 1 keep the delay constant.
+	Multiplier float64
+	// Jitter is the randomization factor of the delay, between 0 and 1.
+	Jitter float64
+	// Retryable classifies the errors. If nil, every error is retried.
+	// Errors wrapped with Permanent are never retried.
+	Retryable func(err error) bool
+}
+
+// Constant returns a policy calling the operation at most attempts times, waiting delay between the calls.
+func Constant(attempts int, delay time.Duration) Policy {
+	return Policy{
+		MaxAttempts:  attempts,
+		InitialDelay: delay,
+	}
+}
+
+// Backoff returns the delay before the n-th retry, starting at 1, without jitter.
+func (p Policy) Backoff(n int) time.Duration {
+	multiplier := math.Max(p.Multiplier, 1)
+	delay := float64(p.InitialDelay) * math.Pow(multiplier, float64(n-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		return p.MaxDelay
+	}
+	if delay > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(delay)
+}
+
+func (p Policy) jitter(delay time.Duration) time.Duration {
+	if p.Jitter <= 0 {
+		return delay
+	}
+	//nolint:gosec // The jitter doesn't need a secure random source.
+	factor := 1 + p.Jitter*(2*rand.Float64()-1)
+	return time.Duration(float64(delay) * factor)
+}
+
+func (p Policy) retryable(err error) bool {
+	if IsPermanent(err) {
+		return false
+	}
+	return p.Retryable == nil || p.Retryable(err)
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks an error as not retryable.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent returns true if the error was marked with Permanent.
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// Do calls fn until it succeeds, following the policy.
+//
+// It stops on errors which are not retryable, and when ctx is done.
 func Do[T interface{}](
+	ctx context.Context,
+	policy Policy,
 	fn func() (T, error),
-	tries int,
-	delay time.Duration,
 ) (result T, err error) {
-	for try := 0; try < tries; try++ {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
 		result, err = fn()
 		if err == nil {
-			break
+			return result, nil
+		}
+		if !policy.retryable(err) {
+			logger.I.Warn("try failed, not retrying", zap.Error(err), zap.Int("try", attempt))
+			return result, err
+		}
+		if policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts {
+			return result, err
+		}
+		delay := policy.jitter(policy.Backoff(attempt))
+		if policy.MaxElapsed > 0 && time.Since(start)+delay > policy.MaxElapsed {
+			return result, err
 		}
-		logger.I.Warn("try failed", zap.Error(err), zap.Int("try", try))
-		time.Sleep(delay)
+		logger.I.Warn("try failed", zap.Error(err), zap.Int("try", attempt), zap.Duration("delay", delay))
+		if sErr := Sleep(ctx, delay); sErr != nil {
+			return result, sErr
+		}
+	}
+}
+
+// Sleep waits for d, or until ctx is done.
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
 	}
-	return result, err
 }
@@ -0,0 +1,136 @@
+package try_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/squarefactory/cloud-burster/utils/try"
+	"github.com/stretchr/testify/suite"
+)
+
+var errPermanent = errors.New("permanent")
+
+type TryTestSuite struct {
+	suite.Suite
+}
+
+func (suite *TryTestSuite) TestDo() {
+	tests := []struct {
+		policy        try.Policy
+		errs          []error
+		expectedCalls int
+		isError       bool
+		title         string
+	}{
+		{
+			policy:        try.Constant(5, time.Millisecond),
+			errs:          []error{errors.New("a"), errors.New("b")},
+			expectedCalls: 3,
+			title:         "Positive test",
+		},
+		{
+			policy:        try.Constant(2, time.Millisecond),
+			errs:          []error{errors.New("a"), errors.New("b"), errors.New("c")},
+			expectedCalls: 2,
+			isError:       true,
+			title:         "Max attempts",
+		},
+		{
+			policy: try.Policy{
+				MaxElapsed:   50 * time.Millisecond,
+				InitialDelay: 20 * time.Millisecond,
+				Multiplier:   2,
+			},
+			errs:          []error{errors.New("a"), errors.New("b"), errors.New("c")},
+			expectedCalls: 2,
+			isError:       true,
+			title:         "Max elapsed",
+		},
+		{
+			policy:        try.Constant(5, time.Millisecond),
+			errs:          []error{try.Permanent(errors.New("a"))},
+			expectedCalls: 1,
+			isError:       true,
+			title:         "Permanent error",
+		},
+		{
+			policy: try.Policy{
+				InitialDelay: time.Millisecond,
+				Retryable: func(err error) bool {
+					return !errors.Is(err, errPermanent)
+				},
+			},
+			errs:          []error{errors.New("a"), errPermanent},
+			expectedCalls: 2,
+			isError:       true,
+			title:         "Classifier",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.title, func() {
+			// Arrange
+			calls := 0
+			fn := func() (int, error) {
+				calls++
+				if calls <= len(tt.errs) {
+					return 0, tt.errs[calls-1]
+				}
+				return calls, nil
+			}
+
+			// Act
+			result, err := try.Do(context.Background(), tt.policy, fn)
+
+			// Assert
+			suite.Equal(tt.expectedCalls, calls)
+			if tt.isError {
+				suite.Error(err)
+			} else {
+				suite.NoError(err)
+				suite.Equal(tt.expectedCalls, result)
+			}
+		})
+	}
+}
+
+func (suite *TryTestSuite) TestDoCanceled() {
+	// Arrange
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	fn := func() (int, error) {
+		calls++
+		cancel()
+		return 0, errors.New("not yet")
+	}
+
+	// Act
+	start := time.Now()
+	_, err := try.Do(ctx, try.Constant(5, time.Minute), fn)
+
+	// Assert
+	suite.ErrorIs(err, context.Canceled)
+	suite.Equal(1, calls)
+	suite.Less(time.Since(start), time.Second)
+}
+
+func (suite *TryTestSuite) TestBackoff() {
+	// Arrange
+	policy := try.Policy{
+		InitialDelay: time.Second,
+		MaxDelay:     5 * time.Second,
+		Multiplier:   2,
+	}
+
+	// Act
+	actual := []time.Duration{policy.Backoff(1), policy.Backoff(2), policy.Backoff(3), policy.Backoff(4)}
+
+	// Assert
+	suite.Equal([]time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second}, actual)
+}
+
+func TestTryTestSuite(t *testing.T) {
+	suite.Run(t, &TryTestSuite{})
+}